@@ -0,0 +1,150 @@
+// jkit-config get, put, diff and roll back consul config keys
+//
+//	jkit-config get <key>
+//	jkit-config put <key> [file]
+//	jkit-config diff <key> [file]
+//	jkit-config history <key>
+//	jkit-config rollback <key> <version>
+//	jkit-config delete <key>
+//
+// put, diff read the value from stdin when no file given
+// values of conn/v1/... and service/go/... are validated before writing
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/JREAMLU/j-kit/consul"
+	"github.com/JREAMLU/j-kit/database/elastic"
+	"github.com/JREAMLU/j-kit/database/mongo"
+	"github.com/JREAMLU/j-kit/database/mysql"
+	"github.com/JREAMLU/j-kit/database/redis"
+	"github.com/JREAMLU/j-kit/go-micro/util"
+)
+
+var (
+	addr       = flag.String("addr", "127.0.0.1:8500", "consul address")
+	token      = flag.String("token", "", "consul acl token")
+	datacenter = flag.String("dc", "", "consul datacenter")
+	author     = flag.String("author", os.Getenv("USER"), "author of the change")
+	maxHistory = flag.Int("history", 20, "max revisions kept of every key")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] get|put|diff|history|rollback|delete <key> [file|version]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client, err := consul.NewClient(
+		consul.SetAddress(*addr),
+		consul.SetToken(*token),
+		consul.SetDatacenter(*datacenter),
+	)
+	if err != nil {
+		exit(err)
+	}
+
+	admin := client.NewConfigAdmin(consul.SetMaxHistory(*maxHistory))
+	registerValidators(admin)
+
+	if err = run(admin, flag.Arg(0), flag.Arg(1), flag.Args()[2:]); err != nil {
+		exit(err)
+	}
+}
+
+func registerValidators(admin *consul.ConfigAdmin) {
+	admin.RegisterValidator(consul.MYSQL, consul.ValidateTOML(func() interface{} { return &mysql.Config{} }, true))
+	admin.RegisterValidator(consul.Redis, consul.ValidateTOML(func() interface{} { return &redis.Configs{} }, true))
+	admin.RegisterValidator(consul.MongoDB, consul.ValidateTOML(func() interface{} { return &mongo.Config{} }, true))
+	admin.RegisterValidator(consul.ElasticSearch, consul.ValidateTOML(func() interface{} { return &elastic.Config{} }, true))
+	admin.RegisterValidator(consul.Kafka, consul.ValidateTOML(func() interface{} { return &consul.KafkaBrokers{} }, true))
+	admin.RegisterValidator(consul.Zookeeper, consul.ValidateTOML(func() interface{} { return &consul.KafkaZookeeper{} }, true))
+	admin.RegisterValidator(consul.Consul, consul.ValidateTOML(func() interface{} { return &consul.RegistryConsul{} }, true))
+	// service config may have custom sections, unknown keys are allowed
+	admin.RegisterValidator("service/go/", consul.ValidateTOML(func() interface{} { return &util.Config{} }, false))
+}
+
+func run(admin *consul.ConfigAdmin, command, key string, args []string) error {
+	switch command {
+	case "get":
+		value, err := admin.Get(key)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(value)
+	case "put":
+		value, err := readValue(args)
+		if err != nil {
+			return err
+		}
+
+		return admin.Put(key, value, *author)
+	case "diff":
+		value, err := readValue(args)
+		if err != nil {
+			return err
+		}
+
+		diff, err := admin.Diff(key, value)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(diff)
+	case "history":
+		revisions, err := admin.History(key)
+		if err != nil {
+			return err
+		}
+
+		for _, revision := range revisions {
+			fmt.Printf("%d\t%s\t%-8s\t%s\n", revision.Version, revision.Time.Format(time.RFC3339), revision.Action, revision.Author)
+		}
+	case "rollback":
+		if len(args) == 0 {
+			return fmt.Errorf("rollback needs a version, see history")
+		}
+
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return err
+		}
+
+		return admin.Rollback(key, version, *author)
+	case "delete":
+		return admin.Delete(key, *author)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+
+	return nil
+}
+
+// readValue read value from file, or stdin when no file given
+func readValue(args []string) (string, error) {
+	if len(args) > 0 {
+		buf, err := ioutil.ReadFile(args[0])
+		return string(buf), err
+	}
+
+	buf, err := ioutil.ReadAll(os.Stdin)
+	return string(buf), err
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
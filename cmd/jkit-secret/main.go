@@ -0,0 +1,114 @@
+// jkit-secret encrypt or decrypt consul config values
+//
+//	jkit-secret -genkey
+//	jkit-secret -key <hex key> <value>...
+//	echo <value> | JKIT_CONSUL_SECRET_KEY=<hex key> jkit-secret
+//	jkit-secret -keyfile <file> -d <enc:...>
+//
+// the key defaults to JKIT_CONSUL_SECRET_KEY or the file of JKIT_CONSUL_SECRET_KEY_FILE
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/JREAMLU/j-kit/consul"
+)
+
+var (
+	key     = flag.String("key", "", "hex aes key, 16, 24 or 32 bytes")
+	keyFile = flag.String("keyfile", "", "file of hex aes key")
+	decrypt = flag.Bool("d", false, "decrypt values")
+	genKey  = flag.Bool("genkey", false, "generate a 32 bytes hex aes key")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-key key | -keyfile file] [-d] [value...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "values are read from stdin by line when no value given\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *genKey {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			exit(err)
+		}
+
+		fmt.Println(hex.EncodeToString(buf))
+		return
+	}
+
+	secretKey, err := loadKey()
+	if err != nil {
+		exit(err)
+	}
+
+	if flag.NArg() > 0 {
+		for _, value := range flag.Args() {
+			if err = convert(value, secretKey); err != nil {
+				exit(err)
+			}
+		}
+		return
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if err = convert(scanner.Text(), secretKey); err != nil {
+			exit(err)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		exit(err)
+	}
+}
+
+func loadKey() (string, error) {
+	if *key != "" {
+		return *key, nil
+	}
+
+	if *keyFile != "" {
+		return consul.ReadSecretKeyFile(*keyFile)
+	}
+
+	secretKey, err := consul.LoadSecretKey()
+	if err != nil {
+		return "", err
+	}
+
+	if secretKey == "" {
+		return "", consul.ErrSecretKeyNotSet
+	}
+
+	return secretKey, nil
+}
+
+func convert(value, secretKey string) error {
+	var result string
+	var err error
+	if *decrypt {
+		result, err = consul.DecryptSecret(value, secretKey)
+	} else {
+		result, err = consul.EncryptSecret(value, secretKey)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(result)
+	return nil
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,369 @@
+package consul
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// HistoryPrefix default prefix of prior versions, eg. history/conn/v1/mysql/BGCrawler/<version>
+	HistoryPrefix = "history"
+	// ActionPut put action
+	ActionPut = "put"
+	// ActionDelete delete action
+	ActionDelete = "delete"
+	// ActionRollback rollback action
+	ActionRollback = "rollback"
+
+	_defaultMaxHistory = 20
+)
+
+var (
+	// ErrConflict key changed by others during the write
+	ErrConflict = errors.New("consul: key changed during the write, retry")
+	// ErrRevisionNotExist revision not exist
+	ErrRevisionNotExist = errors.New("consul: revision does not exist")
+)
+
+// Validator validate value of key before writing
+type Validator func(key, value string) error
+
+// ValidateTOML validate value is toml of the struct returned by newValue
+// strict rejects keys without struct field, eg. typos, wholly encrypted values are not validated
+func ValidateTOML(newValue func() interface{}, strict bool) Validator {
+	return func(key, value string) error {
+		if IsSecret(strings.TrimSpace(value)) {
+			return nil
+		}
+
+		md, err := toml.Decode(value, newValue())
+		if err != nil {
+			return &DecodeError{Key: key, Err: err}
+		}
+
+		if undecoded := md.Undecoded(); strict && len(undecoded) > 0 {
+			return &DecodeError{Key: key, Err: fmt.Errorf("unknown keys %v", undecoded)}
+		}
+
+		return nil
+	}
+}
+
+// Revision prior version of key, recorded on every change
+type Revision struct {
+	Version int64
+	Action  string
+	Author  string
+	Time    time.Time
+	// Value before the change, Exists is false when the key did not exist
+	Value  string
+	Exists bool
+}
+
+// ConfigAdmin get, put, diff and rollback config keys with validation and history
+// every change keeps the prior value under the history prefix, in the same transaction
+type ConfigAdmin struct {
+	client        *Client
+	historyPrefix string
+	maxHistory    int
+	validators    map[string]Validator
+}
+
+// ConfigAdminOptionFunc config admin option func
+type ConfigAdminOptionFunc func(*ConfigAdmin)
+
+// SetHistoryPrefix set history prefix, default is HistoryPrefix
+func SetHistoryPrefix(prefix string) ConfigAdminOptionFunc {
+	return func(admin *ConfigAdmin) {
+		if prefix != "" {
+			admin.historyPrefix = strings.Trim(prefix, "/")
+		}
+	}
+}
+
+// SetMaxHistory set max revisions kept of every key, default is 20
+func SetMaxHistory(max int) ConfigAdminOptionFunc {
+	return func(admin *ConfigAdmin) {
+		if max > 0 {
+			admin.maxHistory = max
+		}
+	}
+}
+
+// NewConfigAdmin new config admin, values are read and written as is, secrets are not decrypted
+func (client *Client) NewConfigAdmin(opts ...ConfigAdminOptionFunc) *ConfigAdmin {
+	admin := &ConfigAdmin{
+		client:        client,
+		historyPrefix: HistoryPrefix,
+		maxHistory:    _defaultMaxHistory,
+		validators:    make(map[string]Validator),
+	}
+
+	for _, opt := range opts {
+		opt(admin)
+	}
+
+	return admin
+}
+
+// RegisterValidator validate keys under keyPrefix, the longest prefix wins
+func (admin *ConfigAdmin) RegisterValidator(keyPrefix string, validator Validator) {
+	admin.validators[strings.TrimSuffix(keyPrefix, "/")+"/"] = validator
+}
+
+// Validate validate value of key by the registered validator
+func (admin *ConfigAdmin) Validate(key, value string) error {
+	var matched string
+	for prefix := range admin.validators {
+		if strings.HasPrefix(key, prefix) && len(prefix) > len(matched) {
+			matched = prefix
+		}
+	}
+
+	if matched == "" {
+		return nil
+	}
+
+	return admin.validators[matched](key, value)
+}
+
+// Get get raw value of key
+func (admin *ConfigAdmin) Get(key string) (string, error) {
+	kvPair, err := admin.get(key)
+	if err != nil {
+		return "", err
+	}
+
+	if kvPair == nil {
+		return "", fmt.Errorf(KeyNotExist, key)
+	}
+
+	return string(kvPair.Value), nil
+}
+
+func (admin *ConfigAdmin) get(key string) (*api.KVPair, error) {
+	kvPair, _, err := admin.client.KV().Get(key, nil)
+	return kvPair, err
+}
+
+// Diff diff the current value of key with value, lines prefixed with "-" and "+"
+func (admin *ConfigAdmin) Diff(key, value string) (string, error) {
+	kvPair, err := admin.get(key)
+	if err != nil {
+		return "", err
+	}
+
+	var current string
+	if kvPair != nil {
+		current = string(kvPair.Value)
+	}
+
+	return DiffLines(current, value), nil
+}
+
+// Put validate and put value, the prior value is kept in history
+func (admin *ConfigAdmin) Put(key, value, author string) error {
+	if err := admin.Validate(key, value); err != nil {
+		return err
+	}
+
+	return admin.write(key, &value, author, ActionPut)
+}
+
+// Delete delete key, the prior value is kept in history
+func (admin *ConfigAdmin) Delete(key, author string) error {
+	return admin.write(key, nil, author, ActionDelete)
+}
+
+// Rollback put the value of key before the change of version
+func (admin *ConfigAdmin) Rollback(key string, version int64, author string) error {
+	kvPair, err := admin.get(admin.historyKey(key, version))
+	if err != nil {
+		return err
+	}
+
+	if kvPair == nil {
+		return ErrRevisionNotExist
+	}
+
+	var revision Revision
+	if err = json.Unmarshal(kvPair.Value, &revision); err != nil {
+		return &DecodeError{Key: kvPair.Key, Err: err}
+	}
+
+	if !revision.Exists {
+		return admin.write(key, nil, author, ActionRollback)
+	}
+
+	if err = admin.Validate(key, revision.Value); err != nil {
+		return err
+	}
+
+	return admin.write(key, &revision.Value, author, ActionRollback)
+}
+
+// History revisions of key, newest first
+func (admin *ConfigAdmin) History(key string) ([]*Revision, error) {
+	kvPairs, _, err := admin.client.KV().List(admin.historyDir(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]*Revision, 0, len(kvPairs))
+	for i := range kvPairs {
+		// keys of deeper dirs belong to other keys
+		if path.Dir(kvPairs[i].Key) != strings.TrimSuffix(admin.historyDir(key), "/") {
+			continue
+		}
+
+		var revision Revision
+		if err = json.Unmarshal(kvPairs[i].Value, &revision); err != nil {
+			return nil, &DecodeError{Key: kvPairs[i].Key, Err: err}
+		}
+		revisions = append(revisions, &revision)
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Version > revisions[j].Version
+	})
+
+	return revisions, nil
+}
+
+// write set or delete (value is nil) key with the prior value in history, by check-and-set
+func (admin *ConfigAdmin) write(key string, value *string, author, action string) error {
+	kvPair, err := admin.get(key)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	revision := &Revision{
+		Version: now.UnixNano(),
+		Action:  action,
+		Author:  author,
+		Time:    now,
+	}
+
+	if value == nil && kvPair == nil {
+		return fmt.Errorf(KeyNotExist, key)
+	}
+
+	var index uint64
+	if kvPair != nil {
+		index = kvPair.ModifyIndex
+		revision.Value = string(kvPair.Value)
+		revision.Exists = true
+	}
+
+	buf, err := json.Marshal(revision)
+	if err != nil {
+		return err
+	}
+
+	txn := admin.client.Txn().Set(admin.historyKey(key, revision.Version), string(buf))
+	if value != nil {
+		txn.CAS(key, *value, index)
+	} else {
+		txn.DeleteCAS(key, index)
+	}
+
+	if _, err = txn.Commit(); err != nil {
+		if _, ok := err.(*TxnError); ok {
+			return ErrConflict
+		}
+
+		return err
+	}
+
+	log.Printf("Consul config %v: %v, author: %v, version: %v \r\n", action, key, author, revision.Version)
+
+	admin.trimHistory(key)
+
+	return nil
+}
+
+// trimHistory keep the newest maxHistory revisions, failure only logged
+func (admin *ConfigAdmin) trimHistory(key string) {
+	revisions, err := admin.History(key)
+	if err != nil || len(revisions) <= admin.maxHistory {
+		return
+	}
+
+	txn := admin.client.Txn()
+	for _, revision := range revisions[admin.maxHistory:] {
+		txn.Delete(admin.historyKey(key, revision.Version))
+	}
+
+	if _, err = txn.Commit(); err != nil {
+		log.Printf("Failed on consul config history trim, key: %v, err: %v \r\n", key, err)
+	}
+}
+
+func (admin *ConfigAdmin) historyDir(key string) string {
+	return path.Join(admin.historyPrefix, key) + "/"
+}
+
+// historyKey zero padded version, keys sort by time
+func (admin *ConfigAdmin) historyKey(key string, version int64) string {
+	return admin.historyDir(key) + fmt.Sprintf("%020d", version)
+}
+
+// DiffLines line diff from a to b, removed lines prefixed with "-", added with "+", kept with " "
+func DiffLines(a, b string) string {
+	x, y := splitLines(a), splitLines(b)
+
+	// lcs[i][j] longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var buf strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			buf.WriteString(" " + x[i] + "\n")
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			buf.WriteString("-" + x[i] + "\n")
+			i++
+		default:
+			buf.WriteString("+" + y[j] + "\n")
+			j++
+		}
+	}
+
+	return buf.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
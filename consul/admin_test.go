@@ -0,0 +1,120 @@
+package consul
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testMySQLConfig struct {
+	InstanceName string
+	DBName       string
+	ReadWrite    struct {
+		Server   string
+		Password string
+	}
+}
+
+func TestConfigAdmin(t *testing.T) {
+	Convey("config admin test", t, func() {
+		fake, server := newFakeKV()
+		defer server.Close()
+
+		client, err := NewClient(SetAddress(strings.TrimPrefix(server.URL, "http://")))
+		So(err, ShouldBeNil)
+
+		admin := client.NewConfigAdmin(SetMaxHistory(2))
+		admin.RegisterValidator(MYSQL, ValidateTOML(func() interface{} { return &testMySQLConfig{} }, true))
+
+		key := path.Join(MYSQL, "BGCrawler")
+		v1 := "InstanceName = \"BGCrawler\"\nDBName = \"crawler\""
+		v2 := "InstanceName = \"BGCrawler\"\nDBName = \"crawler2\""
+
+		Convey("validate", func() {
+			So(admin.Put(key, "DBName = ", "jream"), ShouldNotBeNil)
+			So(admin.Put(key, "DBNmae = \"typo\"", "jream"), ShouldNotBeNil)
+			So(admin.Put("service/go/test/any", "not toml =", "jream"), ShouldBeNil)
+
+			_, err := admin.Get(key)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("put, history and rollback", func() {
+			So(admin.Put(key, v1, "jream"), ShouldBeNil)
+			So(admin.Put(key, v2, "lu"), ShouldBeNil)
+
+			value, err := admin.Get(key)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, v2)
+
+			revisions, err := admin.History(key)
+			So(err, ShouldBeNil)
+			So(len(revisions), ShouldEqual, 2)
+			So(revisions[0].Author, ShouldEqual, "lu")
+			So(revisions[0].Value, ShouldEqual, v1)
+			So(revisions[1].Exists, ShouldBeFalse)
+
+			So(admin.Rollback(key, revisions[0].Version, "jream"), ShouldBeNil)
+			value, err = admin.Get(key)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, v1)
+
+			revisions, err = admin.History(key)
+			So(err, ShouldBeNil)
+			So(len(revisions), ShouldEqual, 2)
+			So(revisions[0].Action, ShouldEqual, ActionRollback)
+			So(revisions[0].Value, ShouldEqual, v2)
+
+			So(admin.Rollback(key, 1, "jream"), ShouldEqual, ErrRevisionNotExist)
+		})
+
+		Convey("delete", func() {
+			So(admin.Put(key, v1, "jream"), ShouldBeNil)
+			So(admin.Delete(key, "jream"), ShouldBeNil)
+			_, err := admin.Get(key)
+			So(err, ShouldNotBeNil)
+
+			So(admin.Delete(key, "jream"), ShouldNotBeNil)
+
+			revisions, err := admin.History(key)
+			So(err, ShouldBeNil)
+			So(revisions[0].Action, ShouldEqual, ActionDelete)
+			So(admin.Rollback(key, revisions[0].Version, "jream"), ShouldBeNil)
+
+			value, err := admin.Get(key)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, v1)
+		})
+
+		Convey("conflict", func() {
+			So(admin.Put(key, v1, "jream"), ShouldBeNil)
+
+			fake.mutex.Lock()
+			fake.touchOnGet = true
+			fake.mutex.Unlock()
+
+			So(admin.Put(key, v2, "jream"), ShouldEqual, ErrConflict)
+
+			value, err := admin.Get(key)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, v1)
+		})
+
+		Convey("diff", func() {
+			So(admin.Put(key, v1, "jream"), ShouldBeNil)
+			diff, err := admin.Diff(key, v2)
+			So(err, ShouldBeNil)
+			So(diff, ShouldEqual, " InstanceName = \"BGCrawler\"\n-DBName = \"crawler\"\n+DBName = \"crawler2\"\n")
+		})
+	})
+}
+
+func TestDiffLines(t *testing.T) {
+	Convey("diff lines test", t, func() {
+		So(DiffLines("", "a\nb\n"), ShouldEqual, "+a\n+b\n")
+		So(DiffLines("a\nb", ""), ShouldEqual, "-a\n-b\n")
+		So(DiffLines("a\nb\nc", "a\nc\nd"), ShouldEqual, " a\n-b\n c\n+d\n")
+	})
+}
@@ -1,14 +1,17 @@
 package consul
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net"
+	"os"
 	"path"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/hashicorp/consul/api"
 )
 
@@ -21,22 +24,40 @@ const (
 
 // Client consul client
 type Client struct {
-	config       *api.Config
-	consulClient *api.Client
-	register     *api.AgentServiceRegistration
+	config            *api.Config
+	consulClient      *api.Client
+	register          *api.AgentServiceRegistration
+	heartbeatInterval time.Duration
+	snapshotDir       string
+	fileDir           string
+	secretKey         string
 }
 
 // NewClient new client
 func NewClient(opts ...ClientOptionFunc) (*Client, error) {
 	client := &Client{
-		config: api.DefaultConfig(),
+		config:      api.DefaultConfig(),
+		snapshotDir: os.Getenv(SnapshotDirEnvName),
+		fileDir:     os.Getenv(FileDirEnvName),
 	}
 
+	secretKey, err := LoadSecretKey()
+	if err != nil {
+		return nil, err
+	}
+	client.secretKey = secretKey
+
 	for _, opt := range opts {
-		opt(client)
+		if err = opt(client); err != nil {
+			return nil, err
+		}
 	}
 
-	log.Printf("Config Consul Addrs: %v\n", client.config.Address)
+	if client.fileDir != "" {
+		log.Printf("Config Consul File Dir: %v\n", client.fileDir)
+	} else {
+		log.Printf("Config Consul Addrs: %v\n", client.config.Address)
+	}
 
 	consulClient, err := api.NewClient(client.config)
 	if err != nil {
@@ -44,8 +65,6 @@ func NewClient(opts ...ClientOptionFunc) (*Client, error) {
 	}
 	client.consulClient = consulClient
 
-	// consulClient.Agent().ServiceRegister(client.register)
-
 	return client, nil
 }
 
@@ -60,6 +79,17 @@ func SetRegister(reg *api.AgentServiceRegistration) ClientOptionFunc {
 	}
 }
 
+// SetHeartbeatInterval set ttl check heartbeat interval, default is half of the shortest ttl
+func SetHeartbeatInterval(interval time.Duration) ClientOptionFunc {
+	return func(client *Client) error {
+		if interval > 0 {
+			client.heartbeatInterval = interval
+		}
+
+		return nil
+	}
+}
+
 // SetAddress set address
 func SetAddress(address string) ClientOptionFunc {
 	return func(client *Client) error {
@@ -128,6 +158,28 @@ func SetToken(token string) ClientOptionFunc {
 	}
 }
 
+// SetNamespace set consul enterprise namespace
+func SetNamespace(namespace string) ClientOptionFunc {
+	return func(client *Client) error {
+		if namespace != "" {
+			client.config.Namespace = namespace
+		}
+
+		return nil
+	}
+}
+
+// SetPartition set consul enterprise admin partition
+func SetPartition(partition string) ClientOptionFunc {
+	return func(client *Client) error {
+		if partition != "" {
+			client.config.Partition = partition
+		}
+
+		return nil
+	}
+}
+
 // KV kv client
 func (client *Client) KV() *api.KV {
 	return client.consulClient.KV()
@@ -140,6 +192,10 @@ func (client *Client) Deregister(serviceName string) error {
 
 // Put put kv
 func (client *Client) Put(key, value string) error {
+	if client.fileDir != "" {
+		return writeFileValue(client.fileDir, key, []byte(value))
+	}
+
 	pair := &api.KVPair{
 		Key:   key,
 		Value: []byte(value),
@@ -149,10 +205,28 @@ func (client *Client) Put(key, value string) error {
 	return err
 }
 
-// Get get kv
+// Get get kv, values with SecretPrefix are decrypted
 func (client *Client) Get(key string) (string, error) {
+	value, err := client.get(key)
+	if err != nil {
+		return "", err
+	}
+
+	return client.decrypt(key, value)
+}
+
+func (client *Client) get(key string) (string, error) {
+	if client.fileDir != "" {
+		value, _, err := readFileValue(client.fileDir, key)
+		return value, err
+	}
+
 	kvPair, _, err := client.KV().Get(key, nil)
 	if err != nil {
+		if value, ok := client.loadSnapshot(key, err); ok {
+			return value, nil
+		}
+
 		return "", err
 	}
 
@@ -160,18 +234,24 @@ func (client *Client) Get(key string) (string, error) {
 		return "", fmt.Errorf(KeyNotExist, key)
 	}
 
+	client.saveSnapshot(key, kvPair.Value)
+
 	return string(kvPair.Value), nil
 }
 
 // Delete delete kv
 func (client *Client) Delete(key string) error {
+	if client.fileDir != "" {
+		return deleteFileValue(client.fileDir, key)
+	}
+
 	_, err := client.KV().Delete(key, nil)
 
 	return err
 }
 
 // GetOrDefault get kv, if not value, return default
-func (client Client) GetOrDefault(key, defaultValue string) string {
+func (client *Client) GetOrDefault(key, defaultValue string) string {
 	value, err := client.Get(key)
 	if err != nil {
 		return defaultValue
@@ -190,8 +270,18 @@ func (client *Client) GetInt(key string) (int, error) {
 	return strconv.Atoi(value)
 }
 
+// GetOrDefaultInt get int, if not value, return default
+func (client *Client) GetOrDefaultInt(key string, defaultValue int) int {
+	value, err := client.GetInt(key)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
 // GetInt64 get int64
-func (client Client) GetInt64(key string) (int64, error) {
+func (client *Client) GetInt64(key string) (int64, error) {
 	value, err := client.Get(key)
 	if err != nil {
 		return 0, err
@@ -201,7 +291,7 @@ func (client Client) GetInt64(key string) (int64, error) {
 }
 
 // GetOrDefaultInt64 get int64, if not value, return default
-func (client Client) GetOrDefaultInt64(key string, defaultValue int64) int64 {
+func (client *Client) GetOrDefaultInt64(key string, defaultValue int64) int64 {
 	value, err := client.GetInt64(key)
 	if err != nil {
 		return defaultValue
@@ -220,6 +310,105 @@ func (client *Client) GetFloat64(key string) (float64, error) {
 	return strconv.ParseFloat(value, 64)
 }
 
+// GetOrDefaultFloat64 get float64, if not value, return default
+func (client *Client) GetOrDefaultFloat64(key string, defaultValue float64) float64 {
+	value, err := client.GetFloat64(key)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
+// GetBool get bool, accepts strconv.ParseBool values and on/off, yes/no
+func (client *Client) GetBool(key string) (bool, error) {
+	value, err := client.Get(key)
+	if err != nil {
+		return false, err
+	}
+
+	return parseBool(value)
+}
+
+// GetOrDefaultBool get bool, if not value, return default
+func (client *Client) GetOrDefaultBool(key string, defaultValue bool) bool {
+	value, err := client.GetBool(key)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
+// GetDuration get duration, eg. 1m30s, a plain integer is seconds
+func (client *Client) GetDuration(key string) (time.Duration, error) {
+	value, err := client.Get(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return parseDuration(strings.TrimSpace(value))
+}
+
+// GetOrDefaultDuration get duration, if not value, return default
+func (client *Client) GetOrDefaultDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := client.GetDuration(key)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
+// GetStringSlice get string slice, value is json array or comma separated
+// eg. ["a","b"] or a, b
+func (client *Client) GetStringSlice(key string) ([]string, error) {
+	value, err := client.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseStringSlice(key, value)
+}
+
+// GetOrDefaultStringSlice get string slice, if not value, return default
+func (client *Client) GetOrDefaultStringSlice(key string, defaultValue []string) []string {
+	value, err := client.GetStringSlice(key)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
+// GetJSON get json value into v
+func (client *Client) GetJSON(key string, v interface{}) error {
+	value, err := client.Get(key)
+	if err != nil {
+		return err
+	}
+
+	if err = json.Unmarshal([]byte(value), v); err != nil {
+		return &DecodeError{Key: key, Err: err}
+	}
+
+	return nil
+}
+
+// GetTOML get toml value into v
+func (client *Client) GetTOML(key string, v interface{}) error {
+	value, err := client.Get(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err = toml.Decode(value, v); err != nil {
+		return &DecodeError{Key: key, Err: err}
+	}
+
+	return nil
+}
+
 // GetHostPort get host port
 func (client *Client) GetHostPort(key string) (string, string, error) {
 	value, err := client.Get(key)
@@ -236,8 +425,16 @@ func (client *Client) GetChildKeys(keyPrefix string) ([]string, error) {
 		keyPrefix += "/"
 	}
 
+	if client.fileDir != "" {
+		return listFileKeys(client.fileDir, keyPrefix)
+	}
+
 	keys, _, err := client.KV().Keys(keyPrefix, "/", nil)
 	if err != nil {
+		if keys, ok := client.loadSnapshotKeys(keyPrefix, err); ok {
+			return keys, nil
+		}
+
 		return nil, err
 	}
 
@@ -264,7 +461,7 @@ func (client *Client) GetChildKeys(keyPrefix string) ([]string, error) {
 	return append(keys[:keyPrefixIndex], keys[keyPrefixIndex+1:]...), nil
 }
 
-//GetChildValues get child all keys' value
+//GetChildValues get child all keys' value, values with SecretPrefix are decrypted
 func (client *Client) GetChildValues(keyPrefix string) (api.KVPairs, error) {
 	keys, err := client.GetChildKeys(keyPrefix)
 	if err != nil {
@@ -321,30 +518,49 @@ func (client *Client) GetMap(keyPrefix string) (map[string]string, error) {
 // FunctionIDsTmpl tmpl
 const FunctionIDsTmpl = "service/go/%s/functionids"
 
-// GetValues get values
+// GetValues get values, fails if one of keys does not exist, values with SecretPrefix are decrypted
 func (client *Client) GetValues(keys []string) (api.KVPairs, error) {
-	txn := make(api.KVTxnOps, len(keys))
-	for i := range keys {
-		txn[i] = &api.KVTxnOp{
-			Verb: api.KVGet,
-			Key:  keys[i],
-		}
-	}
-	success, resp, _, err := client.KV().Txn(txn, nil)
+	kvPairs, err := client.getValues(keys)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(resp.Errors) != 0 {
-		return nil, fmt.Errorf("%v", resp)
+	for i := range kvPairs {
+		value, err := client.decrypt(kvPairs[i].Key, string(kvPairs[i].Value))
+		if err != nil {
+			return nil, err
+		}
+		kvPairs[i].Value = []byte(value)
 	}
 
-	if !success {
-		//TODO
+	return kvPairs, nil
+}
+
+// getValues values as stored, the snapshot keeps them encrypted
+func (client *Client) getValues(keys []string) (api.KVPairs, error) {
+	if len(keys) == 0 {
 		return nil, nil
 	}
 
-	return resp.Results, nil
+	if client.fileDir != "" {
+		return getFileValues(client.fileDir, keys)
+	}
+
+	kvPairs, err := client.Txn().Get(keys...).Commit()
+	if err != nil {
+		if _, ok := err.(*TxnError); !ok && client.snapshotDir != "" {
+			log.Printf("Consul unreachable, use snapshot values: %v, err: %v \r\n", keys, err)
+			return getFileValues(client.snapshotDir, keys)
+		}
+
+		return nil, err
+	}
+
+	for i := range kvPairs {
+		client.saveSnapshot(kvPairs[i].Key, kvPairs[i].Value)
+	}
+
+	return kvPairs, nil
 }
 
 // GetURIAndFunctionIDs get functionids id -> uri
@@ -367,3 +583,31 @@ func (client *Client) GetURIAndFunctionIDs(serviceName string) (map[string]strin
 
 	return functionIDs, nil
 }
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "on", "yes", "y":
+		return true, nil
+	case "off", "no", "n":
+		return false, nil
+	}
+
+	return strconv.ParseBool(strings.TrimSpace(value))
+}
+
+// parseStringSlice splitList without empty items
+func parseStringSlice(key, value string) ([]string, error) {
+	items, err := splitList(value)
+	if err != nil {
+		return nil, &DecodeError{Key: key, Err: err}
+	}
+
+	result := make([]string, 0, len(items))
+	for i := range items {
+		if items[i] != "" {
+			result = append(result, items[i])
+		}
+	}
+
+	return result, nil
+}
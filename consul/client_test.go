@@ -1,8 +1,11 @@
 package consul
 
 import (
+	"io/ioutil"
+	"os"
 	"path"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -172,3 +175,85 @@ func TestConsul(t *testing.T) {
 		})
 	})
 }
+
+func TestTypedGetters(t *testing.T) {
+	Convey("typed getters test", t, func() {
+		dir, err := ioutil.TempDir("", "consul-getters")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		client, err := NewClient(SetFileDir(dir))
+		So(err, ShouldBeNil)
+
+		prefix := "service/go/test/getters"
+		kv := map[string]string{
+			"bool":      "true",
+			"on":        "on",
+			"bad":       "maybe",
+			"duration":  "1m30s",
+			"seconds":   "30",
+			"comma":     "a, b,,c",
+			"jsonslice": `["a","b"]`,
+			"json":      `{"Name":"jream","Age":18}`,
+			"toml":      "Name = \"jream\"\nAge = 18",
+		}
+		for k, v := range kv {
+			So(client.Put(path.Join(prefix, k), v), ShouldBeNil)
+		}
+
+		Convey("bool", func() {
+			value, err := client.GetBool(path.Join(prefix, "bool"))
+			So(err, ShouldBeNil)
+			So(value, ShouldBeTrue)
+
+			value, err = client.GetBool(path.Join(prefix, "on"))
+			So(err, ShouldBeNil)
+			So(value, ShouldBeTrue)
+
+			_, err = client.GetBool(path.Join(prefix, "bad"))
+			So(err, ShouldNotBeNil)
+			So(client.GetOrDefaultBool(path.Join(prefix, "none"), true), ShouldBeTrue)
+		})
+
+		Convey("duration", func() {
+			value, err := client.GetDuration(path.Join(prefix, "duration"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 90*time.Second)
+
+			value, err = client.GetDuration(path.Join(prefix, "seconds"))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 30*time.Second)
+
+			So(client.GetOrDefaultDuration(path.Join(prefix, "bad"), time.Second), ShouldEqual, time.Second)
+		})
+
+		Convey("string slice", func() {
+			value, err := client.GetStringSlice(path.Join(prefix, "comma"))
+			So(err, ShouldBeNil)
+			So(value, ShouldResemble, []string{"a", "b", "c"})
+
+			value, err = client.GetStringSlice(path.Join(prefix, "jsonslice"))
+			So(err, ShouldBeNil)
+			So(value, ShouldResemble, []string{"a", "b"})
+
+			So(client.GetOrDefaultStringSlice(path.Join(prefix, "none"), []string{"d"}), ShouldResemble, []string{"d"})
+		})
+
+		Convey("json & toml", func() {
+			var v struct {
+				Name string
+				Age  int
+			}
+			So(client.GetJSON(path.Join(prefix, "json"), &v), ShouldBeNil)
+			So(v.Name, ShouldEqual, "jream")
+			So(v.Age, ShouldEqual, 18)
+
+			So(client.GetTOML(path.Join(prefix, "toml"), &v), ShouldBeNil)
+			So(v.Name, ShouldEqual, "jream")
+
+			err := client.GetJSON(path.Join(prefix, "toml"), &v)
+			_, ok := err.(*DecodeError)
+			So(ok, ShouldBeTrue)
+		})
+	})
+}
@@ -51,14 +51,29 @@ type RegistryConsul struct {
 
 // GetKafkas get kafka addrs
 func (client *Client) GetKafkas(clusterName string) ([]string, string, error) {
+	return LoadKafkas(client, clusterName)
+}
+
+// GetZookeepers get zookeeper addrs
+func (client *Client) GetZookeepers(clusterName string) ([]string, string, error) {
+	return LoadZookeepers(client, clusterName)
+}
+
+// GetConsulAddrs get consul addrs
+func (client *Client) GetConsulAddrs(clusterName string) ([]string, error) {
+	return LoadConsulAddrs(client, clusterName)
+}
+
+// LoadKafkas load kafka addrs from source
+func LoadKafkas(source ConfigSource, clusterName string) ([]string, string, error) {
 	key := path.Join(Kafka, clusterName)
-	buf, err := client.Get(key)
+	buf, err := source.Get(key)
 	var brokers KafkaBrokers
 	if err != nil {
 		return nil, constant.EmptyStr, err
 	}
 
-	log.Printf("Load Kafka Config: %v \n%v\n%v\n%v\n\n", key, SeparatorStart, buf, SeparatorEnd)
+	log.Printf("Load Kafka Config: %v \n", key)
 
 	_, err = toml.Decode(buf, &brokers)
 	if err != nil {
@@ -68,16 +83,16 @@ func (client *Client) GetKafkas(clusterName string) ([]string, string, error) {
 	return brokers.Brokers, brokers.Topic, nil
 }
 
-// GetZookeepers get zookeeper addrs
-func (client *Client) GetZookeepers(clusterName string) ([]string, string, error) {
+// LoadZookeepers load zookeeper addrs from source
+func LoadZookeepers(source ConfigSource, clusterName string) ([]string, string, error) {
 	key := path.Join(Zookeeper, clusterName)
-	buf, err := client.Get(path.Join(Zookeeper, clusterName))
+	buf, err := source.Get(key)
 	var zk KafkaZookeeper
 	if err != nil {
 		return nil, constant.EmptyStr, err
 	}
 
-	log.Printf("Load Zookeeper Config: %v \n%v\n%v\n%v\n\n", key, SeparatorStart, buf, SeparatorEnd)
+	log.Printf("Load Zookeeper Config: %v \n", key)
 
 	_, err = toml.Decode(buf, &zk)
 	if err != nil {
@@ -87,16 +102,16 @@ func (client *Client) GetZookeepers(clusterName string) ([]string, string, error
 	return zk.Addrs, zk.Zkroot, nil
 }
 
-// GetConsulAddrs get consul addrs
-func (client *Client) GetConsulAddrs(clusterName string) ([]string, error) {
+// LoadConsulAddrs load consul addrs from source
+func LoadConsulAddrs(source ConfigSource, clusterName string) ([]string, error) {
 	key := path.Join(Consul, clusterName)
-	buf, err := client.Get(key)
+	buf, err := source.Get(key)
 	var rc RegistryConsul
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("Load Consul Config: %v \n%v\n%v\n%v\n\n", key, SeparatorStart, buf, SeparatorEnd)
+	log.Printf("Load Consul Config: %v \n", key)
 
 	_, err = toml.Decode(buf, &rc)
 	if err != nil {
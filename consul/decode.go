@@ -0,0 +1,493 @@
+package consul
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/consul/api"
+)
+
+const _decodeTag = "consul"
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// DecodeError decode error with the failing key
+type DecodeError struct {
+	Key string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("consul: decode key %q: %v", e.Key, e.Err)
+}
+
+// kvNode one level of kv tree
+type kvNode struct {
+	key      string
+	value    []byte
+	hasValue bool
+	children map[string]*kvNode
+}
+
+func newKVNode(key string) *kvNode {
+	return &kvNode{
+		key:      key,
+		children: make(map[string]*kvNode),
+	}
+}
+
+// Decode decode kv tree under prefix into v
+// eg. prefix=conn/redis/config
+//
+//	conn/redis/config/master/1/db    0
+//	conn/redis/config/master/1/ip    172.16.9.221
+//	conn/redis/config/poolsize       10
+//	conn/redis/config/timeout        3s
+//
+//	v=&struct {
+//		Master   []struct{ DB int; IP string }
+//		PoolSize int `consul:"poolsize"`
+//		Timeout  time.Duration
+//	}
+func (client *Client) Decode(prefix string, v interface{}) error {
+	kvPairs, err := client.list(prefix)
+	if err != nil {
+		return err
+	}
+
+	return decodeKVPairs(prefix, kvPairs, v, client.SecretKey)
+}
+
+// list kv pairs under prefix, from the file dir, then consul, then the snapshot dir like GetValues
+func (client *Client) list(prefix string) (api.KVPairs, error) {
+	if client.fileDir != "" {
+		return listFileKVPairs(client.fileDir, prefix)
+	}
+
+	kvPairs, _, err := client.KV().List(prefix, nil)
+	if err != nil {
+		if kvPairs, ok := client.loadSnapshotKVPairs(prefix, err); ok {
+			return kvPairs, nil
+		}
+
+		return nil, err
+	}
+
+	for i := range kvPairs {
+		if !strings.HasSuffix(kvPairs[i].Key, "/") {
+			client.saveSnapshot(kvPairs[i].Key, kvPairs[i].Value)
+		}
+	}
+
+	return kvPairs, nil
+}
+
+// DecodeKVPairs decode kv pairs under prefix into v
+// nested key prefixes map to nested structs, slices and maps
+// struct fields match keys by `consul:"name"` tag or field name, case insensitive
+// a struct stored as one key is decoded as toml
+// strings with SecretPrefix are decrypted with LoadSecretKey
+func DecodeKVPairs(prefix string, kvPairs api.KVPairs, v interface{}) error {
+	return decodeKVPairs(prefix, kvPairs, v, LoadSecretKey)
+}
+
+func decodeKVPairs(prefix string, kvPairs api.KVPairs, v interface{}, secretKey func() (string, error)) error {
+	prefix = strings.TrimSuffix(prefix, "/")
+	root := newKVNode(prefix)
+
+	for _, kvPair := range kvPairs {
+		if kvPair.Key == prefix {
+			root.value, root.hasValue = kvPair.Value, true
+			continue
+		}
+
+		if !strings.HasPrefix(kvPair.Key, prefix+"/") {
+			continue
+		}
+
+		node := root
+		for _, name := range strings.Split(strings.TrimPrefix(kvPair.Key, prefix+"/"), "/") {
+			if name == "" {
+				continue
+			}
+
+			child, ok := node.children[name]
+			if !ok {
+				child = newKVNode(node.key + "/" + name)
+				node.children[name] = child
+			}
+			node = child
+		}
+
+		// folder key, eg. conn/redis/
+		if node != root && !strings.HasSuffix(kvPair.Key, "/") {
+			node.value, node.hasValue = kvPair.Value, true
+		}
+	}
+
+	return decodeRoot(root, v, secretKey)
+}
+
+// DecodeValue decode the value of one key into v, structs and maps are decoded as toml
+// the value and strings with SecretPrefix are decrypted with LoadSecretKey
+func DecodeValue(key string, value []byte, v interface{}) error {
+	return decodeValue(key, value, v, LoadSecretKey)
+}
+
+// DecodeSourceValue decode the value of one key read from source into v, as DecodeValue
+// secrets are decrypted with the key of source if it is a SecretKeySource, eg. Client, else LoadSecretKey
+func DecodeSourceValue(source ConfigSource, key string, value []byte, v interface{}) error {
+	secretKey := LoadSecretKey
+	if keySource, ok := source.(SecretKeySource); ok {
+		secretKey = keySource.SecretKey
+	}
+
+	return decodeValue(key, value, v, secretKey)
+}
+
+func decodeValue(key string, value []byte, v interface{}, secretKey func() (string, error)) error {
+	if IsSecret(string(value)) {
+		k, err := secretKey()
+		if err != nil {
+			return &DecodeError{Key: key, Err: err}
+		}
+
+		decrypted, err := DecryptSecret(string(value), k)
+		if err != nil {
+			return &DecodeError{Key: key, Err: err}
+		}
+		value = []byte(decrypted)
+	}
+
+	return decodeRoot(&kvNode{
+		key:      key,
+		value:    value,
+		hasValue: true,
+	}, v, secretKey)
+}
+
+func decodeRoot(root *kvNode, v interface{}, secretKey func() (string, error)) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &DecodeError{Key: root.key, Err: fmt.Errorf("decode target must be non-nil pointer, got %T", v)}
+	}
+
+	if err := decodeNode(root, rv.Elem()); err != nil {
+		return err
+	}
+
+	if err := decryptValue(rv.Elem(), secretKey); err != nil {
+		return &DecodeError{Key: root.key, Err: err}
+	}
+
+	return nil
+}
+
+func decodeNode(node *kvNode, rv reflect.Value) error {
+	if !node.hasValue && len(node.children) == 0 {
+		return nil
+	}
+
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+
+		return decodeNode(node, rv.Elem())
+	}
+
+	if node.hasValue && rv.CanAddr() && rv.Addr().Type().Implements(textUnmarshalerType) {
+		if err := rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(node.value); err != nil {
+			return &DecodeError{Key: node.key, Err: err}
+		}
+
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		return decodeStruct(node, rv)
+	case reflect.Map:
+		return decodeMap(node, rv)
+	case reflect.Slice:
+		return decodeSlice(node, rv)
+	}
+
+	if !node.hasValue {
+		return &DecodeError{Key: node.key, Err: fmt.Errorf("cannot decode dir into %v", rv.Type())}
+	}
+
+	if err := decodeScalar(string(node.value), rv); err != nil {
+		return &DecodeError{Key: node.key, Err: err}
+	}
+
+	return nil
+}
+
+func decodeStruct(node *kvNode, rv reflect.Value) error {
+	// per-instance toml blob
+	if node.hasValue && len(node.children) == 0 {
+		return decodeTOML(node, rv)
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Tag.Get(_decodeTag)
+		if name == "-" {
+			continue
+		}
+
+		// embedded struct shares the same level
+		if field.Anonymous && name == "" && indirect(field.Type).Kind() == reflect.Struct {
+			if err := decodeNode(&kvNode{key: node.key, children: node.children}, rv.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		child := node.child(name)
+		if child == nil {
+			continue
+		}
+
+		if err := decodeNode(child, rv.Field(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func indirect(rt reflect.Type) reflect.Type {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+
+	return rt
+}
+
+// child exact name first, then case insensitive, the smallest name wins when several match
+func (node *kvNode) child(name string) *kvNode {
+	if child, ok := node.children[name]; ok {
+		return child
+	}
+
+	var matched string
+	for childName := range node.children {
+		if strings.EqualFold(childName, name) && (matched == "" || childName < matched) {
+			matched = childName
+		}
+	}
+
+	if matched == "" {
+		return nil
+	}
+
+	return node.children[matched]
+}
+
+func decodeMap(node *kvNode, rv reflect.Value) error {
+	if node.hasValue && len(node.children) == 0 {
+		return decodeTOML(node, rv)
+	}
+
+	rt := rv.Type()
+	if rt.Key().Kind() != reflect.String {
+		return &DecodeError{Key: node.key, Err: fmt.Errorf("map key must be string, got %v", rt.Key())}
+	}
+
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMap(rt))
+	}
+
+	for name, child := range node.children {
+		elem := reflect.New(rt.Elem()).Elem()
+		if err := decodeNode(child, elem); err != nil {
+			return err
+		}
+
+		rv.SetMapIndex(reflect.ValueOf(name).Convert(rt.Key()), elem)
+	}
+
+	return nil
+}
+
+func decodeSlice(node *kvNode, rv reflect.Value) error {
+	rt := rv.Type()
+	if len(node.children) == 0 {
+		// []byte raw value
+		if rt.Elem().Kind() == reflect.Uint8 {
+			rv.SetBytes(append([]byte(nil), node.value...))
+			return nil
+		}
+
+		items, err := splitList(string(node.value))
+		if err != nil {
+			return &DecodeError{Key: node.key, Err: err}
+		}
+
+		slice := reflect.MakeSlice(rt, len(items), len(items))
+		for i := range items {
+			if err = decodeScalar(items[i], slice.Index(i)); err != nil {
+				return &DecodeError{Key: node.key, Err: err}
+			}
+		}
+		rv.Set(slice)
+
+		return nil
+	}
+
+	names := sortedChildNames(node)
+	slice := reflect.MakeSlice(rt, len(names), len(names))
+	for i, name := range names {
+		if err := decodeNode(node.children[name], slice.Index(i)); err != nil {
+			return err
+		}
+	}
+	rv.Set(slice)
+
+	return nil
+}
+
+// sortedChildNames numeric order when all names are numbers, eg. 1,2,10
+func sortedChildNames(node *kvNode) []string {
+	names := make([]string, 0, len(node.children))
+	numeric := true
+	for name := range node.children {
+		names = append(names, name)
+		if _, err := strconv.Atoi(name); err != nil {
+			numeric = false
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if numeric {
+			a, _ := strconv.Atoi(names[i])
+			b, _ := strconv.Atoi(names[j])
+			return a < b
+		}
+
+		return names[i] < names[j]
+	})
+
+	return names
+}
+
+// splitList json array or comma separated list
+func splitList(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(value, "[") {
+		var items []interface{}
+		if err := json.Unmarshal([]byte(value), &items); err != nil {
+			return nil, err
+		}
+
+		result := make([]string, len(items))
+		for i := range items {
+			result[i] = fmt.Sprint(items[i])
+		}
+
+		return result, nil
+	}
+
+	items := strings.Split(value, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+
+	return items, nil
+}
+
+func decodeTOML(node *kvNode, rv reflect.Value) error {
+	if _, err := toml.Decode(string(node.value), rv.Addr().Interface()); err != nil {
+		return &DecodeError{Key: node.key, Err: err}
+	}
+
+	return nil
+}
+
+// decodeScalar string, bool, int, uint, float, duration
+// bool accepts on/off and yes/no like Client.GetBool
+// duration accepts "3s" or a plain number of seconds
+func decodeScalar(value string, rv reflect.Value) error {
+	value = strings.TrimSpace(value)
+
+	if rv.Type() == durationType {
+		d, err := parseDuration(value)
+		if err != nil {
+			return err
+		}
+
+		rv.SetInt(int64(d))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(value)
+	case reflect.Bool:
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			return fmt.Errorf("cannot decode into %v", rv.Type())
+		}
+		rv.Set(reflect.ValueOf(value))
+	default:
+		return fmt.Errorf("cannot decode into %v", rv.Type())
+	}
+
+	return nil
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,155 @@
+package consul
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testDecodeNode struct {
+	DB   int
+	IP   string
+	Port string `consul:"port"`
+}
+
+type Base struct {
+	Name string
+}
+
+type testDecodeConfig struct {
+	Base
+	PoolSize  int64 `consul:"poolsize"`
+	IsCluster bool
+	Timeout   time.Duration
+	Interval  time.Duration
+	Ratio     float64
+	Tags      []string
+	Ports     []int
+	Master    []testDecodeNode
+	Slave     map[string]*testDecodeNode
+	MySQL     mysqlConfig
+	Ignore    string `consul:"-"`
+}
+
+type mysqlConfig struct {
+	InstanceName string
+	DBName       string
+	ReadWrite    struct {
+		Server string
+		Port   string
+	}
+}
+
+func testKVPairs(kv ...string) api.KVPairs {
+	kvPairs := make(api.KVPairs, 0, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		kvPairs = append(kvPairs, &api.KVPair{Key: kv[i], Value: []byte(kv[i+1])})
+	}
+
+	return kvPairs
+}
+
+func TestDecode(t *testing.T) {
+	Convey("decode test", t, func() {
+		Convey("nested struct slice map", func() {
+			kvPairs := testKVPairs(
+				"conn/redis/config/", "",
+				"conn/redis/config/name", "crawler",
+				"conn/redis/config/poolsize", "10",
+				"conn/redis/config/iscluster", "true",
+				"conn/redis/config/timeout", "3s",
+				"conn/redis/config/interval", "30",
+				"conn/redis/config/ratio", "0.6",
+				"conn/redis/config/tags", "a, b,c",
+				"conn/redis/config/ports", "[6379, 6380]",
+				"conn/redis/config/master/10/db", "1",
+				"conn/redis/config/master/2/db", "0",
+				"conn/redis/config/master/2/ip", "172.16.9.221",
+				"conn/redis/config/master/2/port", "6379",
+				"conn/redis/config/slave/s1/ip", "172.16.9.222",
+				"conn/redis/config/mysql", "InstanceName = \"BGCrawler\"\nDBName = \"crawler\"\n[readwrite]\nServer = \"127.0.0.1\"\nPort = \"3306\"",
+				"conn/redis/config/ignore", "x",
+				"conn/redis/config-test/name", "other",
+			)
+
+			var config testDecodeConfig
+			err := DecodeKVPairs("conn/redis/config", kvPairs, &config)
+			So(err, ShouldBeNil)
+			So(config.Name, ShouldEqual, "crawler")
+			So(config.PoolSize, ShouldEqual, 10)
+			So(config.IsCluster, ShouldBeTrue)
+			So(config.Timeout, ShouldEqual, 3*time.Second)
+			So(config.Interval, ShouldEqual, 30*time.Second)
+			So(config.Ratio, ShouldEqual, 0.6)
+			So(config.Tags, ShouldResemble, []string{"a", "b", "c"})
+			So(config.Ports, ShouldResemble, []int{6379, 6380})
+			So(len(config.Master), ShouldEqual, 2)
+			So(config.Master[0].IP, ShouldEqual, "172.16.9.221")
+			So(config.Master[0].Port, ShouldEqual, "6379")
+			So(config.Master[1].DB, ShouldEqual, 1)
+			So(config.Slave["s1"].IP, ShouldEqual, "172.16.9.222")
+			So(config.MySQL.InstanceName, ShouldEqual, "BGCrawler")
+			So(config.MySQL.ReadWrite.Port, ShouldEqual, "3306")
+			So(config.Ignore, ShouldBeEmpty)
+		})
+
+		Convey("error names key", func() {
+			var config testDecodeConfig
+			err := DecodeKVPairs("conn/redis/config", testKVPairs("conn/redis/config/master/1/db", "zero"), &config)
+			So(err, ShouldNotBeNil)
+			decodeErr, ok := err.(*DecodeError)
+			So(ok, ShouldBeTrue)
+			So(decodeErr.Key, ShouldEqual, "conn/redis/config/master/1/db")
+		})
+
+		Convey("bool like GetBool", func() {
+			var config testDecodeConfig
+			for _, value := range []string{"on", "Yes", "y", "1", "true"} {
+				config.IsCluster = false
+				err := DecodeKVPairs("conn/redis/config", testKVPairs("conn/redis/config/iscluster", value), &config)
+				So(err, ShouldBeNil)
+				So(config.IsCluster, ShouldBeTrue)
+			}
+
+			err := DecodeKVPairs("conn/redis/config", testKVPairs("conn/redis/config/iscluster", "off"), &config)
+			So(err, ShouldBeNil)
+			So(config.IsCluster, ShouldBeFalse)
+		})
+
+		Convey("case insensitive names", func() {
+			// exact match first, otherwise the smallest name
+			for i := 0; i < 10; i++ {
+				var config testDecodeConfig
+				err := DecodeKVPairs("conn/redis/config", testKVPairs(
+					"conn/redis/config/name", "lower",
+					"conn/redis/config/NAME", "upper",
+					"conn/redis/config/Name", "exact",
+					"conn/redis/config/RATIO", "0.1",
+					"conn/redis/config/ratio", "0.2",
+				), &config)
+				So(err, ShouldBeNil)
+				So(config.Name, ShouldEqual, "exact")
+				So(config.Ratio, ShouldEqual, 0.1)
+			}
+		})
+
+		Convey("not pointer", func() {
+			var config testDecodeConfig
+			So(DecodeKVPairs("conn/redis/config", nil, config), ShouldNotBeNil)
+		})
+
+		Convey("decode toml value", func() {
+			var config mysqlConfig
+			err := DecodeValue("conn/v1/mysql/BGCrawler", []byte(mysqlToml), &config)
+			So(err, ShouldBeNil)
+			So(config.DBName, ShouldEqual, "crawler")
+			So(config.ReadWrite.Server, ShouldEqual, "127.0.0.1")
+
+			err = DecodeValue("conn/v1/mysql/BGCrawler", []byte("DBName = "), &config)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "conn/v1/mysql/BGCrawler")
+		})
+	})
+}
@@ -0,0 +1,206 @@
+package consul
+
+import (
+	"context"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	_defaultDiscoveryWaitTime   = 5 * time.Minute
+	_defaultDiscoveryRetryDelay = time.Second
+)
+
+// Healthy healthy instances of service, tag is optional
+func (client *Client) Healthy(service, tag string) ([]*api.ServiceEntry, error) {
+	entries, _, err := client.consulClient.Health().Service(service, tag, true, nil)
+	return entries, err
+}
+
+// ServiceCache healthy instances of one service, kept fresh by blocking query
+type ServiceCache struct {
+	client  *Client
+	service string
+	tag     string
+	picker  Picker
+	entries []*api.ServiceEntry
+	index   uint64
+	cancel  context.CancelFunc
+	done    chan struct{}
+	rwMutex sync.RWMutex
+}
+
+// NewServiceCache load healthy instances, then watch the catalog until ctx done or Stop
+// picker is RoundRobin when nil
+func (client *Client) NewServiceCache(ctx context.Context, service, tag string, picker Picker) (*ServiceCache, error) {
+	if picker == nil {
+		picker = RoundRobin()
+	}
+
+	entries, meta, err := client.consulClient.Health().Service(service, tag, true, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cache := &ServiceCache{
+		client:  client,
+		service: service,
+		tag:     tag,
+		picker:  picker,
+		entries: entries,
+		index:   meta.LastIndex,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	go cache.watch(ctx)
+
+	return cache, nil
+}
+
+func (cache *ServiceCache) watch(ctx context.Context) {
+	defer close(cache.done)
+
+	health := cache.client.consulClient.Health()
+	for {
+		opts := &api.QueryOptions{
+			WaitIndex: cache.index,
+			WaitTime:  _defaultDiscoveryWaitTime,
+		}
+
+		entries, meta, err := health.Service(cache.service, cache.tag, true, opts.WithContext(ctx))
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			log.Printf("Failed on consul discovery, service: %v, err: %v \r\n", cache.service, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(_defaultDiscoveryRetryDelay):
+			}
+			continue
+		}
+
+		// index went backwards, consul docs ask to reset
+		index := meta.LastIndex
+		if index < cache.index {
+			index = 0
+		}
+
+		cache.rwMutex.Lock()
+		cache.entries = entries
+		cache.index = index
+		cache.rwMutex.Unlock()
+	}
+}
+
+// Entries healthy instances
+func (cache *ServiceCache) Entries() []*api.ServiceEntry {
+	cache.rwMutex.RLock()
+	entries := cache.entries
+	cache.rwMutex.RUnlock()
+
+	return entries
+}
+
+// Pick pick one healthy instance
+func (cache *ServiceCache) Pick() (*api.ServiceEntry, error) {
+	return cache.picker.Pick(cache.Entries())
+}
+
+// Addr pick one healthy instance, return host:port
+func (cache *ServiceCache) Addr() (string, error) {
+	entry, err := cache.Pick()
+	if err != nil {
+		return "", err
+	}
+
+	return EntryAddr(entry), nil
+}
+
+// Stop stop watching catalog
+func (cache *ServiceCache) Stop() {
+	cache.cancel()
+	<-cache.done
+}
+
+// EntryAddr service address, node address when service address is empty
+func EntryAddr(entry *api.ServiceEntry) string {
+	host := entry.Service.Address
+	if host == "" && entry.Node != nil {
+		host = entry.Node.Address
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(entry.Service.Port))
+}
+
+// Discovery lazily created service caches, by service name
+type Discovery struct {
+	ctx       context.Context
+	client    *Client
+	tag       string
+	newPicker func() Picker
+	caches    map[string]*ServiceCache
+	mutex     sync.Mutex
+}
+
+// NewDiscovery new discovery, every service gets its own picker from newPicker
+func (client *Client) NewDiscovery(ctx context.Context, tag string, newPicker func() Picker) *Discovery {
+	if newPicker == nil {
+		newPicker = RoundRobin
+	}
+
+	return &Discovery{
+		ctx:       ctx,
+		client:    client,
+		tag:       tag,
+		newPicker: newPicker,
+		caches:    make(map[string]*ServiceCache),
+	}
+}
+
+// Cache get or create service cache
+func (d *Discovery) Cache(service string) (*ServiceCache, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if cache, ok := d.caches[service]; ok {
+		return cache, nil
+	}
+
+	cache, err := d.client.NewServiceCache(d.ctx, service, d.tag, d.newPicker())
+	if err != nil {
+		return nil, err
+	}
+
+	d.caches[service] = cache
+	return cache, nil
+}
+
+// Addr pick one healthy instance of service, return host:port
+func (d *Discovery) Addr(service string) (string, error) {
+	cache, err := d.Cache(service)
+	if err != nil {
+		return "", err
+	}
+
+	return cache.Addr()
+}
+
+// Stop stop all service caches
+func (d *Discovery) Stop() {
+	d.mutex.Lock()
+	for service, cache := range d.caches {
+		cache.Stop()
+		delete(d.caches, service)
+	}
+	d.mutex.Unlock()
+}
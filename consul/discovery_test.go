@@ -0,0 +1,169 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeCatalog fake consul health service endpoint with blocking query
+type fakeCatalog struct {
+	mutex   sync.Mutex
+	index   uint64
+	entries []*api.ServiceEntry
+	changed chan struct{}
+}
+
+func newFakeCatalog(entries ...*api.ServiceEntry) (*fakeCatalog, *httptest.Server) {
+	catalog := &fakeCatalog{
+		index:   1,
+		entries: entries,
+		changed: make(chan struct{}),
+	}
+
+	return catalog, httptest.NewServer(catalog)
+}
+
+func (catalog *fakeCatalog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/v1/health/service/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	catalog.mutex.Lock()
+	index, changed := catalog.index, catalog.changed
+	catalog.mutex.Unlock()
+
+	if wait, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); wait >= index {
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+
+	catalog.mutex.Lock()
+	defer catalog.mutex.Unlock()
+
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(catalog.index, 10))
+	json.NewEncoder(w).Encode(catalog.entries)
+}
+
+func (catalog *fakeCatalog) set(entries ...*api.ServiceEntry) {
+	catalog.mutex.Lock()
+	catalog.entries = entries
+	catalog.index++
+	close(catalog.changed)
+	catalog.changed = make(chan struct{})
+	catalog.mutex.Unlock()
+}
+
+func testEntry(addr string, port, weight int) *api.ServiceEntry {
+	return &api.ServiceEntry{
+		Node: &api.Node{Address: "10.0.0.1"},
+		Service: &api.AgentService{
+			Service: "pusher",
+			Address: addr,
+			Port:    port,
+			Weights: api.AgentWeights{Passing: weight},
+		},
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	Convey("discovery test", t, func() {
+		catalog, server := newFakeCatalog(testEntry("127.0.0.1", 8001, 1), testEntry("", 8002, 1))
+		defer server.Close()
+
+		client, err := NewClient(SetAddress(strings.TrimPrefix(server.URL, "http://")))
+		So(err, ShouldBeNil)
+
+		Convey("healthy", func() {
+			entries, err := client.Healthy("pusher", "")
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 2)
+			So(EntryAddr(entries[1]), ShouldEqual, "10.0.0.1:8002")
+		})
+
+		Convey("service cache", func() {
+			cache, err := client.NewServiceCache(context.Background(), "pusher", "", RoundRobin())
+			So(err, ShouldBeNil)
+			defer cache.Stop()
+
+			addr, err := cache.Addr()
+			So(err, ShouldBeNil)
+			So(addr, ShouldEqual, "127.0.0.1:8001")
+			addr, err = cache.Addr()
+			So(err, ShouldBeNil)
+			So(addr, ShouldEqual, "10.0.0.1:8002")
+
+			catalog.set(testEntry("127.0.0.1", 8003, 1))
+			time.Sleep(50 * time.Millisecond)
+			So(len(cache.Entries()), ShouldEqual, 1)
+			addr, err = cache.Addr()
+			So(err, ShouldBeNil)
+			So(addr, ShouldEqual, "127.0.0.1:8003")
+
+			catalog.set()
+			time.Sleep(50 * time.Millisecond)
+			_, err = cache.Addr()
+			So(err, ShouldEqual, ErrNoInstance)
+		})
+
+		Convey("discovery", func() {
+			d := client.NewDiscovery(context.Background(), "", Random)
+			defer d.Stop()
+
+			addr, err := d.Addr("pusher")
+			So(err, ShouldBeNil)
+			So(addr, ShouldNotBeEmpty)
+		})
+	})
+}
+
+func TestPicker(t *testing.T) {
+	Convey("picker test", t, func() {
+		entries := []*api.ServiceEntry{
+			testEntry("127.0.0.1", 8001, 1),
+			testEntry("127.0.0.1", 8002, 0),
+			testEntry("127.0.0.1", 8003, 98),
+		}
+
+		Convey("empty", func() {
+			for _, picker := range []Picker{RoundRobin(), Random(), Weighted()} {
+				_, err := picker.Pick(nil)
+				So(err, ShouldEqual, ErrNoInstance)
+			}
+		})
+
+		Convey("round robin", func() {
+			picker := RoundRobin()
+			for i := 0; i < 6; i++ {
+				entry, err := picker.Pick(entries)
+				So(err, ShouldBeNil)
+				So(entry, ShouldEqual, entries[i%3])
+			}
+		})
+
+		Convey("weighted", func() {
+			picker := Weighted()
+			hits := make(map[int]int)
+			for i := 0; i < 1000; i++ {
+				entry, err := picker.Pick(entries)
+				So(err, ShouldBeNil)
+				hits[entry.Service.Port]++
+			}
+			So(hits[8003], ShouldBeGreaterThan, hits[8001]+hits[8002])
+		})
+	})
+}
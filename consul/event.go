@@ -0,0 +1,116 @@
+package consul
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// EventType kv change type
+type EventType int
+
+const (
+	// Added key added
+	Added EventType = iota + 1
+	// Modified key modified
+	Modified
+	// Deleted key deleted
+	Deleted
+)
+
+func (eventType EventType) String() string {
+	switch eventType {
+	case Added:
+		return "added"
+	case Modified:
+		return "modified"
+	case Deleted:
+		return "deleted"
+	}
+
+	return "unknown"
+}
+
+// Event kv change event
+// Pair is the current pair, nil when deleted
+// Prev is the previous pair, nil when added
+type Event struct {
+	Type EventType
+	Key  string
+	Pair *api.KVPair
+	Prev *api.KVPair
+}
+
+// Diff compute change events from prev to next by ModifyIndex, sorted by key
+func Diff(prev, next api.KVPairs) []Event {
+	prevPairs := make(map[string]*api.KVPair, len(prev))
+	for i := range prev {
+		prevPairs[prev[i].Key] = prev[i]
+	}
+
+	return diff(prevPairs, next)
+}
+
+func diff(prevPairs map[string]*api.KVPair, next api.KVPairs) []Event {
+	var events []Event
+	nextKeys := make(map[string]struct{}, len(next))
+	for i := range next {
+		nextKeys[next[i].Key] = struct{}{}
+
+		p, ok := prevPairs[next[i].Key]
+		if !ok {
+			events = append(events, Event{Type: Added, Key: next[i].Key, Pair: next[i]})
+			continue
+		}
+
+		if p.ModifyIndex != next[i].ModifyIndex {
+			events = append(events, Event{Type: Modified, Key: next[i].Key, Pair: next[i], Prev: p})
+		}
+	}
+
+	for key, p := range prevPairs {
+		if _, ok := nextKeys[key]; !ok {
+			events = append(events, Event{Type: Deleted, Key: key, Prev: p})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Key < events[j].Key
+	})
+
+	return events
+}
+
+// AddKeyPrefixEvents watch dir, handle is called with added, modified and deleted keys
+// with WithInitial, the initial snapshot is delivered as Added events
+func (w *Watcher) AddKeyPrefixEvents(prefix string, handle func(uint64, []Event), opts ...WatchOptionFunc) {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	options := newWatchOptions(opts)
+	skip := !options.initial
+	prevPairs := make(map[string]*api.KVPair)
+	w.add(map[string]interface{}{
+		"type":   "keyprefix",
+		"prefix": prefix,
+	}, func(idx uint64, raw interface{}) {
+		v, _ := raw.(api.KVPairs)
+		events := diff(prevPairs, v)
+
+		prevPairs = make(map[string]*api.KVPair, len(v))
+		for i := range v {
+			prevPairs[v[i].Key] = v[i]
+		}
+
+		if skip {
+			skip = false
+			return
+		}
+
+		if len(events) > 0 {
+			handle(idx, events)
+		}
+	})
+}
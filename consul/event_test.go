@@ -0,0 +1,130 @@
+package consul
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiff(t *testing.T) {
+	Convey("diff test", t, func() {
+		prev := api.KVPairs{
+			{Key: "conn/v1/mysql/a", ModifyIndex: 1},
+			{Key: "conn/v1/mysql/b", ModifyIndex: 2},
+			{Key: "conn/v1/mysql/c", ModifyIndex: 3},
+		}
+
+		Convey("initial snapshot", func() {
+			events := Diff(nil, prev)
+			So(len(events), ShouldEqual, 3)
+			for i := range events {
+				So(events[i].Type, ShouldEqual, Added)
+				So(events[i].Prev, ShouldBeNil)
+			}
+		})
+
+		Convey("added modified deleted", func() {
+			next := api.KVPairs{
+				{Key: "conn/v1/mysql/a", ModifyIndex: 1},
+				{Key: "conn/v1/mysql/b", ModifyIndex: 5},
+				{Key: "conn/v1/mysql/d", ModifyIndex: 6},
+			}
+
+			events := Diff(prev, next)
+			So(len(events), ShouldEqual, 3)
+			So(events[0].Key, ShouldEqual, "conn/v1/mysql/b")
+			So(events[0].Type, ShouldEqual, Modified)
+			So(events[0].Prev.ModifyIndex, ShouldEqual, 2)
+			So(events[1].Key, ShouldEqual, "conn/v1/mysql/c")
+			So(events[1].Type, ShouldEqual, Deleted)
+			So(events[1].Pair, ShouldBeNil)
+			So(events[2].Key, ShouldEqual, "conn/v1/mysql/d")
+			So(events[2].Type, ShouldEqual, Added)
+		})
+
+		Convey("no change", func() {
+			So(Diff(prev, prev), ShouldBeEmpty)
+		})
+
+		Convey("all deleted", func() {
+			events := Diff(prev, nil)
+			So(len(events), ShouldEqual, 3)
+			So(events[2].Type.String(), ShouldEqual, "deleted")
+		})
+	})
+}
+
+func TestKeyPrefixEvents(t *testing.T) {
+	Convey("key prefix events test", t, func() {
+		_, server := newFakeKV()
+		defer server.Close()
+
+		addr := strings.TrimPrefix(server.URL, "http://")
+		client, err := NewClient(SetAddress(addr))
+		So(err, ShouldBeNil)
+
+		prefix := "conn/v1/mysql"
+		So(client.Put(prefix+"/a", "1"), ShouldBeNil)
+
+		changes := make(chan []Event, 10)
+		watcher := NewWatcher(addr)
+		watcher.AddKeyPrefixEvents(prefix, func(idx uint64, events []Event) {
+			changes <- events
+		}, WithInitial())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- watcher.Start(ctx)
+		}()
+
+		next := func() []Event {
+			select {
+			case events := <-changes:
+				return events
+			case <-time.After(5 * time.Second):
+				return nil
+			}
+		}
+
+		events := next()
+		So(len(events), ShouldEqual, 1)
+		So(events[0].Type, ShouldEqual, Added)
+		So(events[0].Key, ShouldEqual, prefix+"/a")
+
+		So(client.Put(prefix+"/b", "2"), ShouldBeNil)
+		events = next()
+		So(len(events), ShouldEqual, 1)
+		So(events[0].Type, ShouldEqual, Added)
+		So(string(events[0].Pair.Value), ShouldEqual, "2")
+
+		So(client.Put(prefix+"/a", "3"), ShouldBeNil)
+		events = next()
+		So(len(events), ShouldEqual, 1)
+		So(events[0].Type, ShouldEqual, Modified)
+		So(string(events[0].Prev.Value), ShouldEqual, "1")
+		So(string(events[0].Pair.Value), ShouldEqual, "3")
+
+		So(client.Delete(prefix+"/b"), ShouldBeNil)
+		events = next()
+		So(len(events), ShouldEqual, 1)
+		So(events[0].Type, ShouldEqual, Deleted)
+		So(events[0].Key, ShouldEqual, prefix+"/b")
+		So(events[0].Pair, ShouldBeNil)
+
+		// keys outside the prefix are not watched
+		So(client.Put("conn/v1/mysql-other/a", "1"), ShouldBeNil)
+		So(client.Delete(prefix+"/a"), ShouldBeNil)
+		events = next()
+		So(len(events), ShouldEqual, 1)
+		So(events[0].Key, ShouldEqual, prefix+"/a")
+		So(events[0].Type, ShouldEqual, Deleted)
+
+		cancel()
+		So(<-done, ShouldBeNil)
+	})
+}
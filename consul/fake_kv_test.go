@@ -0,0 +1,290 @@
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// fakeKV fake consul kv, txn and session endpoints in memory
+// queries with index block until the next change, at most 1s
+// touchOnGet changes the key after every read, like a concurrent writer
+type fakeKV struct {
+	mutex      sync.Mutex
+	index      uint64
+	kv         map[string]*api.KVPair
+	sessions   map[string]bool
+	changed    chan struct{}
+	touchOnGet bool
+}
+
+func newFakeKV() (*fakeKV, *httptest.Server) {
+	f := &fakeKV{
+		index:    1,
+		kv:       make(map[string]*api.KVPair),
+		sessions: make(map[string]bool),
+		changed:  make(chan struct{}),
+	}
+
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeKV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		f.wait(r)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch {
+	case r.URL.Path == "/v1/txn":
+		f.txn(w, r)
+	case strings.HasPrefix(r.URL.Path, "/v1/kv/"):
+		f.serveKV(w, r, strings.TrimPrefix(r.URL.Path, "/v1/kv/"))
+	case strings.HasPrefix(r.URL.Path, "/v1/session/"):
+		f.session(w, r, strings.TrimPrefix(r.URL.Path, "/v1/session/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeKV) serveKV(w http.ResponseWriter, r *http.Request, key string) {
+	query := r.URL.Query()
+	_, recurse := query["recurse"]
+	_, keys := query["keys"]
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+
+		var kvPairs api.KVPairs
+		for _, k := range f.sortedKeys() {
+			if k == key || ((recurse || keys) && strings.HasPrefix(k, key)) {
+				kvPairs = append(kvPairs, f.kv[k])
+			}
+		}
+
+		if len(kvPairs) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		if keys {
+			json.NewEncoder(w).Encode(childKeys(key, query.Get("separator"), kvPairs))
+			return
+		}
+
+		json.NewEncoder(w).Encode(kvPairs)
+
+		if current, ok := f.kv[key]; ok && f.touchOnGet {
+			f.index++
+			f.kv[key] = &api.KVPair{Key: key, Value: current.Value, ModifyIndex: f.index}
+		}
+	case http.MethodPut:
+		value, _ := ioutil.ReadAll(r.Body)
+		flags, _ := strconv.ParseUint(query.Get("flags"), 10, 64)
+		json.NewEncoder(w).Encode(f.put(key, value, flags, query))
+	case http.MethodDelete:
+		if cas := query.Get("cas"); cas != "" {
+			index, _ := strconv.ParseUint(cas, 10, 64)
+			if current, ok := f.kv[key]; !ok || current.ModifyIndex != index {
+				json.NewEncoder(w).Encode(false)
+				return
+			}
+		}
+
+		for k := range f.kv {
+			if k == key || (recurse && strings.HasPrefix(k, key)) {
+				delete(f.kv, k)
+			}
+		}
+		f.bump()
+		json.NewEncoder(w).Encode(true)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// put set key with acquire, release or cas of query
+func (f *fakeKV) put(key string, value []byte, flags uint64, query map[string][]string) bool {
+	kvPair, exists := f.kv[key]
+	if !exists {
+		kvPair = &api.KVPair{Key: key}
+	}
+
+	get := func(name string) string {
+		if values := query[name]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	if session := get("acquire"); session != "" {
+		if !f.sessions[session] || (kvPair.Session != "" && kvPair.Session != session) {
+			return false
+		}
+		if kvPair.Session != session {
+			kvPair.LockIndex++
+		}
+		kvPair.Session = session
+	}
+
+	if session := get("release"); session != "" {
+		if kvPair.Session != session {
+			return false
+		}
+		kvPair.Session = ""
+	}
+
+	if cas := get("cas"); cas != "" {
+		index, _ := strconv.ParseUint(cas, 10, 64)
+		if (index == 0 && exists) || (index != 0 && index != kvPair.ModifyIndex) {
+			return false
+		}
+	}
+
+	f.bump()
+	kvPair.Value, kvPair.Flags, kvPair.ModifyIndex = value, flags, f.index
+	if !exists {
+		kvPair.CreateIndex = f.index
+	}
+	f.kv[key] = kvPair
+
+	return true
+}
+
+// session create, renew and destroy, a destroyed session releases its keys
+func (f *fakeKV) session(w http.ResponseWriter, r *http.Request, op string) {
+	switch {
+	case op == "create":
+		id := fmt.Sprintf("session-%d", f.index)
+		f.sessions[id] = true
+		f.bump()
+		json.NewEncoder(w).Encode(map[string]string{"ID": id})
+	case strings.HasPrefix(op, "renew/"):
+		id := strings.TrimPrefix(op, "renew/")
+		if !f.sessions[id] {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode([]*api.SessionEntry{{ID: id, TTL: api.DefaultLockSessionTTL}})
+	case strings.HasPrefix(op, "destroy/"):
+		id := strings.TrimPrefix(op, "destroy/")
+		delete(f.sessions, id)
+		for _, kvPair := range f.kv {
+			if kvPair.Session == id {
+				kvPair.Session = ""
+			}
+		}
+		f.bump()
+		json.NewEncoder(w).Encode(true)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeKV) txn(w http.ResponseWriter, r *http.Request) {
+	var ops []struct {
+		KV *api.KVTxnOp
+	}
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// check first, then apply
+	for i, op := range ops {
+		current, exists := f.kv[op.KV.Key]
+		failed := false
+		switch op.KV.Verb {
+		case api.KVCAS, api.KVDeleteCAS:
+			failed = (op.KV.Index == 0 && exists) || (op.KV.Index != 0 && (!exists || current.ModifyIndex != op.KV.Index))
+		case api.KVCheckNotExists:
+			failed = exists
+		}
+
+		if failed {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{"Errors": []map[string]interface{}{{"OpIndex": i, "What": "cas failed"}}})
+			return
+		}
+	}
+
+	var results []map[string]*api.KVPair
+	for _, op := range ops {
+		switch op.KV.Verb {
+		case api.KVSet, api.KVCAS:
+			f.bump()
+			f.kv[op.KV.Key] = &api.KVPair{Key: op.KV.Key, Value: op.KV.Value, ModifyIndex: f.index}
+			results = append(results, map[string]*api.KVPair{"KV": {Key: op.KV.Key}})
+		case api.KVDelete, api.KVDeleteCAS:
+			delete(f.kv, op.KV.Key)
+			f.bump()
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"Results": results})
+}
+
+// wait block a query with index until the next change
+func (f *fakeKV) wait(r *http.Request) {
+	f.mutex.Lock()
+	index, changed := f.index, f.changed
+	f.mutex.Unlock()
+
+	if wait, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); wait == 0 || wait < index {
+		return
+	}
+
+	select {
+	case <-changed:
+	case <-r.Context().Done():
+	case <-time.After(time.Second):
+	}
+}
+
+// bump next index and wake blocking queries, must hold f.mutex
+func (f *fakeKV) bump() {
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeKV) sortedKeys() []string {
+	keys := make([]string, 0, len(f.kv))
+	for key := range f.kv {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// childKeys keys under prefix, folded at separator like consul
+func childKeys(prefix, separator string, kvPairs api.KVPairs) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, kvPair := range kvPairs {
+		key := kvPair.Key
+		if i := strings.Index(key[len(prefix):], separator); separator != "" && i >= 0 {
+			key = key[:len(prefix)+i+len(separator)]
+		}
+
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
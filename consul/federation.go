@@ -0,0 +1,238 @@
+package consul
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ErrNoDatacenter federation without datacenter
+var ErrNoDatacenter = errors.New("consul: no datacenter")
+
+var _ ConfigSource = (*Federation)(nil)
+
+// Datacenter one datacenter of federation
+// empty Address uses the address of shared options, Namespace and Partition are consul enterprise only
+type Datacenter struct {
+	Name      string
+	Address   string
+	Token     string
+	Namespace string
+	Partition string
+}
+
+// Federation clients of several datacenters, the first datacenter is preferred
+type Federation struct {
+	datacenters []string
+	clients     map[string]*Client
+}
+
+// NewFederation new federation, the first datacenter is preferred
+// opts are shared by all datacenter clients, eg. SetScheme, SetHTTPBasicAuth
+func NewFederation(datacenters []Datacenter, opts ...ClientOptionFunc) (*Federation, error) {
+	if len(datacenters) == 0 {
+		return nil, ErrNoDatacenter
+	}
+
+	federation := &Federation{
+		datacenters: make([]string, 0, len(datacenters)),
+		clients:     make(map[string]*Client, len(datacenters)),
+	}
+
+	for _, dc := range datacenters {
+		if _, ok := federation.clients[dc.Name]; ok || dc.Name == "" {
+			return nil, fmt.Errorf("consul: invalid or duplicate datacenter %q", dc.Name)
+		}
+
+		dcOpts := append(append([]ClientOptionFunc(nil), opts...),
+			SetDatacenter(dc.Name),
+			SetAddress(dc.Address),
+			SetToken(dc.Token),
+			SetNamespace(dc.Namespace),
+			SetPartition(dc.Partition),
+		)
+
+		client, err := NewClient(dcOpts...)
+		if err != nil {
+			return nil, err
+		}
+
+		federation.datacenters = append(federation.datacenters, dc.Name)
+		federation.clients[dc.Name] = client
+	}
+
+	return federation, nil
+}
+
+// Datacenters datacenter names, preferred first
+func (federation *Federation) Datacenters() []string {
+	return append([]string(nil), federation.datacenters...)
+}
+
+// Client client of datacenter, nil if not exist
+func (federation *Federation) Client(datacenter string) *Client {
+	return federation.clients[datacenter]
+}
+
+// Preferred client of the preferred datacenter
+func (federation *Federation) Preferred() *Client {
+	return federation.clients[federation.datacenters[0]]
+}
+
+// Get get kv from the preferred datacenter, fall back to the others in order
+func (federation *Federation) Get(key string) (string, error) {
+	var err error
+	for _, dc := range federation.datacenters {
+		var value string
+		if value, err = federation.clients[dc].Get(key); err == nil {
+			return value, nil
+		}
+
+		log.Printf("Failed on consul federation Get, dc: %v, key: %v, err: %v \r\n", dc, key, err)
+	}
+
+	return "", err
+}
+
+// List child keys from the preferred datacenter, fall back to the others in order
+func (federation *Federation) List(keyPrefix string) ([]string, error) {
+	var err error
+	for _, dc := range federation.datacenters {
+		var keys []string
+		if keys, err = federation.clients[dc].GetChildKeys(keyPrefix); err == nil {
+			return keys, nil
+		}
+
+		log.Printf("Failed on consul federation List, dc: %v, keyPrefix: %v, err: %v \r\n", dc, keyPrefix, err)
+	}
+
+	return nil, err
+}
+
+// SecretKey secret key of the preferred datacenter, all share the options of NewFederation
+func (federation *Federation) SecretKey() (string, error) {
+	return federation.Preferred().SecretKey()
+}
+
+// Watch watch key in the preferred datacenter until ctx done, with its token, namespace and partition
+func (federation *Federation) Watch(ctx context.Context, key string, handle func(value string)) error {
+	return federation.Preferred().Watch(ctx, key, handle)
+}
+
+// Healthy healthy instances of service in all datacenters, preferred first
+// fails only when every datacenter fails
+func (federation *Federation) Healthy(service, tag string) ([]*api.ServiceEntry, error) {
+	results := make([][]*api.ServiceEntry, len(federation.datacenters))
+	err := federation.each(func(i int, client *Client) error {
+		entries, err := client.Healthy(service, tag)
+		results[i] = entries
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*api.ServiceEntry
+	for i := range results {
+		entries = append(entries, results[i]...)
+	}
+
+	return entries, nil
+}
+
+// Services service names and tags of all datacenters, tags are merged
+// fails only when every datacenter fails
+func (federation *Federation) Services() (map[string][]string, error) {
+	results := make([]map[string][]string, len(federation.datacenters))
+	err := federation.each(func(i int, client *Client) error {
+		services, _, err := client.consulClient.Catalog().Services(nil)
+		results[i] = services
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string][]string)
+	for i := range results {
+		for service, tags := range results[i] {
+			merged[service] = mergeStrings(merged[service], tags)
+		}
+	}
+
+	for service := range merged {
+		sort.Strings(merged[service])
+	}
+
+	return merged, nil
+}
+
+// GetConsulAddrs consul addrs of clusterName in all datacenters, preferred first
+// fails only when every datacenter fails
+func (federation *Federation) GetConsulAddrs(clusterName string) ([]string, error) {
+	results := make([][]string, len(federation.datacenters))
+	err := federation.each(func(i int, client *Client) error {
+		addrs, err := client.GetConsulAddrs(clusterName)
+		results[i] = addrs
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []string
+	for i := range results {
+		addrs = mergeStrings(addrs, results[i])
+	}
+
+	return addrs, nil
+}
+
+// each call fn for every datacenter concurrently, returns the first error when all fail
+func (federation *Federation) each(fn func(i int, client *Client) error) error {
+	errs := make([]error, len(federation.datacenters))
+
+	var wg sync.WaitGroup
+	for i, dc := range federation.datacenters {
+		wg.Add(1)
+		go func(i int, dc string) {
+			defer wg.Done()
+			if errs[i] = fn(i, federation.clients[dc]); errs[i] != nil {
+				log.Printf("Failed on consul federation, dc: %v, err: %v \r\n", dc, errs[i])
+			}
+		}(i, dc)
+	}
+	wg.Wait()
+
+	for i := range errs {
+		if errs[i] == nil {
+			return nil
+		}
+	}
+
+	return errs[0]
+}
+
+// mergeStrings append items not in dst, keep order
+func mergeStrings(dst, items []string) []string {
+	for _, item := range items {
+		exist := false
+		for i := range dst {
+			if dst[i] == item {
+				exist = true
+				break
+			}
+		}
+
+		if !exist {
+			dst = append(dst, item)
+		}
+	}
+
+	return dst
+}
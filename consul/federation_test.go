@@ -0,0 +1,206 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeDatacenter fake consul kv, health and catalog endpoints of one datacenter
+type fakeDatacenter struct {
+	mutex    sync.Mutex
+	down     bool
+	kv       map[string]string
+	entries  []*api.ServiceEntry
+	services map[string][]string
+	queries  []string
+	// raft index of kv, bumped by set
+	index uint64
+}
+
+func (dc *fakeDatacenter) set(key, value string) {
+	dc.mutex.Lock()
+	dc.kv[key] = value
+	dc.index++
+	dc.mutex.Unlock()
+}
+
+func (dc *fakeDatacenter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// a short blocking query for watches
+	if r.URL.Query().Get("index") != "" {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	dc.queries = append(dc.queries, r.URL.Query().Get("dc")+"|"+r.Header.Get("X-Consul-Token")+"|"+r.URL.Query().Get("ns"))
+	if dc.down {
+		http.Error(w, "down", http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v1/kv/"):
+		key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		value, ok := dc.kv[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(dc.index+1, 10))
+		json.NewEncoder(w).Encode([]*api.KVPair{{Key: key, Value: []byte(value)}})
+	case strings.HasPrefix(r.URL.Path, "/v1/health/service/"):
+		json.NewEncoder(w).Encode(dc.entries)
+	case r.URL.Path == "/v1/catalog/services":
+		json.NewEncoder(w).Encode(dc.services)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestFederation(t *testing.T) {
+	Convey("federation test", t, func() {
+		dc1 := &fakeDatacenter{
+			kv: map[string]string{
+				"service/go/test/only1":       "dc1",
+				"service/go/test/both":        "dc1",
+				path.Join(Consul, "registry"): `Addrs = ["10.0.1.1:8500", "10.0.0.1:8500"]`,
+				path.Join(Consul, "dc1-only"): `Addrs = ["10.0.1.1:8500"]`,
+			},
+			entries:  []*api.ServiceEntry{testEntry("10.0.1.2", 8080, 1)},
+			services: map[string][]string{"pusher": {"v1"}, "consul": nil},
+		}
+		dc2 := &fakeDatacenter{
+			kv: map[string]string{
+				"service/go/test/only2":       "dc2",
+				"service/go/test/both":        "dc2",
+				path.Join(Consul, "registry"): `Addrs = ["10.0.2.1:8500", "10.0.0.1:8500"]`,
+			},
+			entries:  []*api.ServiceEntry{testEntry("10.0.2.2", 8080, 1)},
+			services: map[string][]string{"pusher": {"v2", "v1"}, "sender": nil},
+		}
+
+		server1 := httptest.NewServer(dc1)
+		defer server1.Close()
+		server2 := httptest.NewServer(dc2)
+		defer server2.Close()
+
+		federation, err := NewFederation([]Datacenter{
+			{Name: "dc1", Address: strings.TrimPrefix(server1.URL, "http://"), Token: "token1"},
+			{Name: "dc2", Address: strings.TrimPrefix(server2.URL, "http://"), Token: "token2", Namespace: "team"},
+		})
+		So(err, ShouldBeNil)
+		So(federation.Datacenters(), ShouldResemble, []string{"dc1", "dc2"})
+		So(federation.Client("dc3"), ShouldBeNil)
+
+		Convey("get with fallback", func() {
+			value, err := federation.Get("service/go/test/both")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "dc1")
+
+			value, err = federation.Get("service/go/test/only2")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "dc2")
+
+			_, err = federation.Get("service/go/test/none")
+			So(err, ShouldNotBeNil)
+
+			So(dc1.queries[0], ShouldEqual, "dc1|token1|")
+			So(dc2.queries[0], ShouldEqual, "dc2|token2|team")
+		})
+
+		Convey("watch preferred", func() {
+			key := "service/go/test/both"
+			ctx, cancel := context.WithCancel(context.Background())
+			values := make(chan string, 1)
+			done := make(chan struct{})
+			go func() {
+				federation.Watch(ctx, key, func(value string) {
+					values <- value
+				})
+				close(done)
+			}()
+
+			time.Sleep(50 * time.Millisecond)
+			dc1.set(key, "dc1 changed")
+			So(<-values, ShouldEqual, "dc1 changed")
+
+			cancel()
+			<-done
+
+			// the watch requests carry the datacenter and token of the preferred datacenter
+			dc1.mutex.Lock()
+			defer dc1.mutex.Unlock()
+			So(len(dc1.queries), ShouldBeGreaterThan, 1)
+			for _, query := range dc1.queries {
+				So(query, ShouldEqual, "dc1|token1|")
+			}
+		})
+
+		Convey("healthy merged", func() {
+			entries, err := federation.Healthy("pusher", "")
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 2)
+			So(entries[0].Service.Address, ShouldEqual, "10.0.1.2")
+			So(entries[1].Service.Address, ShouldEqual, "10.0.2.2")
+
+			dc1.mutex.Lock()
+			dc1.down = true
+			dc1.mutex.Unlock()
+
+			entries, err = federation.Healthy("pusher", "")
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 1)
+
+			value, err := federation.Get("service/go/test/both")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "dc2")
+
+			dc2.mutex.Lock()
+			dc2.down = true
+			dc2.mutex.Unlock()
+
+			_, err = federation.Healthy("pusher", "")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("services merged", func() {
+			services, err := federation.Services()
+			So(err, ShouldBeNil)
+			So(services["pusher"], ShouldResemble, []string{"v1", "v2"})
+			_, ok := services["sender"]
+			So(ok, ShouldBeTrue)
+			_, ok = services["consul"]
+			So(ok, ShouldBeTrue)
+		})
+
+		Convey("consul addrs merged", func() {
+			addrs, err := federation.GetConsulAddrs("registry")
+			So(err, ShouldBeNil)
+			So(addrs, ShouldResemble, []string{"10.0.1.1:8500", "10.0.0.1:8500", "10.0.2.1:8500"})
+
+			addrs, err = federation.GetConsulAddrs("dc1-only")
+			So(err, ShouldBeNil)
+			So(addrs, ShouldResemble, []string{"10.0.1.1:8500"})
+		})
+	})
+
+	Convey("invalid federation test", t, func() {
+		_, err := NewFederation(nil)
+		So(err, ShouldEqual, ErrNoDatacenter)
+
+		_, err = NewFederation([]Datacenter{{Name: "dc1"}, {Name: "dc1"}})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,237 @@
+package consul
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const _defaultElectionRetryDelay = 5 * time.Second
+
+// ErrLockNotAcquired lock not acquired, ctx done or LockTryOnce timeout
+var ErrLockNotAcquired = errors.New("consul lock not acquired")
+
+// LockOptionFunc lock option func
+type LockOptionFunc func(*lockOptions)
+
+type lockOptions struct {
+	api.LockOptions
+	lockDelay time.Duration
+}
+
+// LockSessionTTL session ttl, renewed in background while the lock is held
+func LockSessionTTL(ttl time.Duration) LockOptionFunc {
+	return func(opts *lockOptions) {
+		opts.SessionTTL = ttl.String()
+	}
+}
+
+// LockDelay after the lock is lost, nobody can acquire it within delay
+func LockDelay(delay time.Duration) LockOptionFunc {
+	return func(opts *lockOptions) {
+		opts.lockDelay = delay
+	}
+}
+
+// LockValue value stored in the lock key, eg. hostname
+func LockValue(value string) LockOptionFunc {
+	return func(opts *lockOptions) {
+		opts.Value = []byte(value)
+	}
+}
+
+// LockSessionName session name
+func LockSessionName(name string) LockOptionFunc {
+	return func(opts *lockOptions) {
+		opts.SessionName = name
+	}
+}
+
+// LockTryOnce try acquire once, wait at most waitTime
+func LockTryOnce(waitTime time.Duration) LockOptionFunc {
+	return func(opts *lockOptions) {
+		opts.LockTryOnce = true
+		opts.LockWaitTime = waitTime
+	}
+}
+
+func newLockOptions(key string, opts []LockOptionFunc) *api.LockOptions {
+	options := &lockOptions{
+		LockOptions: api.LockOptions{
+			Key: key,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	// lock delay only can be set by session entry
+	if options.lockDelay > 0 {
+		options.SessionOpts = &api.SessionEntry{
+			Name:      options.SessionName,
+			TTL:       options.SessionTTL,
+			LockDelay: options.lockDelay,
+			Behavior:  api.SessionBehaviorRelease,
+		}
+
+		if options.SessionOpts.Name == "" {
+			options.SessionOpts.Name = api.DefaultLockSessionName
+		}
+
+		if options.SessionOpts.TTL == "" {
+			options.SessionOpts.TTL = api.DefaultLockSessionTTL
+		}
+	}
+
+	return &options.LockOptions
+}
+
+// Lock distributed lock on consul session
+type Lock struct {
+	lock *api.Lock
+	lost <-chan struct{}
+}
+
+// Lock acquire lock of key, block until acquired or ctx done
+func (client *Client) Lock(ctx context.Context, key string, opts ...LockOptionFunc) (*Lock, error) {
+	lock, err := client.consulClient.LockOpts(newLockOptions(key, opts))
+	if err != nil {
+		return nil, err
+	}
+
+	lost, err := lock.Lock(ctx.Done())
+	if err != nil {
+		return nil, err
+	}
+
+	if lost == nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, ErrLockNotAcquired
+	}
+
+	return &Lock{
+		lock: lock,
+		lost: lost,
+	}, nil
+}
+
+// Lost closed when the lock is lost, eg. session invalidated or key deleted
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Unlock release lock
+func (l *Lock) Unlock() error {
+	return l.lock.Unlock()
+}
+
+// LeaderElection pick exactly one leader among replicas by consul lock
+type LeaderElection struct {
+	client *Client
+	key    string
+	opts   []LockOptionFunc
+	leader bool
+	mutex  sync.RWMutex
+	// retryDelay wait before campaigning again after a failure or lead returned
+	retryDelay time.Duration
+}
+
+// NewLeaderElection new leader election on key
+func (client *Client) NewLeaderElection(key string, opts ...LockOptionFunc) *LeaderElection {
+	return &LeaderElection{
+		client:     client,
+		key:        key,
+		opts:       opts,
+		retryDelay: _defaultElectionRetryDelay,
+	}
+}
+
+// Run campaign until ctx done
+// lead is called when elected, its ctx is cancelled when leadership is lost, then campaign again
+// if lead returns on its own, campaign again after _defaultElectionRetryDelay
+func (e *LeaderElection) Run(ctx context.Context, lead func(context.Context)) error {
+	for {
+		lock, err := e.client.Lock(ctx, e.key, e.opts...)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			log.Printf("Failed on consul leader election, key: %v, err: %v \r\n", e.key, err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(e.retryDelay):
+			}
+			continue
+		}
+
+		e.setLeader(true)
+		returned := e.lead(ctx, lock, lead)
+		e.setLeader(false)
+
+		if err = lock.Unlock(); err != nil && err != api.ErrLockNotHeld {
+			log.Printf("Failed on consul leader unlock, key: %v, err: %v \r\n", e.key, err)
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if !returned {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(e.retryDelay):
+		}
+	}
+}
+
+// lead run lead until it returns, leadership lost or ctx done, true if lead returned on its own
+func (e *LeaderElection) lead(ctx context.Context, lock *Lock, lead func(context.Context)) bool {
+	leadCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leadCtx)
+	}()
+
+	returned := false
+	select {
+	case <-lock.Lost():
+		log.Printf("Consul leadership lost, key: %v \r\n", e.key)
+	case <-done:
+		returned = true
+	case <-ctx.Done():
+	}
+
+	cancel()
+	<-done
+
+	return returned
+}
+
+// IsLeader is leader now
+func (e *LeaderElection) IsLeader() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.leader
+}
+
+func (e *LeaderElection) setLeader(leader bool) {
+	e.mutex.Lock()
+	e.leader = leader
+	e.mutex.Unlock()
+}
@@ -0,0 +1,119 @@
+package consul
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testLockKey     = "service/go/test/lock"
+	testElectionKey = "service/go/test/leader"
+)
+
+func TestLock(t *testing.T) {
+	Convey("lock test", t, func() {
+		_, server := newFakeKV()
+		defer server.Close()
+
+		client, err := NewClient(SetAddress(strings.TrimPrefix(server.URL, "http://")))
+		So(err, ShouldBeNil)
+
+		Convey("lock & unlock", func() {
+			lock, err := client.Lock(context.Background(), testLockKey, LockSessionTTL(10*time.Second), LockValue("test"))
+			So(err, ShouldBeNil)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			_, err = client.Lock(ctx, testLockKey)
+			So(err, ShouldBeError, context.DeadlineExceeded.Error())
+
+			So(lock.Unlock(), ShouldBeNil)
+		})
+
+		Convey("lost", func() {
+			lock, err := client.Lock(context.Background(), testLockKey, LockDelay(time.Millisecond))
+			So(err, ShouldBeNil)
+
+			So(client.Delete(testLockKey), ShouldBeNil)
+			select {
+			case <-lock.Lost():
+			case <-time.After(5 * time.Second):
+				t.Fatal("lock not lost")
+			}
+			lock.Unlock()
+		})
+	})
+}
+
+func TestLeaderElection(t *testing.T) {
+	Convey("leader election test", t, func() {
+		_, server := newFakeKV()
+		defer server.Close()
+
+		client, err := NewClient(SetAddress(strings.TrimPrefix(server.URL, "http://")))
+		So(err, ShouldBeNil)
+
+		var leaders int32
+		ctx, cancel := context.WithCancel(context.Background())
+		elections := []*LeaderElection{
+			client.NewLeaderElection(testElectionKey, LockDelay(time.Millisecond)),
+			client.NewLeaderElection(testElectionKey, LockDelay(time.Millisecond)),
+		}
+
+		done := make(chan struct{}, len(elections))
+		for _, e := range elections {
+			go func(e *LeaderElection) {
+				e.Run(ctx, func(ctx context.Context) {
+					atomic.AddInt32(&leaders, 1)
+					<-ctx.Done()
+					atomic.AddInt32(&leaders, -1)
+				})
+				done <- struct{}{}
+			}(e)
+		}
+
+		time.Sleep(time.Second)
+		So(atomic.LoadInt32(&leaders), ShouldEqual, 1)
+		So(elections[0].IsLeader() != elections[1].IsLeader(), ShouldBeTrue)
+
+		cancel()
+		<-done
+		<-done
+		So(atomic.LoadInt32(&leaders), ShouldEqual, 0)
+	})
+}
+
+func TestLeaderElectionLeadReturned(t *testing.T) {
+	Convey("leader election lead returned test", t, func() {
+		_, server := newFakeKV()
+		defer server.Close()
+
+		client, err := NewClient(SetAddress(strings.TrimPrefix(server.URL, "http://")))
+		So(err, ShouldBeNil)
+
+		// lead returns at once, campaign again only after the retry delay
+		var leads int32
+		e := client.NewLeaderElection(testElectionKey, LockDelay(time.Millisecond))
+		e.retryDelay = time.Hour
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- e.Run(ctx, func(ctx context.Context) {
+				atomic.AddInt32(&leads, 1)
+			})
+		}()
+
+		time.Sleep(300 * time.Millisecond)
+		So(atomic.LoadInt32(&leads), ShouldEqual, 1)
+		So(e.IsLeader(), ShouldBeFalse)
+
+		cancel()
+		So(<-done, ShouldBeNil)
+	})
+}
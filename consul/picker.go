@@ -0,0 +1,109 @@
+package consul
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ErrNoInstance no healthy instance
+var ErrNoInstance = errors.New("consul: no healthy service instance")
+
+// Picker pick one instance from healthy instances
+type Picker interface {
+	Pick(entries []*api.ServiceEntry) (*api.ServiceEntry, error)
+}
+
+// PickerFunc picker func
+type PickerFunc func(entries []*api.ServiceEntry) (*api.ServiceEntry, error)
+
+// Pick pick
+func (f PickerFunc) Pick(entries []*api.ServiceEntry) (*api.ServiceEntry, error) {
+	return f(entries)
+}
+
+type roundRobin struct {
+	next uint64
+}
+
+// RoundRobin round robin picker
+func RoundRobin() Picker {
+	return &roundRobin{}
+}
+
+func (r *roundRobin) Pick(entries []*api.ServiceEntry) (*api.ServiceEntry, error) {
+	if len(entries) == 0 {
+		return nil, ErrNoInstance
+	}
+
+	n := atomic.AddUint64(&r.next, 1)
+	return entries[(n-1)%uint64(len(entries))], nil
+}
+
+// Random random picker
+func Random() Picker {
+	rnd := newLockedRand()
+	return PickerFunc(func(entries []*api.ServiceEntry) (*api.ServiceEntry, error) {
+		if len(entries) == 0 {
+			return nil, ErrNoInstance
+		}
+
+		return entries[rnd.Intn(len(entries))], nil
+	})
+}
+
+// Weighted random picker weighted by Service.Weights.Passing, weight <= 0 counts as 1
+func Weighted() Picker {
+	rnd := newLockedRand()
+	return PickerFunc(func(entries []*api.ServiceEntry) (*api.ServiceEntry, error) {
+		if len(entries) == 0 {
+			return nil, ErrNoInstance
+		}
+
+		total := 0
+		for i := range entries {
+			total += weight(entries[i])
+		}
+
+		n := rnd.Intn(total)
+		for i := range entries {
+			n -= weight(entries[i])
+			if n < 0 {
+				return entries[i], nil
+			}
+		}
+
+		return entries[len(entries)-1], nil
+	})
+}
+
+func weight(entry *api.ServiceEntry) int {
+	if entry.Service == nil || entry.Service.Weights.Passing <= 0 {
+		return 1
+	}
+
+	return entry.Service.Weights.Passing
+}
+
+type lockedRand struct {
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+func newLockedRand() *lockedRand {
+	return &lockedRand{
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (r *lockedRand) Intn(n int) int {
+	r.mutex.Lock()
+	i := r.rand.Intn(n)
+	r.mutex.Unlock()
+
+	return i
+}
@@ -0,0 +1,218 @@
+package consul
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	_defaultHeartbeatInterval = 10 * time.Second
+	_checkIDTmpl              = "service:%s"
+	_checkIDIndexTmpl         = "service:%s:%d"
+)
+
+// ErrRegisterNotSet register not set
+var ErrRegisterNotSet = errors.New("consul register not set, use SetRegister")
+
+// TTLCheck ttl check, service must heartbeat within ttl
+// deregisterAfter > 0, agent deregister service after critical for deregisterAfter
+func TTLCheck(ttl, deregisterAfter time.Duration) *api.AgentServiceCheck {
+	return withDeregister(&api.AgentServiceCheck{
+		TTL: ttl.String(),
+	}, deregisterAfter)
+}
+
+// HTTPCheck http check, agent GET url every interval
+func HTTPCheck(url string, interval, timeout, deregisterAfter time.Duration) *api.AgentServiceCheck {
+	return withDeregister(&api.AgentServiceCheck{
+		HTTP:     url,
+		Interval: interval.String(),
+		Timeout:  timeout.String(),
+	}, deregisterAfter)
+}
+
+// TCPCheck tcp check, agent dial addr every interval
+func TCPCheck(addr string, interval, timeout, deregisterAfter time.Duration) *api.AgentServiceCheck {
+	return withDeregister(&api.AgentServiceCheck{
+		TCP:      addr,
+		Interval: interval.String(),
+		Timeout:  timeout.String(),
+	}, deregisterAfter)
+}
+
+// GRPCCheck grpc health check, addr eg. 127.0.0.1:9000/service
+func GRPCCheck(addr string, useTLS bool, interval, timeout, deregisterAfter time.Duration) *api.AgentServiceCheck {
+	return withDeregister(&api.AgentServiceCheck{
+		GRPC:       addr,
+		GRPCUseTLS: useTLS,
+		Interval:   interval.String(),
+		Timeout:    timeout.String(),
+	}, deregisterAfter)
+}
+
+func withDeregister(check *api.AgentServiceCheck, deregisterAfter time.Duration) *api.AgentServiceCheck {
+	if deregisterAfter > 0 {
+		check.DeregisterCriticalServiceAfter = deregisterAfter.String()
+	}
+
+	return check
+}
+
+// Register register service by SetRegister
+func (client *Client) Register() error {
+	if client.register == nil {
+		return ErrRegisterNotSet
+	}
+
+	return client.consulClient.Agent().ServiceRegister(client.register)
+}
+
+// KeepRegister register service and keep it registered until ctx done, then deregister
+// ttl checks are passed every heartbeat interval
+// when the agent restarts and loses the service, it is registered again
+func (client *Client) KeepRegister(ctx context.Context) error {
+	if err := client.Register(); err != nil {
+		return err
+	}
+
+	// ttl checks start critical, pass them before the first tick
+	if err := client.passAll(client.ttlCheckIDs()); err != nil {
+		log.Printf("Failed on consul heartbeat, service: %v, err: %v \r\n", client.serviceID(), err)
+	}
+
+	ticker := time.NewTicker(client.getHeartbeatInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return client.Deregister(client.serviceID())
+		case <-ticker.C:
+			if err := client.heartbeat(); err != nil {
+				log.Printf("Failed on consul heartbeat, service: %v, err: %v \r\n", client.serviceID(), err)
+			}
+		}
+	}
+}
+
+func (client *Client) heartbeat() error {
+	agent := client.consulClient.Agent()
+	checkIDs := client.ttlCheckIDs()
+
+	// no ttl check, make sure the service still exists in agent
+	if len(checkIDs) == 0 {
+		services, err := agent.Services()
+		if err != nil {
+			return err
+		}
+
+		if _, ok := services[client.serviceID()]; ok {
+			return nil
+		}
+
+		log.Printf("Consul service lost, register again: %v \r\n", client.serviceID())
+		return client.Register()
+	}
+
+	for _, checkID := range checkIDs {
+		if err := agent.UpdateTTL(checkID, "", api.HealthPassing); err != nil {
+			// agent restarted and lost the check, register again
+			log.Printf("Consul check lost, register again: %v, err: %v \r\n", checkID, err)
+			if err = client.Register(); err != nil {
+				return err
+			}
+
+			return client.passAll(checkIDs)
+		}
+	}
+
+	return nil
+}
+
+func (client *Client) passAll(checkIDs []string) error {
+	for _, checkID := range checkIDs {
+		if err := client.consulClient.Agent().UpdateTTL(checkID, "", api.HealthPassing); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (client *Client) serviceID() string {
+	if client.register == nil {
+		return ""
+	}
+
+	if client.register.ID != "" {
+		return client.register.ID
+	}
+
+	return client.register.Name
+}
+
+// ttlCheckIDs check ids as the agent names them, Check first then Checks in one list
+// service:<id> for a single check, service:<id>:<n> numbered from 1 for several
+func (client *Client) ttlCheckIDs() []string {
+	var checkIDs []string
+	id := client.serviceID()
+	checks := client.checks()
+
+	for i, check := range checks {
+		if check.TTL == "" {
+			continue
+		}
+
+		defaultID := fmt.Sprintf(_checkIDTmpl, id)
+		if len(checks) > 1 {
+			defaultID = fmt.Sprintf(_checkIDIndexTmpl, id, i+1)
+		}
+		checkIDs = append(checkIDs, checkID(check, defaultID))
+	}
+
+	return checkIDs
+}
+
+// checks Check and Checks in the order of the agent
+func (client *Client) checks() api.AgentServiceChecks {
+	checks := client.register.Checks
+	if client.register.Check != nil {
+		checks = append(api.AgentServiceChecks{client.register.Check}, checks...)
+	}
+
+	return checks
+}
+
+func checkID(check *api.AgentServiceCheck, defaultID string) string {
+	if check.CheckID != "" {
+		return check.CheckID
+	}
+
+	return defaultID
+}
+
+// getHeartbeatInterval SetHeartbeatInterval, or half of the shortest ttl
+func (client *Client) getHeartbeatInterval() time.Duration {
+	if client.heartbeatInterval > 0 {
+		return client.heartbeatInterval
+	}
+
+	interval := _defaultHeartbeatInterval
+	for _, check := range client.checks() {
+		ttl, err := time.ParseDuration(check.TTL)
+		if err != nil || ttl <= 0 {
+			continue
+		}
+
+		if ttl/2 < interval {
+			interval = ttl / 2
+		}
+	}
+
+	return interval
+}
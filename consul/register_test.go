@@ -0,0 +1,228 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeAgent fake consul agent service & check endpoints
+type fakeAgent struct {
+	mutex    sync.Mutex
+	services map[string]*api.AgentServiceRegistration
+	// ttl checks of services, by check id
+	checks     map[string]string
+	ttlPassed  map[string]int
+	registered int
+}
+
+func newFakeAgent() (*fakeAgent, *httptest.Server) {
+	agent := &fakeAgent{
+		services:  make(map[string]*api.AgentServiceRegistration),
+		checks:    make(map[string]string),
+		ttlPassed: make(map[string]int),
+	}
+
+	return agent, httptest.NewServer(agent)
+}
+
+// register check ids as the agent names them: Check then Checks in one list,
+// service:<id> when it has one check, service:<id>:<n> from 1 when several
+func (agent *fakeAgent) register(reg *api.AgentServiceRegistration) {
+	var checks api.AgentServiceChecks
+	if reg.Check != nil {
+		checks = append(checks, reg.Check)
+	}
+	checks = append(checks, reg.Checks...)
+
+	for i, check := range checks {
+		checkID := check.CheckID
+		if checkID == "" {
+			checkID = "service:" + reg.ID
+			if len(checks) > 1 {
+				checkID += ":" + strconv.Itoa(i+1)
+			}
+		}
+
+		if check.TTL != "" {
+			agent.checks[checkID] = reg.ID
+		}
+	}
+
+	agent.services[reg.ID] = reg
+	agent.registered++
+}
+
+func (agent *fakeAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	agent.mutex.Lock()
+	defer agent.mutex.Unlock()
+
+	switch {
+	case r.URL.Path == "/v1/agent/service/register":
+		var reg api.AgentServiceRegistration
+		if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		agent.register(&reg)
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+		delete(agent.services, strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/check/update/"):
+		checkID := strings.TrimPrefix(r.URL.Path, "/v1/agent/check/update/")
+		if _, ok := agent.checks[checkID]; !ok {
+			http.Error(w, "CheckID does not have associated TTL", http.StatusInternalServerError)
+			return
+		}
+		agent.ttlPassed[checkID]++
+	case r.URL.Path == "/v1/agent/services":
+		services := make(map[string]*api.AgentService, len(agent.services))
+		for id, reg := range agent.services {
+			services[id] = &api.AgentService{ID: id, Service: reg.Name}
+		}
+		json.NewEncoder(w).Encode(services)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (agent *fakeAgent) restart() {
+	agent.mutex.Lock()
+	agent.services = make(map[string]*api.AgentServiceRegistration)
+	agent.checks = make(map[string]string)
+	agent.mutex.Unlock()
+}
+
+func (agent *fakeAgent) state() (int, int, bool) {
+	agent.mutex.Lock()
+	defer agent.mutex.Unlock()
+
+	_, ok := agent.services["pusher-1"]
+	return agent.registered, agent.ttlPassed["service:pusher-1"], ok
+}
+
+func (agent *fakeAgent) passed(checkID string) int {
+	agent.mutex.Lock()
+	defer agent.mutex.Unlock()
+
+	return agent.ttlPassed[checkID]
+}
+
+func TestRegister(t *testing.T) {
+	Convey("register test", t, func() {
+		agent, server := newFakeAgent()
+		defer server.Close()
+
+		client, err := NewClient(
+			SetAddress(strings.TrimPrefix(server.URL, "http://")),
+			SetHeartbeatInterval(10*time.Millisecond),
+			SetRegister(&api.AgentServiceRegistration{
+				ID:    "pusher-1",
+				Name:  "pusher",
+				Check: TTLCheck(time.Second, time.Minute),
+			}),
+		)
+		So(err, ShouldBeNil)
+
+		Convey("register not set", func() {
+			c, err := NewClient(SetAddress(strings.TrimPrefix(server.URL, "http://")))
+			So(err, ShouldBeNil)
+			So(c.Register(), ShouldEqual, ErrRegisterNotSet)
+		})
+
+		Convey("keep register", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			errc := make(chan error, 1)
+			go func() {
+				errc <- client.KeepRegister(ctx)
+			}()
+
+			time.Sleep(50 * time.Millisecond)
+			registered, passed, ok := agent.state()
+			So(registered, ShouldEqual, 1)
+			So(passed, ShouldBeGreaterThan, 0)
+			So(ok, ShouldBeTrue)
+
+			// agent restart lost service
+			agent.restart()
+			time.Sleep(50 * time.Millisecond)
+			registered, _, ok = agent.state()
+			So(registered, ShouldBeGreaterThan, 1)
+			So(ok, ShouldBeTrue)
+
+			cancel()
+			So(<-errc, ShouldBeNil)
+			_, _, ok = agent.state()
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("check and checks", func() {
+			// a long interval, ttl checks are passed right after registered
+			c, err := NewClient(
+				SetAddress(strings.TrimPrefix(server.URL, "http://")),
+				SetHeartbeatInterval(time.Hour),
+				SetRegister(&api.AgentServiceRegistration{
+					ID:    "pusher-2",
+					Name:  "pusher",
+					Check: TTLCheck(time.Second, 0),
+					Checks: api.AgentServiceChecks{
+						HTTPCheck("http://127.0.0.1/health", time.Second, time.Second, 0),
+						TTLCheck(time.Second, 0),
+					},
+				}),
+			)
+			So(err, ShouldBeNil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			errc := make(chan error, 1)
+			go func() {
+				errc <- c.KeepRegister(ctx)
+			}()
+
+			time.Sleep(50 * time.Millisecond)
+			So(agent.passed("service:pusher-2:1"), ShouldEqual, 1)
+			So(agent.passed("service:pusher-2:3"), ShouldEqual, 1)
+
+			cancel()
+			So(<-errc, ShouldBeNil)
+		})
+	})
+
+	Convey("heartbeat interval test", t, func() {
+		client, err := NewClient(SetRegister(&api.AgentServiceRegistration{
+			Name: "pusher",
+			Checks: api.AgentServiceChecks{
+				TTLCheck(30*time.Second, 0),
+				HTTPCheck("http://127.0.0.1/health", time.Second, time.Second, 0),
+				TTLCheck(6*time.Second, 0),
+			},
+		}))
+		So(err, ShouldBeNil)
+		So(client.getHeartbeatInterval(), ShouldEqual, 3*time.Second)
+		So(client.ttlCheckIDs(), ShouldResemble, []string{"service:pusher:1", "service:pusher:3"})
+
+		// one check overall is not numbered, wherever it is
+		client, err = NewClient(SetRegister(&api.AgentServiceRegistration{
+			Name:   "pusher",
+			Checks: api.AgentServiceChecks{TTLCheck(time.Second, 0)},
+		}))
+		So(err, ShouldBeNil)
+		So(client.ttlCheckIDs(), ShouldResemble, []string{"service:pusher"})
+
+		client, err = NewClient(SetRegister(&api.AgentServiceRegistration{
+			Name:   "pusher",
+			Check:  TTLCheck(time.Second, 0),
+			Checks: api.AgentServiceChecks{TTLCheck(time.Second, 0)},
+		}))
+		So(err, ShouldBeNil)
+		So(client.ttlCheckIDs(), ShouldResemble, []string{"service:pusher:1", "service:pusher:2"})
+	})
+}
@@ -0,0 +1,242 @@
+package consul
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/JREAMLU/j-kit/crypto"
+)
+
+const (
+	// SecretPrefix encrypted value prefix, eg. enc:v1:<base64 nonce and aes-gcm ciphertext>
+	SecretPrefix = "enc:"
+	// SecretVersion version of the encrypted value format
+	SecretVersion = "v1"
+	// SecretKeyEnvName hex aes key env, same as SetSecretKey
+	SecretKeyEnvName = "JKIT_CONSUL_SECRET_KEY"
+	// SecretKeyFileEnvName hex aes key file env, used when SecretKeyEnvName is empty
+	SecretKeyFileEnvName = "JKIT_CONSUL_SECRET_KEY_FILE"
+)
+
+var (
+	// ErrSecretKeyNotSet encrypted value without key
+	ErrSecretKeyNotSet = errors.New("consul: secret key not set")
+	// ErrInvalidSecret malformed encrypted value
+	ErrInvalidSecret = errors.New("consul: invalid secret value")
+)
+
+// SecretKeySource source with its own secret key, eg. Client, Federation
+type SecretKeySource interface {
+	// SecretKey hex aes key, empty if not set
+	SecretKey() (string, error)
+}
+
+var (
+	_ SecretKeySource = (*Client)(nil)
+	_ SecretKeySource = (*Federation)(nil)
+)
+
+// SetSecretKey set hex aes key to decrypt values with SecretPrefix
+func SetSecretKey(key string) ClientOptionFunc {
+	return func(client *Client) error {
+		if key != "" {
+			client.secretKey = key
+		}
+
+		return nil
+	}
+}
+
+// SetSecretKeyFile read hex aes key from file
+func SetSecretKeyFile(file string) ClientOptionFunc {
+	return func(client *Client) error {
+		if file == "" {
+			return nil
+		}
+
+		key, err := ReadSecretKeyFile(file)
+		if err != nil {
+			return err
+		}
+		client.secretKey = key
+
+		return nil
+	}
+}
+
+// LoadSecretKey load key from SecretKeyEnvName, then the file of SecretKeyFileEnvName
+// returns empty key when both are not set
+func LoadSecretKey() (string, error) {
+	if key := strings.TrimSpace(os.Getenv(SecretKeyEnvName)); key != "" {
+		return key, nil
+	}
+
+	if file := os.Getenv(SecretKeyFileEnvName); file != "" {
+		return ReadSecretKeyFile(file)
+	}
+
+	return "", nil
+}
+
+// ReadSecretKeyFile read hex aes key from file
+func ReadSecretKeyFile(file string) (string, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(buf)), nil
+}
+
+// IsSecret value has SecretPrefix
+func IsSecret(value string) bool {
+	return strings.HasPrefix(value, SecretPrefix)
+}
+
+// EncryptSecret encrypt value with hex aes key by crypto.AESGCMEncrypter
+// gcm instead of the cbc of crypto.AESEncrypter, a tampered value fails instead of decrypting to garbage
+func EncryptSecret(value, key string) (string, error) {
+	if key == "" {
+		return "", ErrSecretKeyNotSet
+	}
+
+	sealed, err := crypto.AESGCMEncrypter(value, key)
+	if err != nil {
+		return "", err
+	}
+
+	return SecretPrefix + SecretVersion + ":" + sealed, nil
+}
+
+// DecryptSecret decrypt value with hex aes key, value without SecretPrefix is returned as is
+// ErrInvalidSecret if the version is unknown or the value is malformed or tampered
+func DecryptSecret(value, key string) (string, error) {
+	if !IsSecret(value) {
+		return value, nil
+	}
+
+	if key == "" {
+		return "", ErrSecretKeyNotSet
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, SecretPrefix), ":", 2)
+	if len(parts) != 2 || parts[0] != SecretVersion {
+		return "", ErrInvalidSecret
+	}
+
+	buf, err := crypto.AESGCMDecrypter(parts[1], key)
+	if err == crypto.ErrCiphertext {
+		return "", ErrInvalidSecret
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
+}
+
+// DecryptSecrets decrypt every string with SecretPrefix in v, v is a pointer
+func DecryptSecrets(v interface{}, key string) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("decrypt target must be non-nil pointer, got %T", v)
+	}
+
+	return decryptValue(rv.Elem(), func() (string, error) {
+		return key, nil
+	})
+}
+
+// decryptValue walk structs, pointers, slices, arrays, maps and interfaces
+// secretKey is only called when a secret is found
+func decryptValue(rv reflect.Value, secretKey func() (string, error)) error {
+	switch rv.Kind() {
+	case reflect.String:
+		if !IsSecret(rv.String()) || !rv.CanSet() {
+			return nil
+		}
+
+		key, err := secretKey()
+		if err != nil {
+			return err
+		}
+
+		value, err := DecryptSecret(rv.String(), key)
+		if err != nil {
+			return err
+		}
+		rv.SetString(value)
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+
+		elem := rv.Elem()
+		if rv.Kind() == reflect.Interface {
+			// values in interfaces are not settable, decrypt a copy
+			copied := reflect.New(elem.Type()).Elem()
+			copied.Set(elem)
+			if err := decryptValue(copied, secretKey); err != nil {
+				return err
+			}
+
+			if rv.CanSet() {
+				rv.Set(copied)
+			}
+			return nil
+		}
+
+		return decryptValue(elem, secretKey)
+	case reflect.Struct:
+		for i := 0; i < rv.NumField(); i++ {
+			if rv.Type().Field(i).PkgPath != "" {
+				continue
+			}
+
+			if err := decryptValue(rv.Field(i), secretKey); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := decryptValue(rv.Index(i), secretKey); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			if err := decryptValue(elem, secretKey); err != nil {
+				return err
+			}
+			rv.SetMapIndex(iter.Key(), elem)
+		}
+	}
+
+	return nil
+}
+
+// SecretKey secret key of SetSecretKey, SetSecretKeyFile or LoadSecretKey
+func (client *Client) SecretKey() (string, error) {
+	return client.secretKey, nil
+}
+
+// decrypt decrypt value of key with client secret key
+func (client *Client) decrypt(key, value string) (string, error) {
+	if !IsSecret(value) {
+		return value, nil
+	}
+
+	value, err := DecryptSecret(value, client.secretKey)
+	if err != nil {
+		return "", &DecodeError{Key: key, Err: err}
+	}
+
+	return value, nil
+}
@@ -0,0 +1,180 @@
+package consul
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testSecretKey = "0F10F6CB2F5369C14D14FA07BAD302267901240CC8C845DD2C645FBD149A11C9"
+
+func TestSecret(t *testing.T) {
+	Convey("secret test", t, func() {
+		encrypted, err := EncryptSecret("123456", testSecretKey)
+		So(err, ShouldBeNil)
+		So(IsSecret(encrypted), ShouldBeTrue)
+
+		Convey("decrypt", func() {
+			value, err := DecryptSecret(encrypted, testSecretKey)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "123456")
+
+			value, err = DecryptSecret("plain", "")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "plain")
+
+			_, err = DecryptSecret(encrypted, "")
+			So(err, ShouldEqual, ErrSecretKeyNotSet)
+
+			_, err = DecryptSecret("enc:bad", testSecretKey)
+			So(err, ShouldEqual, ErrInvalidSecret)
+
+			_, err = DecryptSecret(encrypted[:len(encrypted)-4], testSecretKey)
+			So(err, ShouldEqual, ErrInvalidSecret)
+
+			_, err = DecryptSecret("enc:v0:"+strings.TrimPrefix(encrypted, "enc:v1:"), testSecretKey)
+			So(err, ShouldEqual, ErrInvalidSecret)
+
+			// a tampered byte fails authentication
+			sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, "enc:v1:"))
+			So(err, ShouldBeNil)
+			sealed[len(sealed)-1] ^= 1
+			_, err = DecryptSecret("enc:v1:"+base64.StdEncoding.EncodeToString(sealed), testSecretKey)
+			So(err, ShouldEqual, ErrInvalidSecret)
+
+			_, err = DecryptSecret(encrypted, "1F10F6CB2F5369C14D14FA07BAD302267901240CC8C845DD2C645FBD149A11C9")
+			So(err, ShouldEqual, ErrInvalidSecret)
+		})
+
+		Convey("random nonce", func() {
+			other, err := EncryptSecret("123456", testSecretKey)
+			So(err, ShouldBeNil)
+			So(other, ShouldNotEqual, encrypted)
+			So(other, ShouldStartWith, SecretPrefix+SecretVersion+":")
+		})
+
+		Convey("decrypt secrets", func() {
+			v := struct {
+				Password string
+				Users    []string
+				Secrets  map[string]string
+				Nested   *struct{ Token string }
+			}{
+				Password: encrypted,
+				Users:    []string{"jream", encrypted},
+				Secrets:  map[string]string{"a": encrypted},
+				Nested:   &struct{ Token string }{Token: encrypted},
+			}
+
+			So(DecryptSecrets(&v, testSecretKey), ShouldBeNil)
+			So(v.Password, ShouldEqual, "123456")
+			So(v.Users, ShouldResemble, []string{"jream", "123456"})
+			So(v.Secrets["a"], ShouldEqual, "123456")
+			So(v.Nested.Token, ShouldEqual, "123456")
+		})
+
+		Convey("client get", func() {
+			dir, err := ioutil.TempDir("", "consul-secret")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+
+			keyFile := filepath.Join(dir, "key")
+			So(ioutil.WriteFile(keyFile, []byte(testSecretKey+"\n"), 0600), ShouldBeNil)
+
+			client, err := NewClient(SetFileDir(dir), SetSecretKeyFile(keyFile))
+			So(err, ShouldBeNil)
+
+			key := "service/go/test/secret"
+			So(client.Put(key, encrypted), ShouldBeNil)
+
+			value, err := client.Get(key)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "123456")
+
+			keyPrefix := "service/go/test/secrets/"
+			So(client.Put(keyPrefix+"a", encrypted), ShouldBeNil)
+			So(client.Put(keyPrefix+"b", "plain"), ShouldBeNil)
+
+			kvPairs, err := client.GetValues([]string{key})
+			So(err, ShouldBeNil)
+			So(string(kvPairs[0].Value), ShouldEqual, "123456")
+
+			values, err := client.GetArray(keyPrefix)
+			So(err, ShouldBeNil)
+			So(values, ShouldResemble, []string{"123456", "plain"})
+
+			m, err := client.GetMap(keyPrefix)
+			So(err, ShouldBeNil)
+			So(m, ShouldResemble, map[string]string{"a": "123456", "b": "plain"})
+
+			client, err = NewClient(SetFileDir(dir), SetSecretKey("00"))
+			So(err, ShouldBeNil)
+			_, err = client.Get(key)
+			So(err, ShouldNotBeNil)
+			_, err = client.GetMap(keyPrefix)
+			So(err, ShouldNotBeNil)
+
+			_, err = NewClient(SetSecretKeyFile(filepath.Join(dir, "none")))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("decode value", func() {
+			So(os.Setenv(SecretKeyEnvName, testSecretKey), ShouldBeNil)
+			defer os.Unsetenv(SecretKeyEnvName)
+
+			var config struct {
+				ReadWrite struct {
+					UserID   string
+					Password string
+				}
+			}
+
+			value := "[ReadWrite]\nUserID = \"root\"\nPassword = \"" + encrypted + "\""
+			So(DecodeValue(path.Join(MYSQL, "BGCrawler"), []byte(value), &config), ShouldBeNil)
+			So(config.ReadWrite.UserID, ShouldEqual, "root")
+			So(config.ReadWrite.Password, ShouldEqual, "123456")
+
+			whole, err := EncryptSecret(value, testSecretKey)
+			So(err, ShouldBeNil)
+			config.ReadWrite.Password = ""
+			So(DecodeValue(path.Join(MYSQL, "BGCrawler"), []byte(whole), &config), ShouldBeNil)
+			So(config.ReadWrite.Password, ShouldEqual, "123456")
+
+			So(os.Unsetenv(SecretKeyEnvName), ShouldBeNil)
+			err = DecodeValue(path.Join(MYSQL, "BGCrawler"), []byte(value), &config)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("decode source value", func() {
+			var config struct {
+				ReadWrite struct {
+					Password string
+				}
+			}
+
+			key := path.Join(MYSQL, "BGCrawler")
+			value := "[ReadWrite]\nPassword = \"" + encrypted + "\""
+
+			// the key of the client, not of the env
+			client, err := NewClient(SetSecretKey(testSecretKey))
+			So(err, ShouldBeNil)
+			So(DecodeSourceValue(client, key, []byte(value), &config), ShouldBeNil)
+			So(config.ReadWrite.Password, ShouldEqual, "123456")
+
+			federation, err := NewFederation([]Datacenter{{Name: "dc1"}}, SetSecretKey(testSecretKey))
+			So(err, ShouldBeNil)
+			config.ReadWrite.Password = ""
+			So(DecodeSourceValue(federation, key, []byte(value), &config), ShouldBeNil)
+			So(config.ReadWrite.Password, ShouldEqual, "123456")
+
+			err = DecodeSourceValue(NewMemorySource(nil), key, []byte(value), &config)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
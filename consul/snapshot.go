@@ -0,0 +1,284 @@
+package consul
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// SnapshotDirEnvName snapshot dir env, same as SetSnapshotDir
+	SnapshotDirEnvName = "JKIT_CONSUL_SNAPSHOT_DIR"
+	// FileDirEnvName file dir env, same as SetFileDir
+	FileDirEnvName = "JKIT_CONSUL_FILE_DIR"
+)
+
+// ErrInvalidKey key escapes the dir
+var ErrInvalidKey = errors.New("consul: invalid key")
+
+// SetSnapshotDir persist every value read from consul into dir
+// when consul is unreachable, values are read from dir
+func SetSnapshotDir(dir string) ClientOptionFunc {
+	return func(client *Client) error {
+		if dir != "" {
+			client.snapshotDir = dir
+		}
+
+		return nil
+	}
+}
+
+// SetFileDir read and write keys from dir only, no consul at all
+// the file of key conn/v1/mysql/BGCrawler is <dir>/conn/v1/mysql/BGCrawler
+func SetFileDir(dir string) ClientOptionFunc {
+	return func(client *Client) error {
+		if dir != "" {
+			client.fileDir = dir
+		}
+
+		return nil
+	}
+}
+
+// saveSnapshot save value of key, failure only logged
+func (client *Client) saveSnapshot(key string, value []byte) {
+	if client.snapshotDir == "" {
+		return
+	}
+
+	if err := writeFileValue(client.snapshotDir, key, value); err != nil {
+		log.Printf("Failed on consul snapshot save, key: %v, err: %v \r\n", key, err)
+	}
+}
+
+// loadSnapshot value of key when consul is unreachable
+func (client *Client) loadSnapshot(key string, consulErr error) (string, bool) {
+	if client.snapshotDir == "" {
+		return "", false
+	}
+
+	value, modTime, err := readFileValue(client.snapshotDir, key)
+	if err != nil {
+		return "", false
+	}
+
+	log.Printf("Consul unreachable, use snapshot: %v, stale: %v, err: %v \r\n", key, time.Since(modTime), consulErr)
+	return value, true
+}
+
+// loadSnapshotKeys child keys when consul is unreachable
+func (client *Client) loadSnapshotKeys(keyPrefix string, consulErr error) ([]string, bool) {
+	if client.snapshotDir == "" {
+		return nil, false
+	}
+
+	keys, err := listFileKeys(client.snapshotDir, keyPrefix)
+	if err != nil {
+		return nil, false
+	}
+
+	log.Printf("Consul unreachable, use snapshot keys: %v, err: %v \r\n", keyPrefix, consulErr)
+	return keys, true
+}
+
+// loadSnapshotKVPairs kv pairs under keyPrefix when consul is unreachable
+func (client *Client) loadSnapshotKVPairs(keyPrefix string, consulErr error) (api.KVPairs, bool) {
+	if client.snapshotDir == "" {
+		return nil, false
+	}
+
+	kvPairs, err := listFileKVPairs(client.snapshotDir, keyPrefix)
+	if err != nil || len(kvPairs) == 0 {
+		return nil, false
+	}
+
+	log.Printf("Consul unreachable, use snapshot kv pairs: %v, err: %v \r\n", keyPrefix, consulErr)
+	return kvPairs, true
+}
+
+func getFileValues(dir string, keys []string) (api.KVPairs, error) {
+	kvPairs := make(api.KVPairs, len(keys))
+	for i := range keys {
+		value, _, err := readFileValue(dir, keys[i])
+		if err != nil {
+			return nil, err
+		}
+
+		kvPairs[i] = &api.KVPair{
+			Key:   keys[i],
+			Value: []byte(value),
+		}
+	}
+
+	return kvPairs, nil
+}
+
+func keyPath(dir, key string) (string, error) {
+	p := filepath.Join(dir, filepath.FromSlash(key))
+	if p != filepath.Clean(dir) && !strings.HasPrefix(p, filepath.Clean(dir)+string(filepath.Separator)) {
+		return "", ErrInvalidKey
+	}
+
+	return p, nil
+}
+
+func readFileValue(dir, key string) (string, time.Time, error) {
+	p, err := keyPath(dir, key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	fi, err := os.Stat(p)
+	if err != nil || fi.IsDir() {
+		return "", time.Time{}, fmt.Errorf(KeyNotExist, key)
+	}
+
+	buf, err := ioutil.ReadFile(p)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return string(buf), fi.ModTime(), nil
+}
+
+// writeFileValue write to temp file then rename, readers never see half values
+func writeFileValue(dir, key string, value []byte) error {
+	p, err := keyPath(dir, key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(p), ".snapshot-")
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(value); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), p)
+}
+
+func deleteFileValue(dir, key string) error {
+	p, err := keyPath(dir, key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// listFileKeys child keys like consul keys with separator "/", dirs end with "/"
+func listFileKeys(dir, keyPrefix string) ([]string, error) {
+	if !strings.HasSuffix(keyPrefix, "/") {
+		keyPrefix += "/"
+	}
+
+	p, err := keyPath(dir, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	fis, err := ioutil.ReadDir(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf(DirNotExist, keyPrefix)
+		}
+
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+
+		if fi.IsDir() {
+			keys = append(keys, keyPrefix+fi.Name()+"/")
+			continue
+		}
+
+		keys = append(keys, keyPrefix+fi.Name())
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// listFileKVPairs kv pairs of files under keyPrefix recursively, like kv list
+// keyPrefix itself is included when it is a file
+func listFileKVPairs(dir, keyPrefix string) (api.KVPairs, error) {
+	keyPrefix = strings.TrimSuffix(keyPrefix, "/")
+	root, err := keyPath(dir, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var kvPairs api.KVPairs
+	err = filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		if p != root && strings.HasPrefix(fi.Name(), ".") {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if fi.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		key := keyPrefix
+		if rel != "." {
+			key = keyPrefix + "/" + filepath.ToSlash(rel)
+		}
+
+		buf, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		kvPairs = append(kvPairs, &api.KVPair{Key: key, Value: buf})
+		return nil
+	})
+
+	return kvPairs, err
+}
@@ -0,0 +1,134 @@
+package consul
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFileDir(t *testing.T) {
+	Convey("file dir test", t, func() {
+		dir, err := ioutil.TempDir("", "consul-file")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		client, err := NewClient(SetFileDir(dir))
+		So(err, ShouldBeNil)
+
+		key := path.Join(MYSQL, "BGCrawler-Test")
+		So(client.Put(key, mysqlToml), ShouldBeNil)
+		So(client.Put(path.Join(MYSQL, "BGCrawler-Other"), mysqlToml), ShouldBeNil)
+		So(client.Put(path.Join(MYSQL, "sub", "a"), "a"), ShouldBeNil)
+
+		value, err := client.Get(key)
+		So(err, ShouldBeNil)
+		So(value, ShouldEqual, mysqlToml)
+
+		_, err = client.Get(path.Join(MYSQL, "None"))
+		So(err, ShouldNotBeNil)
+
+		_, err = client.Get("../../etc/passwd")
+		So(err, ShouldEqual, ErrInvalidKey)
+
+		keys, err := client.GetChildKeys(MYSQL)
+		So(err, ShouldBeNil)
+		So(keys, ShouldResemble, []string{
+			path.Join(MYSQL, "BGCrawler-Other"),
+			path.Join(MYSQL, "BGCrawler-Test"),
+			path.Join(MYSQL, "sub") + "/",
+		})
+
+		kvPairs, err := client.GetValues(keys[:2])
+		So(err, ShouldBeNil)
+		So(len(kvPairs), ShouldEqual, 2)
+
+		var configs struct {
+			Sub map[string]string
+		}
+		So(client.Decode(MYSQL, &configs), ShouldBeNil)
+		So(configs.Sub, ShouldResemble, map[string]string{"a": "a"})
+
+		var config mysqlConfig
+		So(client.Decode(key, &config), ShouldBeNil)
+		So(config.DBName, ShouldEqual, "crawler")
+
+		So(client.Delete(key), ShouldBeNil)
+		_, err = client.Get(key)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	Convey("snapshot test", t, func() {
+		dir, err := ioutil.TempDir("", "consul-snapshot")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		key := path.Join(MYSQL, "BGCrawler-Test")
+		So(os.MkdirAll(filepath.Join(dir, MYSQL), 0755), ShouldBeNil)
+		So(ioutil.WriteFile(filepath.Join(dir, key), []byte(mysqlToml), 0644), ShouldBeNil)
+
+		Convey("consul unreachable", func() {
+			client, err := NewClient(SetAddress("127.0.0.1:1"), SetSnapshotDir(dir))
+			So(err, ShouldBeNil)
+
+			value, err := client.Get(key)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, mysqlToml)
+
+			keys, err := client.GetChildKeys(MYSQL)
+			So(err, ShouldBeNil)
+			So(keys, ShouldResemble, []string{key})
+
+			kvPairs, err := client.GetValues(keys)
+			So(err, ShouldBeNil)
+			So(string(kvPairs[0].Value), ShouldEqual, mysqlToml)
+
+			var configs map[string]mysqlConfig
+			So(client.Decode(MYSQL, &configs), ShouldBeNil)
+			So(configs["BGCrawler-Test"].DBName, ShouldEqual, "crawler")
+
+			_, err = client.Get(path.Join(MYSQL, "None"))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("no snapshot", func() {
+			client, err := NewClient(SetAddress("127.0.0.1:1"))
+			So(err, ShouldBeNil)
+
+			_, err = client.Get(key)
+			So(err, ShouldNotBeNil)
+
+			var configs map[string]mysqlConfig
+			So(client.Decode(MYSQL, &configs), ShouldNotBeNil)
+		})
+
+		Convey("save snapshot", func() {
+			_, server := newFakeKV()
+			defer server.Close()
+
+			client, err := NewClient(SetAddress(strings.TrimPrefix(server.URL, "http://")), SetSnapshotDir(dir))
+			So(err, ShouldBeNil)
+
+			snapshotKey := path.Join(Zookeeper, "Snapshot-Test")
+			So(client.Put(snapshotKey, zookeeperToml), ShouldBeNil)
+			_, err = client.Get(snapshotKey)
+			So(err, ShouldBeNil)
+
+			buf, err := ioutil.ReadFile(filepath.Join(dir, snapshotKey))
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, zookeeperToml)
+
+			// the saved snapshot serves reads after consul is gone
+			server.Close()
+			value, err := client.Get(snapshotKey)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, zookeeperToml)
+		})
+	})
+}
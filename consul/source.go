@@ -0,0 +1,301 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const _defaultPollInterval = 5 * time.Second
+
+// ConfigSource config source, keys are laid out as in consul, eg. conn/v1/mysql/BGCrawler
+type ConfigSource interface {
+	// Get value of key
+	Get(key string) (string, error)
+	// List child keys of keyPrefix, one level like GetChildKeys
+	List(keyPrefix string) ([]string, error)
+	// Watch call handle with the new value on every change of key, block until ctx done
+	Watch(ctx context.Context, key string, handle func(value string)) error
+}
+
+var (
+	_ ConfigSource = (*Client)(nil)
+	_ ConfigSource = (*DirSource)(nil)
+	_ ConfigSource = (*EnvSource)(nil)
+	_ ConfigSource = (*MemorySource)(nil)
+)
+
+// List child keys, same as GetChildKeys
+func (client *Client) List(keyPrefix string) ([]string, error) {
+	return client.GetChildKeys(keyPrefix)
+}
+
+// Watch watch key until ctx done, values with SecretPrefix are decrypted
+func (client *Client) Watch(ctx context.Context, key string, handle func(value string)) error {
+	decryptHandle := func(value string) {
+		value, err := client.decrypt(key, value)
+		if err != nil {
+			log.Printf("Failed on consul Watch decrypt, key: %v, err: %v \r\n", key, err)
+			return
+		}
+
+		handle(value)
+	}
+
+	if client.fileDir != "" {
+		return NewDirSource(client.fileDir, _defaultPollInterval).Watch(ctx, key, decryptHandle)
+	}
+
+	w := NewConfigWatcher(client.config)
+	w.AddKey(key, func(idx uint64, kvPair *api.KVPair) {
+		decryptHandle(string(kvPair.Value))
+	})
+
+	return w.Start(ctx)
+}
+
+// DirSource local dir source, the file of key conn/v1/mysql/BGCrawler is <dir>/conn/v1/mysql/BGCrawler
+type DirSource struct {
+	dir          string
+	pollInterval time.Duration
+}
+
+// NewDirSource new dir source, Watch polls file every pollInterval
+func NewDirSource(dir string, pollInterval time.Duration) *DirSource {
+	if pollInterval <= 0 {
+		pollInterval = _defaultPollInterval
+	}
+
+	return &DirSource{
+		dir:          dir,
+		pollInterval: pollInterval,
+	}
+}
+
+// Get get value
+func (source *DirSource) Get(key string) (string, error) {
+	value, _, err := readFileValue(source.dir, key)
+	return value, err
+}
+
+// List list child keys
+func (source *DirSource) List(keyPrefix string) ([]string, error) {
+	return listFileKeys(source.dir, keyPrefix)
+}
+
+// Watch poll the file of key until ctx done
+func (source *DirSource) Watch(ctx context.Context, key string, handle func(value string)) error {
+	_, modTime, _ := readFileValue(source.dir, key)
+
+	ticker := time.NewTicker(source.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			value, t, err := readFileValue(source.dir, key)
+			if err != nil || t.Equal(modTime) {
+				continue
+			}
+
+			modTime = t
+			handle(value)
+		}
+	}
+}
+
+var envNameReplacer = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+// EnvSource environment variables source
+// key conn/v1/mysql/BGCrawler with prefix JKIT is env JKIT_CONN_V1_MYSQL_BGCRAWLER
+// List returns keys with the upper case env suffix, eg. conn/v1/mysql/BGCRAWLER
+type EnvSource struct {
+	prefix string
+}
+
+// NewEnvSource new env source, prefix is optional
+func NewEnvSource(prefix string) *EnvSource {
+	return &EnvSource{
+		prefix: prefix,
+	}
+}
+
+func (source *EnvSource) envName(key string) string {
+	key = strings.Trim(key, "/")
+	if source.prefix != "" {
+		key = source.prefix + "_" + key
+	}
+
+	return strings.ToUpper(envNameReplacer.ReplaceAllString(key, "_"))
+}
+
+// Get get value
+func (source *EnvSource) Get(key string) (string, error) {
+	value, ok := os.LookupEnv(source.envName(key))
+	if !ok {
+		return "", fmt.Errorf(KeyNotExist, key)
+	}
+
+	return value, nil
+}
+
+// List list child keys
+func (source *EnvSource) List(keyPrefix string) ([]string, error) {
+	keyPrefix = strings.TrimSuffix(keyPrefix, "/")
+	envPrefix := source.envName(keyPrefix) + "_"
+
+	var keys []string
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if strings.HasPrefix(name, envPrefix) && len(name) > len(envPrefix) {
+			keys = append(keys, path.Join(keyPrefix, strings.TrimPrefix(name, envPrefix)))
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Watch env never changes, block until ctx done
+func (source *EnvSource) Watch(ctx context.Context, key string, handle func(value string)) error {
+	<-ctx.Done()
+	return nil
+}
+
+// MemorySource in memory source, for tests
+type MemorySource struct {
+	kv       map[string]string
+	watchers map[string][]*memoryWatcher
+	rwMutex  sync.RWMutex
+}
+
+type memoryWatcher struct {
+	values chan string
+	done   chan struct{}
+}
+
+// NewMemorySource new memory source with initial kv
+func NewMemorySource(kv map[string]string) *MemorySource {
+	source := &MemorySource{
+		kv:       make(map[string]string, len(kv)),
+		watchers: make(map[string][]*memoryWatcher),
+	}
+
+	for k, v := range kv {
+		source.kv[k] = v
+	}
+
+	return source
+}
+
+// Get get value
+func (source *MemorySource) Get(key string) (string, error) {
+	source.rwMutex.RLock()
+	defer source.rwMutex.RUnlock()
+
+	value, ok := source.kv[key]
+	if !ok {
+		return "", fmt.Errorf(KeyNotExist, key)
+	}
+
+	return value, nil
+}
+
+// List list child keys, dirs end with "/"
+func (source *MemorySource) List(keyPrefix string) ([]string, error) {
+	if !strings.HasSuffix(keyPrefix, "/") {
+		keyPrefix += "/"
+	}
+
+	source.rwMutex.RLock()
+	defer source.rwMutex.RUnlock()
+
+	seen := make(map[string]struct{})
+	var keys []string
+	for key := range source.kv {
+		if !strings.HasPrefix(key, keyPrefix) || key == keyPrefix {
+			continue
+		}
+
+		child := keyPrefix + strings.SplitN(strings.TrimPrefix(key, keyPrefix), "/", 2)[0]
+		if child != key {
+			child += "/"
+		}
+
+		if _, ok := seen[child]; !ok {
+			seen[child] = struct{}{}
+			keys = append(keys, child)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Set set value and notify watchers
+func (source *MemorySource) Set(key, value string) {
+	source.rwMutex.Lock()
+	source.kv[key] = value
+	watchers := source.watchers[key]
+	source.rwMutex.Unlock()
+
+	for _, watcher := range watchers {
+		select {
+		case watcher.values <- value:
+		case <-watcher.done:
+		}
+	}
+}
+
+// Delete delete key
+func (source *MemorySource) Delete(key string) {
+	source.rwMutex.Lock()
+	delete(source.kv, key)
+	source.rwMutex.Unlock()
+}
+
+// Watch handle every Set of key until ctx done
+func (source *MemorySource) Watch(ctx context.Context, key string, handle func(value string)) error {
+	watcher := &memoryWatcher{
+		values: make(chan string),
+		done:   make(chan struct{}),
+	}
+
+	source.rwMutex.Lock()
+	source.watchers[key] = append(source.watchers[key], watcher)
+	source.rwMutex.Unlock()
+
+	defer func() {
+		close(watcher.done)
+
+		source.rwMutex.Lock()
+		watchers := source.watchers[key]
+		for i := range watchers {
+			if watchers[i] == watcher {
+				source.watchers[key] = append(watchers[:i:i], watchers[i+1:]...)
+				break
+			}
+		}
+		source.rwMutex.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case value := <-watcher.values:
+			handle(value)
+		}
+	}
+}
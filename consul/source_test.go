@@ -0,0 +1,176 @@
+package consul
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMemorySource(t *testing.T) {
+	Convey("memory source test", t, func() {
+		key := path.Join(MYSQL, "BGCrawler-Test")
+		source := NewMemorySource(map[string]string{
+			key:                          mysqlToml,
+			path.Join(MYSQL, "sub", "a"): "a",
+			path.Join(Kafka, "Zipkin"):   kafkaToml,
+		})
+
+		value, err := source.Get(key)
+		So(err, ShouldBeNil)
+		So(value, ShouldEqual, mysqlToml)
+
+		_, err = source.Get(path.Join(MYSQL, "None"))
+		So(err, ShouldNotBeNil)
+
+		keys, err := source.List(MYSQL)
+		So(err, ShouldBeNil)
+		So(keys, ShouldResemble, []string{key, path.Join(MYSQL, "sub") + "/"})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		values := make(chan string, 1)
+		done := make(chan struct{})
+		go func() {
+			source.Watch(ctx, key, func(value string) {
+				values <- value
+			})
+			close(done)
+		}()
+
+		// wait for the watcher to be registered
+		for {
+			source.rwMutex.RLock()
+			n := len(source.watchers[key])
+			source.rwMutex.RUnlock()
+			if n > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		source.Set(key, "changed")
+		So(<-values, ShouldEqual, "changed")
+
+		cancel()
+		<-done
+		source.Set(key, "not watched")
+
+		source.Delete(key)
+		_, err = source.Get(key)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestClientWatch(t *testing.T) {
+	Convey("client watch test", t, func() {
+		key := "service/go/test/watch"
+		dc := &fakeDatacenter{kv: map[string]string{key: "v1"}}
+		server := httptest.NewServer(dc)
+		defer server.Close()
+
+		client, err := NewClient(
+			SetAddress(strings.TrimPrefix(server.URL, "http://")),
+			SetDatacenter("dc1"),
+			SetToken("token1"),
+			SetNamespace("team"),
+		)
+		So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		values := make(chan string, 1)
+		done := make(chan struct{})
+		go func() {
+			client.Watch(ctx, key, func(value string) {
+				values <- value
+			})
+			close(done)
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		dc.set(key, "v2")
+		So(<-values, ShouldEqual, "v2")
+
+		cancel()
+		<-done
+
+		// every watch request carries the datacenter, token and namespace of the client
+		dc.mutex.Lock()
+		defer dc.mutex.Unlock()
+		So(len(dc.queries), ShouldBeGreaterThan, 1)
+		for _, query := range dc.queries {
+			So(query, ShouldEqual, "dc1|token1|team")
+		}
+	})
+}
+
+func TestDirSource(t *testing.T) {
+	Convey("dir source test", t, func() {
+		dir, err := ioutil.TempDir("", "consul-source")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		key := path.Join(MYSQL, "BGCrawler-Test")
+		So(writeFileValue(dir, key, []byte(mysqlToml)), ShouldBeNil)
+
+		source := NewDirSource(dir, 10*time.Millisecond)
+		value, err := source.Get(key)
+		So(err, ShouldBeNil)
+		So(value, ShouldEqual, mysqlToml)
+
+		keys, err := source.List(MYSQL)
+		So(err, ShouldBeNil)
+		So(keys, ShouldResemble, []string{key})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		values := make(chan string, 1)
+		go source.Watch(ctx, key, func(value string) {
+			select {
+			case values <- value:
+			default:
+			}
+		})
+
+		time.Sleep(50 * time.Millisecond)
+		So(writeFileValue(dir, key, []byte("changed")), ShouldBeNil)
+		future := time.Now().Add(time.Hour)
+		So(os.Chtimes(filepath.Join(dir, key), future, future), ShouldBeNil)
+
+		select {
+		case value = <-values:
+		case <-ctx.Done():
+		}
+		So(value, ShouldEqual, "changed")
+	})
+}
+
+func TestEnvSource(t *testing.T) {
+	Convey("env source test", t, func() {
+		So(os.Setenv("JKIT_TEST_CONN_V1_MYSQL_BGCRAWLER", mysqlToml), ShouldBeNil)
+		defer os.Unsetenv("JKIT_TEST_CONN_V1_MYSQL_BGCRAWLER")
+
+		source := NewEnvSource("JKIT_TEST")
+		value, err := source.Get(path.Join(MYSQL, "BGCrawler"))
+		So(err, ShouldBeNil)
+		So(value, ShouldEqual, mysqlToml)
+
+		_, err = source.Get(path.Join(MYSQL, "None"))
+		So(err, ShouldNotBeNil)
+
+		keys, err := source.List(MYSQL)
+		So(err, ShouldBeNil)
+		So(keys, ShouldResemble, []string{path.Join(MYSQL, "BGCRAWLER")})
+
+		value, err = source.Get(keys[0])
+		So(err, ShouldBeNil)
+		So(value, ShouldEqual, mysqlToml)
+	})
+}
@@ -0,0 +1,169 @@
+package consul
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// MaxTxnOps consul limit of ops in one transaction
+const MaxTxnOps = 64
+
+// TxnError failed transaction, OpIndex of Errors is the index in the whole Txn
+// Committed ops before the failed chunk are already applied
+type TxnError struct {
+	Errors    api.TxnErrors
+	Committed int
+}
+
+func (e *TxnError) Error() string {
+	whats := make([]string, len(e.Errors))
+	for i := range e.Errors {
+		whats[i] = fmt.Sprintf("op %d: %s", e.Errors[i].OpIndex, e.Errors[i].What)
+	}
+
+	return fmt.Sprintf("consul txn failed, committed %d ops: %s", e.Committed, strings.Join(whats, "; "))
+}
+
+// PutCAS put kv if the key's ModifyIndex is index, index=0 put only if key not exists
+func (client *Client) PutCAS(key, value string, index uint64) (bool, error) {
+	pair := &api.KVPair{
+		Key:         key,
+		Value:       []byte(value),
+		ModifyIndex: index,
+	}
+
+	ok, _, err := client.KV().CAS(pair, nil)
+	return ok, err
+}
+
+// DeleteCAS delete kv if the key's ModifyIndex is index
+func (client *Client) DeleteCAS(key string, index uint64) (bool, error) {
+	pair := &api.KVPair{
+		Key:         key,
+		ModifyIndex: index,
+	}
+
+	ok, _, err := client.KV().DeleteCAS(pair, nil)
+	return ok, err
+}
+
+// DeleteTree delete all keys under prefix
+func (client *Client) DeleteTree(prefix string) error {
+	_, err := client.KV().DeleteTree(prefix, nil)
+
+	return err
+}
+
+// Txn kv transaction builder
+// more than MaxTxnOps ops are split into several transactions, each one is atomic
+type Txn struct {
+	client *Client
+	ops    api.KVTxnOps
+}
+
+// Txn new transaction
+func (client *Client) Txn() *Txn {
+	return &Txn{
+		client: client,
+	}
+}
+
+func (txn *Txn) add(op *api.KVTxnOp) *Txn {
+	txn.ops = append(txn.ops, op)
+	return txn
+}
+
+// Set set kv
+func (txn *Txn) Set(key, value string) *Txn {
+	return txn.add(&api.KVTxnOp{Verb: api.KVSet, Key: key, Value: []byte(value)})
+}
+
+// CAS set kv if the key's ModifyIndex is index
+func (txn *Txn) CAS(key, value string, index uint64) *Txn {
+	return txn.add(&api.KVTxnOp{Verb: api.KVCAS, Key: key, Value: []byte(value), Index: index})
+}
+
+// Get get kv, fails the transaction if the key does not exist
+func (txn *Txn) Get(keys ...string) *Txn {
+	for i := range keys {
+		txn.add(&api.KVTxnOp{Verb: api.KVGet, Key: keys[i]})
+	}
+
+	return txn
+}
+
+// CheckIndex fails the transaction if the key's ModifyIndex is not index
+func (txn *Txn) CheckIndex(key string, index uint64) *Txn {
+	return txn.add(&api.KVTxnOp{Verb: api.KVCheckIndex, Key: key, Index: index})
+}
+
+// CheckNotExists fails the transaction if the key exists
+func (txn *Txn) CheckNotExists(key string) *Txn {
+	return txn.add(&api.KVTxnOp{Verb: api.KVCheckNotExists, Key: key})
+}
+
+// Delete delete kv
+func (txn *Txn) Delete(key string) *Txn {
+	return txn.add(&api.KVTxnOp{Verb: api.KVDelete, Key: key})
+}
+
+// DeleteCAS delete kv if the key's ModifyIndex is index
+func (txn *Txn) DeleteCAS(key string, index uint64) *Txn {
+	return txn.add(&api.KVTxnOp{Verb: api.KVDeleteCAS, Key: key, Index: index})
+}
+
+// DeleteTree delete all keys under prefix
+func (txn *Txn) DeleteTree(prefix string) *Txn {
+	return txn.add(&api.KVTxnOp{Verb: api.KVDeleteTree, Key: prefix})
+}
+
+// Len ops count
+func (txn *Txn) Len() int {
+	return len(txn.ops)
+}
+
+// Commit commit ops by MaxTxnOps, stop at the first failed transaction
+func (txn *Txn) Commit() (api.KVPairs, error) {
+	var results api.KVPairs
+	committed := 0
+
+	for _, ops := range splitTxnOps(txn.ops, MaxTxnOps) {
+		ok, resp, _, err := txn.client.KV().Txn(ops, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok || len(resp.Errors) != 0 {
+			txnErr := &TxnError{Committed: committed}
+			for _, e := range resp.Errors {
+				txnErr.Errors = append(txnErr.Errors, &api.TxnError{
+					OpIndex: e.OpIndex + committed,
+					What:    e.What,
+				})
+			}
+
+			return nil, txnErr
+		}
+
+		results = append(results, resp.Results...)
+		committed += len(ops)
+	}
+
+	return results, nil
+}
+
+func splitTxnOps(ops api.KVTxnOps, size int) []api.KVTxnOps {
+	var chunks []api.KVTxnOps
+	for start := 0; start < len(ops); start += size {
+		end := start + size
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		chunks = append(chunks, ops[start:end])
+	}
+
+	return chunks
+}
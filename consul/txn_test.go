@@ -0,0 +1,135 @@
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeTxn fake consul txn endpoint, ops on key "fail" fail the transaction
+type fakeTxn struct {
+	mutex sync.Mutex
+	sizes []int
+}
+
+func (f *fakeTxn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/txn" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var ops []struct {
+		KV *api.KVTxnOp
+	}
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mutex.Lock()
+	f.sizes = append(f.sizes, len(ops))
+	f.mutex.Unlock()
+
+	var results []map[string]*api.KVPair
+	var errors []map[string]interface{}
+	for i, op := range ops {
+		if op.KV.Key == "fail" {
+			errors = append(errors, map[string]interface{}{"OpIndex": i, "What": "failed"})
+			continue
+		}
+		results = append(results, map[string]*api.KVPair{"KV": {Key: op.KV.Key, Value: op.KV.Value}})
+	}
+
+	if len(errors) > 0 {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{"Errors": errors})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"Results": results})
+}
+
+func TestTxn(t *testing.T) {
+	Convey("txn test", t, func() {
+		fake := &fakeTxn{}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		client, err := NewClient(SetAddress(strings.TrimPrefix(server.URL, "http://")))
+		So(err, ShouldBeNil)
+
+		Convey("split by max ops", func() {
+			txn := client.Txn()
+			for i := 0; i < 150; i++ {
+				txn.Set(fmt.Sprintf("service/go/test/%d", i), "v")
+			}
+			So(txn.Len(), ShouldEqual, 150)
+
+			results, err := txn.Commit()
+			So(err, ShouldBeNil)
+			So(len(results), ShouldEqual, 150)
+			So(fake.sizes, ShouldResemble, []int{64, 64, 22})
+		})
+
+		Convey("failed", func() {
+			txn := client.Txn()
+			for i := 0; i < 70; i++ {
+				txn.CheckIndex(fmt.Sprintf("service/go/test/%d", i), 1)
+			}
+			txn.Delete("fail")
+
+			_, err := txn.Commit()
+			So(err, ShouldNotBeNil)
+			txnErr, ok := err.(*TxnError)
+			So(ok, ShouldBeTrue)
+			So(txnErr.Committed, ShouldEqual, 64)
+			So(txnErr.Errors[0].OpIndex, ShouldEqual, 70)
+			So(err.Error(), ShouldContainSubstring, "op 70: failed")
+		})
+
+		Convey("get values", func() {
+			kvPairs, err := client.GetValues([]string{"a", "b"})
+			So(err, ShouldBeNil)
+			So(len(kvPairs), ShouldEqual, 2)
+
+			_, err = client.GetValues([]string{"a", "fail"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("cas test", t, func() {
+		_, server := newFakeKV()
+		defer server.Close()
+
+		client, err := NewClient(SetAddress(strings.TrimPrefix(server.URL, "http://")))
+		So(err, ShouldBeNil)
+
+		key := "service/go/test/cas"
+		So(client.Put(key, "v1"), ShouldBeNil)
+		kvPair, _, err := client.KV().Get(key, nil)
+		So(err, ShouldBeNil)
+
+		ok, err := client.PutCAS(key, "v2", kvPair.ModifyIndex)
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeTrue)
+
+		ok, err = client.PutCAS(key, "v3", kvPair.ModifyIndex)
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeFalse)
+
+		ok, err = client.DeleteCAS(key, kvPair.ModifyIndex)
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeFalse)
+
+		So(client.DeleteTree("service/go/test/"), ShouldBeNil)
+		_, err = client.Get(key)
+		So(err, ShouldNotBeNil)
+	})
+}
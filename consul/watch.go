@@ -1,28 +1,86 @@
 package consul
 
 import (
+	"context"
+	"errors"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/watch"
 )
 
-// WatchKey watch key
-func WatchKey(consulAddr, key string, handle func(*api.KVPair)) {
-	plan, err := watch.Parse(map[string]interface{}{
-		"type": "key",
-		"key":  key,
-	})
+// ErrWatcherRunning watcher already running
+var ErrWatcherRunning = errors.New("consul watcher is already running")
 
-	if err != nil {
-		panic(err)
+// Watcher watch several keys and key prefixes on one consul agent
+type Watcher struct {
+	consulAddr string
+	config     *api.Config
+	entries    []watchEntry
+	plans      []*watch.Plan
+	running    bool
+	done       chan struct{}
+	stopOnce   sync.Once
+	mutex      sync.Mutex
+}
+
+type watchEntry struct {
+	params  map[string]interface{}
+	handler watch.HandlerFunc
+}
+
+// WatchOptionFunc watch option func
+type WatchOptionFunc func(*watchOptions)
+
+type watchOptions struct {
+	initial bool
+}
+
+// WithInitial deliver the initial snapshot, by default the first callback is dropped
+func WithInitial() WatchOptionFunc {
+	return func(opts *watchOptions) {
+		opts.initial = true
+	}
+}
+
+func newWatchOptions(opts []WatchOptionFunc) watchOptions {
+	var options watchOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return options
+}
+
+// NewWatcher new watcher
+func NewWatcher(consulAddr string) *Watcher {
+	return &Watcher{
+		consulAddr: consulAddr,
+		done:       make(chan struct{}),
+	}
+}
+
+// NewConfigWatcher new watcher of config, plans run with its token, datacenter, namespace, partition and tls
+func NewConfigWatcher(config *api.Config) *Watcher {
+	return &Watcher{
+		consulAddr: config.Address,
+		config:     config,
+		done:       make(chan struct{}),
 	}
+}
 
-	first := true
-	plan.Handler = func(idx uint64, raw interface{}) {
+// AddKey watch key, handle is called with the consul index on every change
+func (w *Watcher) AddKey(key string, handle func(uint64, *api.KVPair), opts ...WatchOptionFunc) {
+	options := newWatchOptions(opts)
+	skip := !options.initial
+	w.add(map[string]interface{}{
+		"type": "key",
+		"key":  key,
+	}, func(idx uint64, raw interface{}) {
 		// when first run, key exist and do handler
-		if first {
-			first = false
+		if skip {
+			skip = false
 			return
 		}
 
@@ -32,34 +90,25 @@ func WatchKey(consulAddr, key string, handle func(*api.KVPair)) {
 
 		v, ok := raw.(*api.KVPair)
 		if ok && v != nil {
-			handle(v)
+			handle(idx, v)
 		}
-	}
-
-	err = plan.Run(consulAddr)
-	if err != nil {
-		panic(err)
-	}
+	})
 }
 
-// WatchKeyPrefix watch dir
-func WatchKeyPrefix(consulAddr, key string, handle func(api.KVPairs)) {
-	if !strings.HasSuffix(key, "/") {
-		key += "/"
+// AddKeyPrefix watch dir, handle is called with the consul index on every change
+func (w *Watcher) AddKeyPrefix(prefix string, handle func(uint64, api.KVPairs), opts ...WatchOptionFunc) {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
 	}
 
-	plan, err := watch.Parse(map[string]interface{}{
+	options := newWatchOptions(opts)
+	skip := !options.initial
+	w.add(map[string]interface{}{
 		"type":   "keyprefix",
-		"prefix": key,
-	})
-	if err != nil {
-		panic(err)
-	}
-
-	first := true
-	plan.Handler = func(idx uint64, raw interface{}) {
-		if first {
-			first = false
+		"prefix": prefix,
+	}, func(idx uint64, raw interface{}) {
+		if skip {
+			skip = false
 			return
 		}
 
@@ -69,12 +118,136 @@ func WatchKeyPrefix(consulAddr, key string, handle func(api.KVPairs)) {
 
 		v, ok := raw.(api.KVPairs)
 		if ok && len(v) > 0 {
-			handle(v)
+			handle(idx, v)
 		}
+	})
+}
+
+func (w *Watcher) add(params map[string]interface{}, handler watch.HandlerFunc) {
+	w.mutex.Lock()
+	w.entries = append(w.entries, watchEntry{
+		params:  params,
+		handler: handler,
+	})
+	w.mutex.Unlock()
+}
+
+// Start run all watches, block until ctx is done, Stop is called or a watch fails
+func (w *Watcher) Start(ctx context.Context) error {
+	plans, err := w.prepare()
+	if err != nil || len(plans) == 0 {
+		return err
 	}
 
-	err = plan.Run(consulAddr)
-	if err != nil {
+	errc := make(chan error, len(plans))
+	for i := range plans {
+		go func(plan *watch.Plan) {
+			errc <- plan.RunWithConfig(w.consulAddr, w.planConfig())
+		}(plans[i])
+	}
+
+	pending := len(plans)
+	select {
+	case <-ctx.Done():
+	case <-w.done:
+	case err = <-errc:
+		pending--
+	}
+
+	w.Stop()
+	for ; pending > 0; pending-- {
+		<-errc
+	}
+
+	return err
+}
+
+func (w *Watcher) prepare() ([]*watch.Plan, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	select {
+	case <-w.done:
+		return nil, nil
+	default:
+	}
+
+	if w.running {
+		return nil, ErrWatcherRunning
+	}
+
+	plans := make([]*watch.Plan, len(w.entries))
+	for i := range w.entries {
+		plan, err := watch.Parse(w.entries[i].params)
+		if err != nil {
+			return nil, err
+		}
+
+		// RunWithConfig takes datacenter and token of the plan
+		if w.config != nil {
+			plan.Datacenter = w.config.Datacenter
+			plan.Token = w.config.Token
+		}
+
+		plan.Handler = w.entries[i].handler
+		plans[i] = plan
+	}
+
+	w.plans = plans
+	w.running = true
+
+	return plans, nil
+}
+
+// planConfig copy of config for a plan, nil for the default config
+func (w *Watcher) planConfig() *api.Config {
+	if w.config == nil {
+		return nil
+	}
+
+	config := *w.config
+	return &config
+}
+
+// Stop stop all watches, Start returns after Stop
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		w.mutex.Lock()
+		close(w.done)
+		for i := range w.plans {
+			w.plans[i].Stop()
+		}
+		w.mutex.Unlock()
+	})
+}
+
+// Done closed when the watcher is stopped
+func (w *Watcher) Done() <-chan struct{} {
+	return w.done
+}
+
+// WatchKey watch key
+// Deprecated: use Watcher, WatchKey blocks forever and panics on error
+func WatchKey(consulAddr, key string, handle func(*api.KVPair)) {
+	w := NewWatcher(consulAddr)
+	w.AddKey(key, func(idx uint64, kvPair *api.KVPair) {
+		handle(kvPair)
+	})
+
+	if err := w.Start(context.Background()); err != nil {
+		panic(err)
+	}
+}
+
+// WatchKeyPrefix watch dir
+// Deprecated: use Watcher, WatchKeyPrefix blocks forever and panics on error
+func WatchKeyPrefix(consulAddr, key string, handle func(api.KVPairs)) {
+	w := NewWatcher(consulAddr)
+	w.AddKeyPrefix(key, func(idx uint64, kvPairs api.KVPairs) {
+		handle(kvPairs)
+	})
+
+	if err := w.Start(context.Background()); err != nil {
 		panic(err)
 	}
 }
@@ -1,6 +1,7 @@
 package consul
 
 import (
+	"context"
 	"path/filepath"
 	"sync"
 	"testing"
@@ -57,6 +58,67 @@ func TestWatch(t *testing.T) {
 
 			So(err, ShouldBeNil)
 		})
+
+		Convey("watcher keys and prefixes", func() {
+			w := NewWatcher(testConsulAddrWatch)
+			err := testWatch(t, testKeyWatch, func(wg *sync.WaitGroup) {
+				var once sync.Once
+				w.AddKey(testKeyWatch, func(idx uint64, kvPair *api.KVPair) {
+					t.Log(idx, kvPair.Key)
+					once.Do(wg.Done)
+				})
+				w.AddKeyPrefix(testKeyPrefixWatch, func(idx uint64, kvPairs api.KVPairs) {
+					t.Log(idx, len(kvPairs))
+				})
+				w.Start(context.Background())
+			})
+			w.Stop()
+
+			So(err, ShouldBeNil)
+		})
+	})
+
+	Convey("watch keyprefix events test", t, func() {
+		w := NewWatcher(testConsulAddrWatch)
+		done := make(chan struct{})
+		var once sync.Once
+		w.AddKeyPrefixEvents(testKeyPrefixWatch, func(idx uint64, events []Event) {
+			for i := range events {
+				t.Log(idx, events[i].Type, events[i].Key)
+			}
+			once.Do(func() { close(done) })
+		}, WithInitial())
+
+		go w.Start(context.Background())
+		<-done
+		w.Stop()
+	})
+
+	Convey("watcher stop test", t, func() {
+		Convey("stop by context", func() {
+			w := NewWatcher("127.0.0.1:1")
+			w.AddKey(testKeyWatch, func(idx uint64, kvPair *api.KVPair) {})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			So(w.Start(ctx), ShouldBeNil)
+			// stopped watcher can not be started again
+			So(w.Start(context.Background()), ShouldBeNil)
+		})
+
+		Convey("stop by stop", func() {
+			w := NewWatcher("127.0.0.1:1")
+			w.AddKeyPrefix(testKeyPrefixWatch, func(idx uint64, kvPairs api.KVPairs) {})
+
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				w.Stop()
+			}()
+
+			So(w.Start(context.Background()), ShouldBeNil)
+			<-w.Done()
+		})
 	})
 }
 
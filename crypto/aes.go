@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	crand "crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
@@ -30,6 +31,8 @@ var (
 	ErrPadding = errors.New("padding err")
 	// ErrPaddingEq padding diff
 	ErrPaddingEq = errors.New("padding eq err")
+	// ErrCiphertext ciphertext malformed or tampered
+	ErrCiphertext = errors.New("ciphertext malformed or tampered")
 )
 
 // AESEncrypter aes encrypt
@@ -81,6 +84,58 @@ func AESDecrypter(src string, encrypteKey string, iv []byte) ([]byte, error) {
 	return PKCS7UnPadding(dst, block.BlockSize())
 }
 
+// AESGCMEncrypter aes-gcm encrypt, authenticated, returns base64 of the random nonce and sealed src
+func AESGCMEncrypter(src string, encrypteKey string) (string, error) {
+	gcm, err := newGCM(encrypteKey)
+	if err != nil {
+		return constant.EmptyStr, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = crand.Read(nonce); err != nil {
+		return constant.EmptyStr, err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(src), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// AESGCMDecrypter aes-gcm decrypt of AESGCMEncrypter, ErrCiphertext if malformed or tampered
+func AESGCMDecrypter(src string, encrypteKey string) ([]byte, error) {
+	gcm, err := newGCM(encrypteKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(src)
+	if err != nil || len(sealed) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, ErrCiphertext
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	dst, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrCiphertext
+	}
+
+	return dst, nil
+}
+
+// newGCM aes-gcm of hex key, 16, 24 or 32 bytes
+func newGCM(encrypteKey string) (cipher.AEAD, error) {
+	key, err := hex.DecodeString(encrypteKey)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
 // EncryptCookie aes encrypt cookie
 func EncryptCookie(src string, encrypteKey string, validationKey string) (string, error) {
 	key, err := hex.DecodeString(encrypteKey)
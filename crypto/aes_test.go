@@ -33,6 +33,36 @@ func TestEncrypt(t *testing.T) {
 	})
 }
 
+func TestGCM(t *testing.T) {
+	key := "0F10F6CB2F5369C14D14FA07BAD302267901240CC8C845DD2C645FBD149A11C9"
+	data := "123"
+
+	Convey("gcm test", t, func() {
+		ciphertext, err := AESGCMEncrypter(data, key)
+		So(err, ShouldBeNil)
+		So(ciphertext, ShouldNotBeEmpty)
+
+		other, err := AESGCMEncrypter(data, key)
+		So(err, ShouldBeNil)
+		So(other, ShouldNotEqual, ciphertext)
+
+		raw, err := AESGCMDecrypter(ciphertext, key)
+		So(err, ShouldBeNil)
+		So(string(raw), ShouldEqual, data)
+
+		sealed, _ := base64.StdEncoding.DecodeString(ciphertext)
+		sealed[len(sealed)-1] ^= 1
+		_, err = AESGCMDecrypter(base64.StdEncoding.EncodeToString(sealed), key)
+		So(err, ShouldEqual, ErrCiphertext)
+
+		_, err = AESGCMDecrypter("not base64", key)
+		So(err, ShouldEqual, ErrCiphertext)
+
+		_, err = AESGCMDecrypter(ciphertext, "zz")
+		So(err, ShouldNotBeNil)
+	})
+}
+
 func TestCookie(t *testing.T) {
 	// key, err := keyGen()
 	// if err != nil {
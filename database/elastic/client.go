@@ -52,3 +52,8 @@ func NewElastic(debug bool, urls []string) (*Elastic, error) {
 		Codes:  codes,
 	}, nil
 }
+
+// Stop stop the background goroutines of client
+func (e *Elastic) Stop() {
+	e.client.Stop()
+}
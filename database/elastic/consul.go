@@ -1,12 +1,13 @@
 package elastic
 
 import (
+	"context"
 	"log"
 	"path"
 	"sync"
 
-	"github.com/BurntSushi/toml"
 	"github.com/JREAMLU/j-kit/consul"
+	"github.com/JREAMLU/j-kit/database/registry"
 	"github.com/hashicorp/consul/api"
 )
 
@@ -18,38 +19,90 @@ type Config struct {
 }
 
 var (
-	esClients map[string]*Elastic
-	mutex     sync.Mutex
+	esClients = registry.New(registry.SetCloser(func(es *Elastic) error {
+		es.Stop()
+		return nil
+	}))
+	cancels    []context.CancelFunc
+	watchMutex sync.Mutex
 )
 
-// Watch watch config
-func Watch(consulAddr string, reloadConfig chan string, names ...string) {
+// Watch watch config, the returned watcher is already running and can be stopped
+func Watch(consulAddr string, reloadConfig chan string, names ...string) *consul.Watcher {
+	w := consul.NewWatcher(consulAddr)
 	for i := range names {
-		go func(name string) {
-			consul.WatchKey(consulAddr, path.Join(consul.ElasticSearch, name), func(kvPair *api.KVPair) {
-				reloadConfig <- name
+		name := names[i]
+		w.AddKey(path.Join(consul.ElasticSearch, name), func(idx uint64, kvPair *api.KVPair) {
+			select {
+			case reloadConfig <- name:
+			case <-w.Done():
+			}
+		})
+	}
+
+	go func() {
+		if err := w.Start(context.Background()); err != nil {
+			log.Printf("Failed on elastic Watch, names: %v, err: %v \r\n", names, err)
+		}
+	}()
+
+	return w
+}
+
+// WatchSource watch config of source until ctx done
+func WatchSource(ctx context.Context, source consul.ConfigSource, reloadConfig chan string, names ...string) {
+	for i := range names {
+		name := names[i]
+		go func() {
+			err := source.Watch(ctx, path.Join(consul.ElasticSearch, name), func(value string) {
+				select {
+				case reloadConfig <- name:
+				case <-ctx.Done():
+				}
 			})
-		}(names[i])
+			if err != nil {
+				log.Printf("Failed on elastic WatchSource, name: %v, err: %v \r\n", name, err)
+			}
+		}()
 	}
 }
 
-func watching(consulAddr string, debug bool, names ...string) {
+// StopWatching stop all watchers started by Load
+func StopWatching() {
+	watchMutex.Lock()
+	for i := range cancels {
+		cancels[i]()
+	}
+	cancels = nil
+	watchMutex.Unlock()
+}
+
+func addCancel(cancel context.CancelFunc) {
+	watchMutex.Lock()
+	cancels = append(cancels, cancel)
+	watchMutex.Unlock()
+}
+
+func watching(source consul.ConfigSource, debug bool, names ...string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	addCancel(cancel)
+
 	watchdNode := make(chan string)
-	Watch(consulAddr, watchdNode, names...)
+	WatchSource(ctx, source, watchdNode, names...)
 	go func() {
 		for {
 			select {
+			case <-ctx.Done():
+				return
 			case node := <-watchdNode:
 				log.Printf("changed: %v \r\n", node)
-				nEsclient, err := LoadConfig(consulAddr, false, debug, node)
+				nEsclient, err := LoadConfigSource(source, false, debug, node)
 				if err != nil {
 					log.Printf("Failed on elastic LoadConfig, watchedNode: %v, err: %v \r\n", node, err)
 					continue
 				}
 
-				mutex.Lock()
-				esClients[node] = nEsclient[node]
-				mutex.Unlock()
+				esClients.Swap(nEsclient)
 			}
 		}
 	}()
@@ -57,12 +110,23 @@ func watching(consulAddr string, debug bool, names ...string) {
 
 // Load load elastic
 func Load(consulAddr string, isWatching, debug bool, names ...string) error {
-	var err error
-	esClients, err = LoadConfig(consulAddr, isWatching, debug, names...)
+	ess, err := LoadConfig(consulAddr, isWatching, debug, names...)
+	if err != nil {
+		return err
+	}
+
+	esClients.Swap(ess)
+	return nil
+}
+
+// LoadSource load elastic from source
+func LoadSource(source consul.ConfigSource, isWatching, debug bool, names ...string) error {
+	ess, err := LoadConfigSource(source, isWatching, debug, names...)
 	if err != nil {
 		return err
 	}
 
+	esClients.Swap(ess)
 	return nil
 }
 
@@ -73,60 +137,67 @@ func LoadConfig(consulAddr string, isWatching, debug bool, names ...string) (map
 		return nil, err
 	}
 
+	return LoadConfigSource(client, isWatching, debug, names...)
+}
+
+// LoadConfigSource load config from source
+func LoadConfigSource(source consul.ConfigSource, isWatching, debug bool, names ...string) (map[string]*Elastic, error) {
 	if isWatching {
-		watching(consulAddr, debug, names...)
+		watching(source, debug, names...)
 	}
 
 	if len(names) == 0 {
-		return loadAll(client)
+		return loadAll(source)
 	}
 
-	return loadByNames(client, names)
+	return loadByNames(source, names)
 }
 
 // GetElastic get elastic
 func GetElastic(instanceName string) *Elastic {
-	if _, ok := esClients[instanceName]; ok {
-		return esClients[instanceName]
-	}
-
-	return nil
+	es, _ := esClients.Get(instanceName)
+	return es
 }
 
-// GetAllElastic get all elastic
+// GetAllElastic get all elastic, a copy
 func GetAllElastic() map[string]*Elastic {
-	return esClients
+	return esClients.All()
+}
+
+// OnReload call fn after elastic of name loaded or reloaded, the replaced one is stopped after registry.DefaultDrain
+func OnReload(fn func(name string, es *Elastic)) {
+	esClients.Subscribe(fn)
 }
 
-func loadByNames(client *consul.Client, names []string) (map[string]*Elastic, error) {
+func loadByNames(source consul.ConfigSource, names []string) (map[string]*Elastic, error) {
 	for i := range names {
 		names[i] = path.Join(consul.ElasticSearch, names[i])
 	}
 
-	return loadConfig(client, names)
+	return loadConfig(source, names)
 }
 
-func loadAll(client *consul.Client) (map[string]*Elastic, error) {
-	keys, err := client.GetChildKeys(consul.ElasticSearch)
+func loadAll(source consul.ConfigSource) (map[string]*Elastic, error) {
+	keys, err := source.List(consul.ElasticSearch)
 	if err != nil {
 		return nil, err
 	}
 
-	return loadConfig(client, keys)
+	return loadConfig(source, keys)
 }
 
-func loadConfig(client *consul.Client, keys []string) (map[string]*Elastic, error) {
+func loadConfig(source consul.ConfigSource, keys []string) (map[string]*Elastic, error) {
 	var ess = make(map[string]*Elastic, len(keys))
 
 	for _, key := range keys {
 		instanceName := path.Base(key)
-		buf, err := client.Get(key)
+		buf, err := source.Get(key)
 		if err != nil {
 			return nil, err
 		}
 
 		var config Config
-		if _, err = toml.Decode(buf, &config); err != nil {
+		if err = consul.DecodeSourceValue(source, key, []byte(buf), &config); err != nil {
 			return nil, err
 		}
 
@@ -135,9 +206,7 @@ func loadConfig(client *consul.Client, keys []string) (map[string]*Elastic, erro
 			return nil, err
 		}
 
-		mutex.Lock()
 		ess[instanceName] = es
-		mutex.Unlock()
 	}
 
 	return ess, nil
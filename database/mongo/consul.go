@@ -1,14 +1,15 @@
 package mongo
 
 import (
+	"context"
 	"errors"
 	"log"
 	"path"
 	"strings"
 	"sync"
 
-	"github.com/BurntSushi/toml"
 	"github.com/JREAMLU/j-kit/consul"
+	"github.com/JREAMLU/j-kit/database/registry"
 	"github.com/JREAMLU/j-kit/ext"
 	"github.com/hashicorp/consul/api"
 	mgo "gopkg.in/mgo.v2"
@@ -23,38 +24,90 @@ type Config struct {
 }
 
 var (
-	mgoClients map[string]*mgo.Session
-	mutex      sync.Mutex
+	mgoClients = registry.New(registry.SetCloser(func(session *mgo.Session) error {
+		session.Close()
+		return nil
+	}))
+	cancels    []context.CancelFunc
+	watchMutex sync.Mutex
 )
 
-// Watch watch
-func Watch(consulAddr string, reloadConfig chan string, names ...string) {
+// Watch watch config, the returned watcher is already running and can be stopped
+func Watch(consulAddr string, reloadConfig chan string, names ...string) *consul.Watcher {
+	w := consul.NewWatcher(consulAddr)
 	for i := range names {
-		go func(name string) {
-			consul.WatchKey(consulAddr, path.Join(consul.MongoDB, name), func(kvPair *api.KVPair) {
-				reloadConfig <- name
+		name := names[i]
+		w.AddKey(path.Join(consul.MongoDB, name), func(idx uint64, kvPair *api.KVPair) {
+			select {
+			case reloadConfig <- name:
+			case <-w.Done():
+			}
+		})
+	}
+
+	go func() {
+		if err := w.Start(context.Background()); err != nil {
+			log.Printf("Failed on mongo Watch, names: %v, err: %v \r\n", names, err)
+		}
+	}()
+
+	return w
+}
+
+// WatchSource watch config of source until ctx done
+func WatchSource(ctx context.Context, source consul.ConfigSource, reloadConfig chan string, names ...string) {
+	for i := range names {
+		name := names[i]
+		go func() {
+			err := source.Watch(ctx, path.Join(consul.MongoDB, name), func(value string) {
+				select {
+				case reloadConfig <- name:
+				case <-ctx.Done():
+				}
 			})
-		}(names[i])
+			if err != nil {
+				log.Printf("Failed on mongo WatchSource, name: %v, err: %v \r\n", name, err)
+			}
+		}()
 	}
 }
 
-func watching(consulAddr string, names ...string) {
+// StopWatching stop all watchers started by Load
+func StopWatching() {
+	watchMutex.Lock()
+	for i := range cancels {
+		cancels[i]()
+	}
+	cancels = nil
+	watchMutex.Unlock()
+}
+
+func addCancel(cancel context.CancelFunc) {
+	watchMutex.Lock()
+	cancels = append(cancels, cancel)
+	watchMutex.Unlock()
+}
+
+func watching(source consul.ConfigSource, names ...string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	addCancel(cancel)
+
 	watchdNode := make(chan string)
-	Watch(consulAddr, watchdNode, names...)
+	WatchSource(ctx, source, watchdNode, names...)
 	go func() {
 		for {
 			select {
+			case <-ctx.Done():
+				return
 			case node := <-watchdNode:
 				log.Printf("changed: %v \r\n", node)
-				mgoClient, err := LoadConfig(consulAddr, false, node)
+				mgoClient, err := LoadConfigSource(source, false, node)
 				if err != nil {
 					log.Printf("Failed on mongo LoadConfig, watchedNode: %v, err: %v \r\n", node, err)
 					continue
 				}
 
-				mutex.Lock()
-				mgoClients[node] = mgoClient[node]
-				mutex.Unlock()
+				mgoClients.Swap(mgoClient)
 			}
 		}
 	}()
@@ -62,12 +115,23 @@ func watching(consulAddr string, names ...string) {
 
 // Load load mongo
 func Load(consulAddr string, isWatching bool, names ...string) error {
-	var err error
-	mgoClients, err = LoadConfig(consulAddr, isWatching, names...)
+	sessions, err := LoadConfig(consulAddr, isWatching, names...)
+	if err != nil {
+		return err
+	}
+
+	mgoClients.Swap(sessions)
+	return nil
+}
+
+// LoadSource load mongo from source
+func LoadSource(source consul.ConfigSource, isWatching bool, names ...string) error {
+	sessions, err := LoadConfigSource(source, isWatching, names...)
 	if err != nil {
 		return err
 	}
 
+	mgoClients.Swap(sessions)
 	return nil
 }
 
@@ -78,60 +142,67 @@ func LoadConfig(consulAddr string, isWatching bool, names ...string) (map[string
 		return nil, err
 	}
 
+	return LoadConfigSource(client, isWatching, names...)
+}
+
+// LoadConfigSource load config from source
+func LoadConfigSource(source consul.ConfigSource, isWatching bool, names ...string) (map[string]*mgo.Session, error) {
 	if isWatching {
-		watching(consulAddr, names...)
+		watching(source, names...)
 	}
 
 	if len(names) == 0 {
-		return loadAll(client)
+		return loadAll(source)
 	}
 
-	return loadByNames(client, names)
+	return loadByNames(source, names)
 }
 
-func loadByNames(client *consul.Client, names []string) (map[string]*mgo.Session, error) {
+func loadByNames(source consul.ConfigSource, names []string) (map[string]*mgo.Session, error) {
 	for i := range names {
 		names[i] = path.Join(consul.MongoDB, names[i])
 	}
 
-	return loadConfig(client, names)
+	return loadConfig(source, names)
 }
 
 // GetMongo get mongo session
 func GetMongo(instanceName string) *mgo.Session {
-	if _, ok := mgoClients[instanceName]; ok {
-		return mgoClients[instanceName]
-	}
-
-	return nil
+	session, _ := mgoClients.Get(instanceName)
+	return session
 }
 
-// GetAllMongo get all mongo session
+// GetAllMongo get all mongo session, a copy
 func GetAllMongo() map[string]*mgo.Session {
-	return mgoClients
+	return mgoClients.All()
+}
+
+// OnReload call fn after session of name loaded or reloaded, the replaced session is closed after registry.DefaultDrain
+func OnReload(fn func(name string, session *mgo.Session)) {
+	mgoClients.Subscribe(fn)
 }
 
-func loadAll(client *consul.Client) (map[string]*mgo.Session, error) {
-	keys, err := client.GetChildKeys(consul.MongoDB)
+func loadAll(source consul.ConfigSource) (map[string]*mgo.Session, error) {
+	keys, err := source.List(consul.MongoDB)
 	if err != nil {
 		return nil, err
 	}
 
-	return loadConfig(client, keys)
+	return loadConfig(source, keys)
 }
 
-func loadConfig(client *consul.Client, keys []string) (map[string]*mgo.Session, error) {
+func loadConfig(source consul.ConfigSource, keys []string) (map[string]*mgo.Session, error) {
 	var sessions = make(map[string]*mgo.Session, len(keys))
 
 	for _, key := range keys {
 		instanceName := path.Base(key)
-		buf, err := client.Get(key)
+		buf, err := source.Get(key)
 		if err != nil {
 			return nil, err
 		}
 
 		var config Config
-		if _, err = toml.Decode(buf, &config); err != nil {
+		if err = consul.DecodeSourceValue(source, key, []byte(buf), &config); err != nil {
 			return nil, err
 		}
 
@@ -140,9 +211,7 @@ func loadConfig(client *consul.Client, keys []string) (map[string]*mgo.Session,
 			return nil, err
 		}
 
-		mutex.Lock()
 		sessions[instanceName] = session
-		mutex.Unlock()
 	}
 
 	return sessions, nil
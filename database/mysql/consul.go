@@ -1,13 +1,14 @@
 package mysql
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"path"
 	"sync"
 
-	"github.com/BurntSushi/toml"
 	"github.com/JREAMLU/j-kit/consul"
+	"github.com/JREAMLU/j-kit/database/registry"
 	"github.com/JREAMLU/j-kit/ext"
 	"github.com/hashicorp/consul/api"
 	"github.com/jinzhu/gorm"
@@ -33,8 +34,11 @@ const (
 )
 
 var (
-	gx    map[string]*gorm.DB
-	mutex sync.Mutex
+	gx = registry.New(registry.SetCloser(func(db *gorm.DB) error {
+		return db.Close()
+	}))
+	cancels    []context.CancelFunc
+	watchMutex sync.Mutex
 )
 
 // Config mysql config in consul
@@ -61,34 +65,83 @@ type readonly struct {
 	CharSet  string
 }
 
-// Watch watch config
-func Watch(consulAddr string, reloadConfig chan string, names ...string) {
+// Watch watch config, the returned watcher is already running and can be stopped
+func Watch(consulAddr string, reloadConfig chan string, names ...string) *consul.Watcher {
+	w := consul.NewWatcher(consulAddr)
 	for i := range names {
-		go func(name string) {
-			consul.WatchKey(consulAddr, path.Join(consul.MYSQL, name), func(kvPair *api.KVPair) {
-				reloadConfig <- name
+		name := names[i]
+		w.AddKey(path.Join(consul.MYSQL, name), func(idx uint64, kvPair *api.KVPair) {
+			select {
+			case reloadConfig <- name:
+			case <-w.Done():
+			}
+		})
+	}
+
+	go func() {
+		if err := w.Start(context.Background()); err != nil {
+			log.Printf("Failed on mysql Watch, names: %v, err: %v \r\n", names, err)
+		}
+	}()
+
+	return w
+}
+
+// WatchSource watch config of source until ctx done
+func WatchSource(ctx context.Context, source consul.ConfigSource, reloadConfig chan string, names ...string) {
+	for i := range names {
+		name := names[i]
+		go func() {
+			err := source.Watch(ctx, path.Join(consul.MYSQL, name), func(value string) {
+				select {
+				case reloadConfig <- name:
+				case <-ctx.Done():
+				}
 			})
-		}(names[i])
+			if err != nil {
+				log.Printf("Failed on mysql WatchSource, name: %v, err: %v \r\n", name, err)
+			}
+		}()
 	}
 }
 
-func watching(consulAddr string, names ...string) {
+// StopWatching stop all watchers started by Load
+func StopWatching() {
+	watchMutex.Lock()
+	for i := range cancels {
+		cancels[i]()
+	}
+	cancels = nil
+	watchMutex.Unlock()
+}
+
+func addCancel(cancel context.CancelFunc) {
+	watchMutex.Lock()
+	cancels = append(cancels, cancel)
+	watchMutex.Unlock()
+}
+
+func watching(source consul.ConfigSource, names ...string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	addCancel(cancel)
+
 	watchdNode := make(chan string)
-	Watch(consulAddr, watchdNode, names...)
+	WatchSource(ctx, source, watchdNode, names...)
 	go func() {
 		for {
 			select {
+			case <-ctx.Done():
+				return
 			case node := <-watchdNode:
 				log.Printf("changed: %v \r\n", node)
-				ngx, err := LoadConfig(consulAddr, false, node)
+				ngx, err := LoadConfigSource(source, false, node)
 				if err != nil {
 					log.Printf("Failed on mysql LoadConfig, watchedNode: %v, err: %v \r\n", node, err)
 					continue
 				}
 
-				mutex.Lock()
-				gx[node] = ngx[node]
-				mutex.Unlock()
+				// read and write, readonly swapped together
+				gx.Swap(ngx)
 			}
 		}
 	}()
@@ -96,12 +149,23 @@ func watching(consulAddr string, names ...string) {
 
 // Load load mysql
 func Load(consulAddr string, isWatching bool, names ...string) error {
-	var err error
-	gx, err = LoadConfig(consulAddr, isWatching, names...)
+	dbs, err := LoadConfig(consulAddr, isWatching, names...)
 	if err != nil {
 		return err
 	}
 
+	gx.Swap(dbs)
+	return nil
+}
+
+// LoadSource load mysql from source
+func LoadSource(source consul.ConfigSource, isWatching bool, names ...string) error {
+	dbs, err := LoadConfigSource(source, isWatching, names...)
+	if err != nil {
+		return err
+	}
+
+	gx.Swap(dbs)
 	return nil
 }
 
@@ -112,15 +176,20 @@ func LoadConfig(consulAddr string, isWatching bool, names ...string) (map[string
 		return nil, err
 	}
 
+	return LoadConfigSource(client, isWatching, names...)
+}
+
+// LoadConfigSource load config from source
+func LoadConfigSource(source consul.ConfigSource, isWatching bool, names ...string) (map[string]*gorm.DB, error) {
 	if isWatching {
-		watching(consulAddr, names...)
+		watching(source, names...)
 	}
 
 	if len(names) == 0 {
-		return loadAll(client)
+		return loadAll(source)
 	}
 
-	return loadByNames(client, names)
+	return loadByNames(source, names)
 }
 
 // GetReadOnly get readonly
@@ -130,55 +199,54 @@ func GetReadOnly(name string) string {
 
 // GetDB get instance db
 func GetDB(name string) *gorm.DB {
-	if _, ok := gx[name]; ok {
-		return gx[name]
-	}
-
-	return nil
+	db, _ := gx.Get(name)
+	return db
 }
 
 // GetReadOnlyDB get instance readonly db
 func GetReadOnlyDB(name string) *gorm.DB {
-	if _, ok := gx[GetReadOnly(name)]; ok {
-		return gx[GetReadOnly(name)]
-	}
-
-	return nil
+	db, _ := gx.Get(GetReadOnly(name))
+	return db
 }
 
-// GetAllDB get all db
+// GetAllDB get all db, a copy
 func GetAllDB() map[string]*gorm.DB {
-	return gx
+	return gx.All()
+}
+
+// OnReload call fn after db of name loaded or reloaded, the replaced db is closed after registry.DefaultDrain
+func OnReload(fn func(name string, db *gorm.DB)) {
+	gx.Subscribe(fn)
 }
 
-func loadByNames(client *consul.Client, names []string) (map[string]*gorm.DB, error) {
+func loadByNames(source consul.ConfigSource, names []string) (map[string]*gorm.DB, error) {
 	for i := range names {
 		names[i] = path.Join(consul.MYSQL, names[i])
 	}
 
-	return loadConfig(client, names)
+	return loadConfig(source, names)
 }
 
-func loadAll(client *consul.Client) (map[string]*gorm.DB, error) {
-	keys, err := client.GetChildKeys(consul.MYSQL)
+func loadAll(source consul.ConfigSource) (map[string]*gorm.DB, error) {
+	keys, err := source.List(consul.MYSQL)
 	if err != nil {
 		return nil, err
 	}
 
-	return loadConfig(client, keys)
+	return loadConfig(source, keys)
 }
 
-func loadConfig(client *consul.Client, keys []string) (map[string]*gorm.DB, error) {
+func loadConfig(source consul.ConfigSource, keys []string) (map[string]*gorm.DB, error) {
 	var dbs = make(map[string]*gorm.DB, len(keys)*2)
 	for _, key := range keys {
 		instanceName := path.Base(key)
-		buf, err := client.Get(key)
+		buf, err := source.Get(key)
 		if err != nil {
 			return nil, err
 		}
 
 		var config Config
-		if _, err = toml.Decode(buf, &config); err != nil {
+		if err = consul.DecodeSourceValue(source, key, []byte(buf), &config); err != nil {
 			return nil, err
 		}
 
@@ -191,9 +259,7 @@ func loadConfig(client *consul.Client, keys []string) (map[string]*gorm.DB, erro
 		// set pool
 		rwdb.DB().SetMaxOpenConns(MaxOpenConns)
 		rwdb.DB().SetMaxIdleConns(MaxIdleConns)
-		mutex.Lock()
 		dbs[instanceName] = rwdb
-		mutex.Unlock()
 
 		// readonly
 		rdb, err := registerDatabase(instanceName, config, false)
@@ -203,10 +269,7 @@ func loadConfig(client *consul.Client, keys []string) (map[string]*gorm.DB, erro
 
 		rdb.DB().SetMaxOpenConns(MaxOpenConns)
 		rdb.DB().SetMaxIdleConns(MaxIdleConns)
-
-		mutex.Lock()
 		dbs[GetReadOnly(instanceName)] = rdb
-		mutex.Unlock()
 	}
 
 	return dbs, nil
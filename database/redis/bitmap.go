@@ -0,0 +1,188 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Bitmap redis bitmap on a string, offsets of bits and ranges of bytes
+type Bitmap struct {
+	Structure
+}
+
+const (
+	// BITCOUNT bitcount
+	BITCOUNT = "BITCOUNT"
+	// BITOP bitop
+	BITOP = "BITOP"
+	// BITPOS bitpos
+	BITPOS = "BITPOS"
+	// BITFIELD bitfield
+	BITFIELD = "BITFIELD"
+	// BITFIELDRO bitfield of get ops, runs on read-only replicas, redis 6.2+
+	BITFIELDRO = "BITFIELD_RO"
+	// OVERFLOW overflow
+	OVERFLOW = "OVERFLOW"
+)
+
+// BitOperation operation of BitOp
+type BitOperation string
+
+const (
+	// BitAnd and
+	BitAnd BitOperation = "AND"
+	// BitOr or
+	BitOr BitOperation = "OR"
+	// BitXor xor
+	BitXor BitOperation = "XOR"
+	// BitNot not, of one key
+	BitNot BitOperation = "NOT"
+)
+
+// BitFieldOverflow overflow behavior of following BitFieldSet and BitFieldIncrBy
+type BitFieldOverflow string
+
+const (
+	// OverflowWrap wrap around, default
+	OverflowWrap BitFieldOverflow = "WRAP"
+	// OverflowSat saturate to min or max
+	OverflowSat BitFieldOverflow = "SAT"
+	// OverflowFail not executed, its result is nil
+	OverflowFail BitFieldOverflow = "FAIL"
+)
+
+var (
+	// ErrBitFieldOverflow an op of OverflowFail not executed, its value in results is 0
+	ErrBitFieldOverflow = errors.New("redis: bitfield overflow")
+)
+
+// BitFieldType type of field, signed up to 64 bits, unsigned up to 63 bits
+type BitFieldType struct {
+	Signed bool
+	Bits   int
+}
+
+// BitFieldInt signed field of bits
+func BitFieldInt(bits int) BitFieldType {
+	return BitFieldType{Signed: true, Bits: bits}
+}
+
+// BitFieldUint unsigned field of bits
+func BitFieldUint(bits int) BitFieldType {
+	return BitFieldType{Bits: bits}
+}
+
+// String i8, u16 ...
+func (t BitFieldType) String() string {
+	if t.Signed {
+		return "i" + strconv.Itoa(t.Bits)
+	}
+
+	return "u" + strconv.Itoa(t.Bits)
+}
+
+// At bit offset of the index-th field of the type, as the "#index" offset
+func (t BitFieldType) At(index int64) int64 {
+	return index * int64(t.Bits)
+}
+
+// BitFieldOp op of BitField
+type BitFieldOp struct {
+	args []interface{}
+	get  bool
+}
+
+// BitFieldGet get field at bit offset
+func BitFieldGet(t BitFieldType, offset int64) BitFieldOp {
+	return BitFieldOp{args: []interface{}{GET, t.String(), offset}, get: true}
+}
+
+// BitFieldSet set field at bit offset, the result is the old value
+func BitFieldSet(t BitFieldType, offset, value int64) BitFieldOp {
+	return BitFieldOp{args: []interface{}{SET, t.String(), offset, value}}
+}
+
+// BitFieldIncrBy incrby field at bit offset, the result is the new value
+func BitFieldIncrBy(t BitFieldType, offset, increment int64) BitFieldOp {
+	return BitFieldOp{args: []interface{}{INCRBY, t.String(), offset, increment}}
+}
+
+// BitFieldOverflowOf overflow behavior of following ops
+func BitFieldOverflowOf(overflow BitFieldOverflow) BitFieldOp {
+	return BitFieldOp{args: []interface{}{OVERFLOW, string(overflow)}}
+}
+
+// NewBitmap new bitmap
+func NewBitmap(instanceName, keyPrefixFmt string) Bitmap {
+	return Bitmap{
+		Structure: NewStructure(instanceName, keyPrefixFmt),
+	}
+}
+
+// WithContext copy of bitmap, commands honor deadline and cancellation of ctx
+func (b *Bitmap) WithContext(ctx context.Context) *Bitmap {
+	return &Bitmap{Structure: b.Structure.WithContext(ctx)}
+}
+
+// SetBit setbit, the old bit returned
+func (b *Bitmap) SetBit(keySuffix string, offset int64, value int) (int, error) {
+	return b.Int(MASTER, SETBIT, b.InitKey(keySuffix), offset, value)
+}
+
+// GetBit getbit
+func (b *Bitmap) GetBit(keySuffix string, offset int64) (int, error) {
+	return b.Int(SLAVE, GETBIT, b.InitKey(keySuffix), offset)
+}
+
+// BitCount set bits between bytes start and end, 0 and -1 for all
+func (b *Bitmap) BitCount(keySuffix string, start, end int64) (int64, error) {
+	return b.Int64(SLAVE, BITCOUNT, b.InitKey(keySuffix), start, end)
+}
+
+// BitOp bitop of keys into dest, the size of dest returned, keys must be in one slot in cluster mode
+func (b *Bitmap) BitOp(op BitOperation, destKeySuffix string, keySuffix ...string) (int64, error) {
+	args := append([]interface{}{string(op), b.InitKey(destKeySuffix)}, b.initKeys(keySuffix)...)
+	return b.Int64(MASTER, BITOP, args...)
+}
+
+// BitPos first bit of value between bytes start and end, 0 and -1 for all, -1 if not found
+func (b *Bitmap) BitPos(keySuffix string, bit int, start, end int64) (int64, error) {
+	return b.Int64(SLAVE, BITPOS, b.InitKey(keySuffix), bit, start, end)
+}
+
+// BitField results of get, set and incrby ops, BITFIELD_RO on slave if all ops are get
+// ErrBitFieldOverflow with the results if an op of OverflowFail is not executed
+func (b *Bitmap) BitField(keySuffix string, ops ...BitFieldOp) ([]int64, error) {
+	// BITFIELD is a write command, replicas reject it even with get ops only
+	isMaster, cmd := SLAVE, BITFIELDRO
+	args := redis.Args{}.Add(b.InitKey(keySuffix))
+	for _, op := range ops {
+		if !op.get {
+			isMaster, cmd = MASTER, BITFIELD
+		}
+		args = args.Add(op.args...)
+	}
+
+	values, err := redis.Values(b.Do(isMaster, cmd, args...))
+	if err != nil {
+		return nil, err
+	}
+
+	var overflow error
+	results := make([]int64, len(values))
+	for i, value := range values {
+		if value == nil {
+			overflow = ErrBitFieldOverflow
+			continue
+		}
+
+		if results[i], err = redis.Int64(value, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, overflow
+}
@@ -0,0 +1,81 @@
+package redis
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBitmap(t *testing.T) {
+	Convey("bitmap test", t, func() {
+		fake := newFakeRedis(t)
+		defer fake.close()
+		fake.load(t, "BitmapTest")
+
+		b := NewBitmap("BitmapTest", "sign:%s")
+
+		Convey("bits", func() {
+			old, err := b.SetBit("u1", 7, 1)
+			So(err, ShouldBeNil)
+			So(old, ShouldEqual, 0)
+			_, err = b.SetBit("u1", 9, 1)
+			So(err, ShouldBeNil)
+
+			bit, err := b.GetBit("u1", 7)
+			So(err, ShouldBeNil)
+			So(bit, ShouldEqual, 1)
+
+			n, err := b.BitCount("u1", 0, -1)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+		})
+
+		Convey("bitfield", func() {
+			u8 := BitFieldUint(8)
+			So(u8.String(), ShouldEqual, "u8")
+			So(BitFieldInt(16).String(), ShouldEqual, "i16")
+			So(u8.At(2), ShouldEqual, 16)
+
+			results, err := b.BitField("counters",
+				BitFieldSet(u8, u8.At(0), 200),
+				BitFieldIncrBy(u8, u8.At(0), 100),
+				BitFieldGet(u8, u8.At(0)),
+				BitFieldIncrBy(BitFieldInt(8), u8.At(1), -3),
+			)
+			So(err, ShouldBeNil)
+			So(results, ShouldResemble, []int64{0, 44, 44, -3})
+
+			results, err = b.BitField("counters",
+				BitFieldOverflowOf(OverflowFail),
+				BitFieldIncrBy(u8, u8.At(0), 250),
+				BitFieldIncrBy(u8, u8.At(0), 1),
+			)
+			So(err, ShouldEqual, ErrBitFieldOverflow)
+			So(results, ShouldResemble, []int64{0, 45})
+		})
+	})
+
+	Convey("bitfield on replica test", t, func() {
+		master := newFakeRedis(t)
+		defer master.close()
+		replica := newFakeRedis(t)
+		defer replica.close()
+		master.loadReplica(t, "BitmapReplicaTest", replica)
+
+		b := NewBitmap("BitmapReplicaTest", "sign:%s")
+		u8 := BitFieldUint(8)
+		replica.mutex.Lock()
+		replica.strings["sign:counters"] = string([]byte{7, 9})
+		replica.mutex.Unlock()
+
+		// get ops only read the replica
+		results, err := b.BitField("counters", BitFieldGet(u8, u8.At(0)), BitFieldGet(u8, u8.At(1)))
+		So(err, ShouldBeNil)
+		So(results, ShouldResemble, []int64{7, 9})
+
+		// any write op goes to the master
+		results, err = b.BitField("counters", BitFieldGet(u8, u8.At(0)), BitFieldSet(u8, u8.At(0), 1))
+		So(err, ShouldBeNil)
+		So(results, ShouldResemble, []int64{0, 0})
+	})
+}
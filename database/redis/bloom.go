@@ -0,0 +1,179 @@
+package redis
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"hash/fnv"
+	"math"
+	"strconv"
+)
+
+const (
+	_defaultBloomShards = 1
+	// bits of a redis string, 512MB
+	_maxBloomBits = 1 << 32
+)
+
+// ErrBloomInvalidSize expected elements is 0 or false positive rate is not in (0, 1)
+var ErrBloomInvalidSize = errors.New("redis: bloom expected elements must be > 0 and false positive rate in (0, 1)")
+
+// Bloom client side bloom filter on bitmaps, sized by expected elements and false positive rate
+// elements are sharded across keySuffix:0 ... keySuffix:shards-1, every element sets k bits of its shard
+// bits of an element are in one pipeline, it is not atomic with others
+type Bloom struct {
+	bitmap Bitmap
+	shards uint32
+	// bits and hashes of a shard
+	m uint64
+	k uint64
+}
+
+// BloomOptionFunc bloom option func
+type BloomOptionFunc func(*Bloom)
+
+// SetBloomShards set keys the elements sharded across, to spread memory and load in cluster mode, default is 1
+func SetBloomShards(shards uint32) BloomOptionFunc {
+	return func(b *Bloom) {
+		if shards > 0 {
+			b.shards = shards
+		}
+	}
+}
+
+// NewBloom new bloom filter of n expected elements with false positive rate p, ErrBloomInvalidSize if n is 0 or p not in (0, 1)
+// a shard over 512MB is capped, more shards are needed to keep p
+func NewBloom(instanceName, keyPrefixFmt string, n uint64, p float64, opts ...BloomOptionFunc) (*Bloom, error) {
+	if n == 0 || !(p > 0 && p < 1) {
+		return nil, ErrBloomInvalidSize
+	}
+
+	b := &Bloom{
+		bitmap: NewBitmap(instanceName, keyPrefixFmt),
+		shards: _defaultBloomShards,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	b.m, b.k = bloomSize(n/uint64(b.shards)+1, p)
+	return b, nil
+}
+
+// WithContext copy of bloom, commands honor deadline and cancellation of ctx
+func (b *Bloom) WithContext(ctx context.Context) *Bloom {
+	c := *b
+	c.bitmap = *b.bitmap.WithContext(ctx)
+	return &c
+}
+
+// Bits bits and hashes of a shard
+func (b *Bloom) Bits() (m, k uint64) {
+	return b.m, b.k
+}
+
+// Add add elements, true if an element was not in the filter
+func (b *Bloom) Add(keySuffix string, elements ...string) ([]bool, error) {
+	p := b.bitmap.Pipeline(MASTER)
+	replies := b.send(p, SETBIT, keySuffix, elements, 1)
+	if err := p.Flush(); err != nil {
+		return nil, err
+	}
+
+	// an element is added when any of its bits was not set
+	return b.receive(replies, 0)
+}
+
+// Exists true if an element may be in the filter, false if it is definitely not
+func (b *Bloom) Exists(keySuffix string, elements ...string) ([]bool, error) {
+	p := b.bitmap.Pipeline(SLAVE)
+	replies := b.send(p, GETBIT, keySuffix, elements)
+	if err := p.Flush(); err != nil {
+		return nil, err
+	}
+
+	// an element exists when none of its bits is not set
+	exists, err := b.receive(replies, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range exists {
+		exists[i] = !exists[i]
+	}
+
+	return exists, nil
+}
+
+// Delete delete keys of all shards
+func (b *Bloom) Delete(keySuffix string) error {
+	p := b.bitmap.Pipeline(MASTER)
+	for shard := uint32(0); shard < b.shards; shard++ {
+		p.Send(DEL, b.key(keySuffix, shard))
+	}
+
+	return p.Flush()
+}
+
+// send send cmd of the k bits of every element
+func (b *Bloom) send(p *Pipeline, cmd, keySuffix string, elements []string, args ...interface{}) [][]*PipelineReply {
+	replies := make([][]*PipelineReply, len(elements))
+	for i, element := range elements {
+		key := b.key(keySuffix, crc32.ChecksumIEEE([]byte(element))%b.shards)
+		h1, h2 := bloomHash(element)
+
+		replies[i] = make([]*PipelineReply, b.k)
+		for j := uint64(0); j < b.k; j++ {
+			// double hashing, h1 + j * h2
+			offset := (h1 + j*h2) % b.m
+			replies[i][j] = p.Send(cmd, append([]interface{}{key, offset}, args...)...)
+		}
+	}
+
+	return replies
+}
+
+// receive true if any bit of an element equals bit
+func (b *Bloom) receive(replies [][]*PipelineReply, bit int) ([]bool, error) {
+	result := make([]bool, len(replies))
+	for i := range replies {
+		for _, reply := range replies[i] {
+			v, err := reply.Int()
+			if err != nil {
+				return nil, err
+			}
+
+			if v == bit {
+				result[i] = true
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (b *Bloom) key(keySuffix string, shard uint32) string {
+	return b.bitmap.InitKey(keySuffix + ":" + strconv.FormatUint(uint64(shard), 10))
+}
+
+// bloomSize optimal bits m = -n ln(p) / ln(2)^2 and hashes k = m / n ln(2)
+func bloomSize(n uint64, p float64) (uint64, uint64) {
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	m = math.Min(math.Max(m, 1), _maxBloomBits)
+
+	k := math.Max(math.Round(m/float64(n)*math.Ln2), 1)
+	return uint64(m), uint64(k)
+}
+
+// bloomHash two hashes of the 128 bits fnv-1a
+func bloomHash(element string) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(element))
+	sum := h.Sum(nil)
+
+	// h2 is odd so it is never 0, the k offsets are distinct only when h2 mod m is coprime with m
+	// m is not a power of 2, so a few elements may repeat offsets and use fewer bits
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
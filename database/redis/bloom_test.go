@@ -0,0 +1,94 @@
+package redis
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBloom(t *testing.T) {
+	Convey("bloom test", t, func() {
+		fake := newFakeRedis(t)
+		defer fake.close()
+		fake.load(t, "BloomTest")
+
+		Convey("size", func() {
+			m, k := bloomSize(1000, 0.01)
+			So(m, ShouldEqual, 9586)
+			So(k, ShouldEqual, 7)
+
+			b, err := NewBloom("BloomTest", "dedup:%s", 1000, 0.01, SetBloomShards(4))
+			So(err, ShouldBeNil)
+			m, k = b.Bits()
+			So(m, ShouldBeLessThan, 9586/3)
+			So(k, ShouldEqual, 7)
+		})
+
+		Convey("invalid size", func() {
+			for _, p := range []float64{0, 1, -0.1, 1.5, math.NaN()} {
+				_, err := NewBloom("BloomTest", "dedup:%s", 1000, p)
+				So(err, ShouldEqual, ErrBloomInvalidSize)
+			}
+
+			_, err := NewBloom("BloomTest", "dedup:%s", 0, 0.01)
+			So(err, ShouldEqual, ErrBloomInvalidSize)
+		})
+
+		Convey("add and exists", func() {
+			b, err := NewBloom("BloomTest", "dedup:%s", 1000, 0.01, SetBloomShards(4))
+			So(err, ShouldBeNil)
+
+			added, err := b.Add("msg", "a", "b")
+			So(err, ShouldBeNil)
+			So(added, ShouldResemble, []bool{true, true})
+
+			added, err = b.Add("msg", "a", "c")
+			So(err, ShouldBeNil)
+			So(added, ShouldResemble, []bool{false, true})
+
+			exists, err := b.Exists("msg", "a", "b", "c", "d")
+			So(err, ShouldBeNil)
+			So(exists, ShouldResemble, []bool{true, true, true, false})
+
+			So(b.Delete("msg"), ShouldBeNil)
+			exists, err = b.Exists("msg", "a")
+			So(err, ShouldBeNil)
+			So(exists, ShouldResemble, []bool{false})
+		})
+
+		Convey("false positive rate", func() {
+			b, err := NewBloom("BloomTest", "dedup:%s", 1000, 0.01, SetBloomShards(2))
+			So(err, ShouldBeNil)
+
+			elements := make([]string, 1000)
+			for i := range elements {
+				elements[i] = fmt.Sprintf("in-%d", i)
+			}
+			_, err = b.Add("fp", elements...)
+			So(err, ShouldBeNil)
+
+			for i := range elements {
+				elements[i] = fmt.Sprintf("out-%d", i)
+			}
+			exists, err := b.Exists("fp", elements...)
+			So(err, ShouldBeNil)
+
+			var positives int
+			for _, ok := range exists {
+				if ok {
+					positives++
+				}
+			}
+			So(positives, ShouldBeLessThan, 30)
+		})
+
+		Convey("not loaded instance", func() {
+			b, err := NewBloom("None", "dedup:%s", 1000, 0.01)
+			So(err, ShouldBeNil)
+			_, err = b.Add("msg", "a")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,341 @@
+package redis
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	_defaultCacheBeta        = 1.0
+	_defaultCacheLoadTimeout = 10 * time.Second
+
+	// entry flags
+	_cacheValue    byte = 0
+	_cacheNotFound byte = 1
+	// flag(1) | load time ms(8) | expire at unix ms(8) | payload
+	_cacheHeaderSize = 17
+)
+
+var (
+	// ErrCacheNotFound returned by loader when the value does not exist, cached as negative result
+	ErrCacheNotFound = errors.New("redis: cache not found")
+	// ErrCacheCorrupted cached entry is not written by Cache
+	ErrCacheCorrupted = errors.New("redis: cache entry corrupted")
+
+	// set field and extend the ttl of the hash, a field expires by expire at of its entry
+	_cacheHSetLua = `redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+local ttl = tonumber(ARGV[3])
+if redis.call('PTTL', KEYS[1]) < ttl then
+    redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1`
+)
+
+// CacheLoader load value of a cache miss
+type CacheLoader func(ctx context.Context) (interface{}, error)
+
+// Cache cache-aside over String, or fields of a Hash by HGetOrLoad, concurrent misses of a key share one load
+// a hit may refresh early with the probability growing near expiry, loading time and beta
+type Cache struct {
+	str         String
+	codec       Codec
+	negativeTTL time.Duration
+	beta        float64
+	loadTimeout time.Duration
+	group       singleflight.Group
+}
+
+// cacheEntry decoded entry of a key
+type cacheEntry struct {
+	flag     byte
+	delta    time.Duration
+	expireAt time.Time
+	payload  []byte
+}
+
+// CacheOptionFunc cache option func
+type CacheOptionFunc func(*Cache)
+
+// SetCacheCodec set codec of values, default is JSONCodec
+func SetCacheCodec(codec Codec) CacheOptionFunc {
+	return func(c *Cache) {
+		c.codec = codec
+	}
+}
+
+// SetCacheNegativeTTL cache ErrCacheNotFound of loader for ttl, default is 0 not cached
+func SetCacheNegativeTTL(ttl time.Duration) CacheOptionFunc {
+	return func(c *Cache) {
+		c.negativeTTL = ttl
+	}
+}
+
+// SetCacheBeta set beta of early expiration, larger refreshes earlier, 0 disables it, default is 1
+func SetCacheBeta(beta float64) CacheOptionFunc {
+	return func(c *Cache) {
+		if beta >= 0 {
+			c.beta = beta
+		}
+	}
+}
+
+// SetCacheLoadTimeout set timeout of a shared load, default is 10s
+func SetCacheLoadTimeout(timeout time.Duration) CacheOptionFunc {
+	return func(c *Cache) {
+		if timeout > 0 {
+			c.loadTimeout = timeout
+		}
+	}
+}
+
+// NewCache new cache
+func NewCache(instanceName, keyPrefixFmt string, opts ...CacheOptionFunc) *Cache {
+	c := &Cache{
+		str:         NewString(instanceName, keyPrefixFmt),
+		codec:       JSONCodec,
+		beta:        _defaultCacheBeta,
+		loadTimeout: _defaultCacheLoadTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// GetOrLoad unmarshal the cached value of keySuffix into value, load and cache it for ttl on miss
+// loader returns ErrCacheNotFound for missing values, redis errors are logged and the value is loaded
+// concurrent misses share one load, it runs with the values of ctx but not its cancellation, until the load timeout
+func (c *Cache) GetOrLoad(ctx context.Context, keySuffix string, ttl time.Duration, loader CacheLoader, value interface{}) error {
+	return c.getOrLoad(ctx, keySuffix, "", ttl, loader, value)
+}
+
+// HGetOrLoad GetOrLoad of a field of the hash keySuffix, fields expire on their own ttl
+// the hash lives as long as its longest ttl
+func (c *Cache) HGetOrLoad(ctx context.Context, keySuffix, field string, ttl time.Duration, loader CacheLoader, value interface{}) error {
+	return c.getOrLoad(ctx, keySuffix, field, ttl, loader, value)
+}
+
+// Set cache value for ttl
+func (c *Cache) Set(ctx context.Context, keySuffix string, ttl time.Duration, value interface{}) error {
+	return c.setValue(ctx, keySuffix, "", ttl, value)
+}
+
+// HSet cache value of a field of the hash keySuffix for ttl
+func (c *Cache) HSet(ctx context.Context, keySuffix, field string, ttl time.Duration, value interface{}) error {
+	return c.setValue(ctx, keySuffix, field, ttl, value)
+}
+
+// Delete invalidate keySuffix, all fields of a hash
+func (c *Cache) Delete(ctx context.Context, keySuffix string) error {
+	_, err := c.str.WithContext(ctx).Do(MASTER, DEL, c.str.InitKey(keySuffix))
+	return err
+}
+
+// HDelete invalidate fields of the hash keySuffix
+func (c *Cache) HDelete(ctx context.Context, keySuffix string, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	args := redis.Args{}.Add(c.str.InitKey(keySuffix)).AddFlat(fields)
+	_, err := c.str.WithContext(ctx).Do(MASTER, HDEL, args...)
+	return err
+}
+
+// getOrLoad of keySuffix, or its field when field is not empty
+func (c *Cache) getOrLoad(ctx context.Context, keySuffix, field string, ttl time.Duration, loader CacheLoader, value interface{}) error {
+	entry, err := c.get(ctx, keySuffix, field)
+	if err != nil && err != redis.ErrNil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		log.Printf("Failed on redis cache get, key: %v, field: %v, err: %v \r\n", keySuffix, field, err)
+	}
+
+	if entry != nil && !entry.early(c.beta) {
+		return c.decode(entry, value)
+	}
+
+	// the load is shared, a canceled caller never fails the others
+	ch := c.group.DoChan(c.groupKey(keySuffix, field), func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(detachedContext{ctx}, c.loadTimeout)
+		defer cancel()
+
+		return c.load(loadCtx, keySuffix, field, ttl, loader)
+	})
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case result := <-ch:
+		// a failed early refresh serves the cached entry
+		if result.Err != nil && entry != nil {
+			log.Printf("Failed on redis cache refresh, key: %v, field: %v, err: %v \r\n", keySuffix, field, result.Err)
+			return c.decode(entry, value)
+		}
+
+		if result.Err != nil {
+			return result.Err
+		}
+
+		return c.decode(result.Val.(*cacheEntry), value)
+	}
+}
+
+func (c *Cache) groupKey(keySuffix, field string) string {
+	if field == "" {
+		return c.str.InitKey(keySuffix)
+	}
+
+	return c.str.InitKey(keySuffix) + " " + field
+}
+
+func (c *Cache) setValue(ctx context.Context, keySuffix, field string, ttl time.Duration, value interface{}) error {
+	payload, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return c.set(ctx, keySuffix, field, ttl, &cacheEntry{flag: _cacheValue, payload: payload})
+}
+
+// get entry of keySuffix or its field, an expired field is redis.ErrNil
+func (c *Cache) get(ctx context.Context, keySuffix, field string) (*cacheEntry, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if field == "" {
+		data, err = redis.Bytes(c.str.WithContext(ctx).Do(SLAVE, GET, c.str.InitKey(keySuffix)))
+	} else {
+		data, err = redis.Bytes(c.str.WithContext(ctx).Do(SLAVE, HGET, c.str.InitKey(keySuffix), field))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < _cacheHeaderSize {
+		return nil, ErrCacheCorrupted
+	}
+
+	entry := &cacheEntry{
+		flag:     data[0],
+		delta:    time.Duration(binary.BigEndian.Uint64(data[1:9])) * time.Millisecond,
+		expireAt: time.Unix(0, int64(binary.BigEndian.Uint64(data[9:17]))*int64(time.Millisecond)),
+		payload:  data[_cacheHeaderSize:],
+	}
+
+	if field != "" && !time.Now().Before(entry.expireAt) {
+		return nil, redis.ErrNil
+	}
+
+	return entry, nil
+}
+
+func (c *Cache) set(ctx context.Context, keySuffix, field string, ttl time.Duration, entry *cacheEntry) error {
+	if ttl < time.Millisecond {
+		return nil
+	}
+
+	entry.expireAt = time.Now().Add(ttl)
+
+	data := make([]byte, _cacheHeaderSize+len(entry.payload))
+	data[0] = entry.flag
+	binary.BigEndian.PutUint64(data[1:9], uint64(entry.delta/time.Millisecond))
+	binary.BigEndian.PutUint64(data[9:17], uint64(entry.expireAt.UnixNano()/int64(time.Millisecond)))
+	copy(data[_cacheHeaderSize:], entry.payload)
+
+	if field == "" {
+		_, err := c.str.WithContext(ctx).Do(MASTER, SET, c.str.InitKey(keySuffix), data, PX, int64(ttl/time.Millisecond))
+		return err
+	}
+
+	connStr := c.str.getConnstr(MASTER)
+	if connStr == "" {
+		return configNotExistsOrLoad(c.str.InstanceName, MASTER)
+	}
+
+	script := GetScript(connStr, _cacheHSetLua)
+	if script == nil {
+		return configNotExistsOrLoad(c.str.InstanceName, MASTER)
+	}
+
+	_, err := c.str.eval(ctx, script, c.str.InitKey(keySuffix), field, data, int64(ttl/time.Millisecond))
+	return err
+}
+
+// load call loader and cache the result, a failed cache write is only logged
+func (c *Cache) load(ctx context.Context, keySuffix, field string, ttl time.Duration, loader CacheLoader) (*cacheEntry, error) {
+	start := time.Now()
+	value, err := loader(ctx)
+	delta := time.Since(start)
+
+	entry := &cacheEntry{flag: _cacheValue, delta: delta}
+	switch err {
+	case nil:
+		if entry.payload, err = c.codec.Marshal(value); err != nil {
+			return nil, err
+		}
+	case ErrCacheNotFound:
+		entry.flag, ttl = _cacheNotFound, c.negativeTTL
+	default:
+		return nil, err
+	}
+
+	if err = c.set(ctx, keySuffix, field, ttl, entry); err != nil {
+		log.Printf("Failed on redis cache set, key: %v, field: %v, err: %v \r\n", keySuffix, field, err)
+	}
+
+	return entry, nil
+}
+
+func (c *Cache) decode(entry *cacheEntry, value interface{}) error {
+	if entry.flag == _cacheNotFound {
+		return ErrCacheNotFound
+	}
+
+	return c.codec.Unmarshal(entry.payload, value)
+}
+
+// early refresh before expiry with probability of xfetch, now - delta * beta * ln(rand) >= expiry
+func (entry *cacheEntry) early(beta float64) bool {
+	if beta == 0 || entry.delta == 0 {
+		return false
+	}
+
+	// rand in (0, 1]
+	gap := time.Duration(float64(entry.delta) * beta * -math.Log(1-rand.Float64()))
+	return !time.Now().Add(gap).Before(entry.expireAt)
+}
+
+// detachedContext values of the parent without its deadline and cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (ctx detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (ctx detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (ctx detachedContext) Err() error {
+	return nil
+}
+
+func (ctx detachedContext) Value(key interface{}) interface{} {
+	return ctx.parent.Value(key)
+}
@@ -0,0 +1,238 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type cacheUser struct {
+	ID   int64  `json:"id" msgpack:"id"`
+	Name string `json:"name" msgpack:"name"`
+}
+
+// fakeCacheScripts go implementation of the hset script of cache
+func fakeCacheScripts(f *fakeRedis) {
+	f.script(_cacheHSetLua, func(f *fakeRedis, keys, args []string) interface{} {
+		f.exec([]string{HSET, keys[0], args[0], args[1]})
+		ttl, _ := strconv.ParseInt(args[2], 10, 64)
+		if at, ok := f.expires[keys[0]]; !ok || time.Until(at) < time.Duration(ttl)*time.Millisecond {
+			f.expires[keys[0]] = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+		}
+		return int64(1)
+	})
+}
+
+type cacheCtxKey struct{}
+
+func TestCache(t *testing.T) {
+	Convey("cache test", t, func() {
+		fake := newFakeRedis(t)
+		defer fake.close()
+		fake.load(t, "CacheTest")
+		fakeCacheScripts(fake)
+
+		ctx := context.Background()
+
+		var loads int32
+		loader := func(ctx context.Context) (interface{}, error) {
+			atomic.AddInt32(&loads, 1)
+			time.Sleep(20 * time.Millisecond)
+			return cacheUser{ID: 1, Name: "jream"}, nil
+		}
+
+		Convey("get or load", func() {
+			c := NewCache("CacheTest", "user:%s", SetCacheBeta(0))
+
+			var user cacheUser
+			So(c.GetOrLoad(ctx, "1", time.Minute, loader, &user), ShouldBeNil)
+			So(user, ShouldResemble, cacheUser{ID: 1, Name: "jream"})
+
+			user = cacheUser{}
+			So(c.GetOrLoad(ctx, "1", time.Minute, loader, &user), ShouldBeNil)
+			So(user.Name, ShouldEqual, "jream")
+			So(atomic.LoadInt32(&loads), ShouldEqual, 1)
+
+			So(c.Delete(ctx, "1"), ShouldBeNil)
+			So(c.GetOrLoad(ctx, "1", time.Minute, loader, &user), ShouldBeNil)
+			So(atomic.LoadInt32(&loads), ShouldEqual, 2)
+
+			So(c.Set(ctx, "2", time.Minute, cacheUser{ID: 2}), ShouldBeNil)
+			So(c.GetOrLoad(ctx, "2", time.Minute, loader, &user), ShouldBeNil)
+			So(user, ShouldResemble, cacheUser{ID: 2})
+			So(atomic.LoadInt32(&loads), ShouldEqual, 2)
+		})
+
+		Convey("concurrent misses load once", func() {
+			c := NewCache("CacheTest", "user:%s", SetCacheCodec(MsgpackCodec))
+
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					var user cacheUser
+					if err := c.GetOrLoad(ctx, "herd", time.Minute, loader, &user); err != nil || user.ID != 1 {
+						t.Error(user, err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			So(atomic.LoadInt32(&loads), ShouldEqual, 1)
+		})
+
+		Convey("canceled first caller", func() {
+			c := NewCache("CacheTest", "user:%s")
+			slow := func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&loads, 1)
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(50 * time.Millisecond):
+				}
+
+				return cacheUser{ID: 1, Name: ctx.Value(cacheCtxKey{}).(string)}, nil
+			}
+
+			first, cancel := context.WithTimeout(context.WithValue(ctx, cacheCtxKey{}, "first"), 10*time.Millisecond)
+			defer cancel()
+
+			errc := make(chan error, 1)
+			go func() {
+				var user cacheUser
+				errc <- c.GetOrLoad(first, "canceled", time.Minute, slow, &user)
+			}()
+
+			// the second caller shares the load of the first one
+			time.Sleep(5 * time.Millisecond)
+			var user cacheUser
+			So(c.GetOrLoad(ctx, "canceled", time.Minute, slow, &user), ShouldBeNil)
+			So(user, ShouldResemble, cacheUser{ID: 1, Name: "first"})
+			So(<-errc, ShouldBeError, context.DeadlineExceeded.Error())
+			So(atomic.LoadInt32(&loads), ShouldEqual, 1)
+		})
+
+		Convey("load timeout", func() {
+			c := NewCache("CacheTest", "user:%s", SetCacheLoadTimeout(10*time.Millisecond))
+			var user cacheUser
+			So(c.GetOrLoad(ctx, "timeout", time.Minute, func(ctx context.Context) (interface{}, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}, &user), ShouldBeError, context.DeadlineExceeded.Error())
+		})
+
+		Convey("hash fields", func() {
+			c := NewCache("CacheTest", "users:%s", SetCacheBeta(0))
+
+			var user cacheUser
+			So(c.HGetOrLoad(ctx, "group", "1", time.Minute, loader, &user), ShouldBeNil)
+			So(user, ShouldResemble, cacheUser{ID: 1, Name: "jream"})
+			So(c.HGetOrLoad(ctx, "group", "1", time.Minute, loader, &user), ShouldBeNil)
+			So(atomic.LoadInt32(&loads), ShouldEqual, 1)
+
+			So(c.HSet(ctx, "group", "2", 20*time.Millisecond, cacheUser{ID: 2}), ShouldBeNil)
+			So(c.HGetOrLoad(ctx, "group", "2", time.Minute, loader, &user), ShouldBeNil)
+			So(user, ShouldResemble, cacheUser{ID: 2})
+			So(atomic.LoadInt32(&loads), ShouldEqual, 1)
+
+			// a field expires on its own ttl, the hash keeps the longest one
+			time.Sleep(30 * time.Millisecond)
+			So(c.HGetOrLoad(ctx, "group", "2", time.Minute, loader, &user), ShouldBeNil)
+			So(user.ID, ShouldEqual, 1)
+			So(atomic.LoadInt32(&loads), ShouldEqual, 2)
+			So(c.HGetOrLoad(ctx, "group", "1", time.Minute, loader, &user), ShouldBeNil)
+			So(atomic.LoadInt32(&loads), ShouldEqual, 2)
+
+			So(c.HDelete(ctx, "group", "1"), ShouldBeNil)
+			So(c.HGetOrLoad(ctx, "group", "1", time.Minute, loader, &user), ShouldBeNil)
+			So(atomic.LoadInt32(&loads), ShouldEqual, 3)
+
+			So(c.Delete(ctx, "group"), ShouldBeNil)
+			So(c.HGetOrLoad(ctx, "group", "2", time.Minute, loader, &user), ShouldBeNil)
+			So(atomic.LoadInt32(&loads), ShouldEqual, 4)
+		})
+
+		Convey("negative result", func() {
+			missing := func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&loads, 1)
+				return nil, ErrCacheNotFound
+			}
+
+			c := NewCache("CacheTest", "user:%s")
+			var user cacheUser
+			So(c.GetOrLoad(ctx, "none", time.Minute, missing, &user), ShouldEqual, ErrCacheNotFound)
+			So(c.GetOrLoad(ctx, "none", time.Minute, missing, &user), ShouldEqual, ErrCacheNotFound)
+			So(atomic.LoadInt32(&loads), ShouldEqual, 2)
+
+			c = NewCache("CacheTest", "user:%s", SetCacheNegativeTTL(time.Minute))
+			So(c.GetOrLoad(ctx, "none", time.Minute, missing, &user), ShouldEqual, ErrCacheNotFound)
+			So(c.GetOrLoad(ctx, "none", time.Minute, missing, &user), ShouldEqual, ErrCacheNotFound)
+			So(atomic.LoadInt32(&loads), ShouldEqual, 3)
+		})
+
+		Convey("loader error is not cached", func() {
+			errLoad := errors.New("load")
+			c := NewCache("CacheTest", "user:%s")
+
+			var user cacheUser
+			So(c.GetOrLoad(ctx, "err", time.Minute, func(ctx context.Context) (interface{}, error) {
+				return nil, errLoad
+			}, &user), ShouldEqual, errLoad)
+			So(c.GetOrLoad(ctx, "err", time.Minute, loader, &user), ShouldBeNil)
+			So(user.ID, ShouldEqual, 1)
+		})
+
+		Convey("early expiration", func() {
+			// a large beta always refreshes before expiry
+			c := NewCache("CacheTest", "user:%s", SetCacheBeta(1e6))
+
+			var user cacheUser
+			for i := 0; i < 3; i++ {
+				So(c.GetOrLoad(ctx, "early", time.Minute, loader, &user), ShouldBeNil)
+			}
+			So(atomic.LoadInt32(&loads), ShouldEqual, 3)
+
+			// expired entries are never hit
+			entry := &cacheEntry{delta: time.Millisecond, expireAt: time.Now().Add(-time.Second)}
+			So(entry.early(1), ShouldBeTrue)
+			entry.expireAt = time.Now().Add(time.Hour)
+			So(entry.early(1), ShouldBeFalse)
+			So(entry.early(0), ShouldBeFalse)
+
+			// a failed refresh serves the cached entry
+			user = cacheUser{}
+			So(c.GetOrLoad(ctx, "early", time.Minute, func(ctx context.Context) (interface{}, error) {
+				return nil, errors.New("load")
+			}, &user), ShouldBeNil)
+			So(user.Name, ShouldEqual, "jream")
+		})
+
+		Convey("codecs", func() {
+			c := NewCache("CacheTest", "proto:%s", SetCacheCodec(ProtoCodec))
+			So(c.Set(ctx, "1", time.Minute, &types.StringValue{Value: "v"}), ShouldBeNil)
+
+			var value types.StringValue
+			So(c.GetOrLoad(ctx, "1", time.Minute, loader, &value), ShouldBeNil)
+			So(value.Value, ShouldEqual, "v")
+
+			So(c.Set(ctx, "2", time.Minute, cacheUser{}), ShouldEqual, ErrNotProtoMessage)
+		})
+
+		Convey("not loaded instance falls back to loader", func() {
+			c := NewCache("None", "user:%s")
+
+			var user cacheUser
+			So(c.GetOrLoad(ctx, "1", time.Minute, loader, &user), ShouldBeNil)
+			So(user.ID, ShouldEqual, 1)
+		})
+	})
+}
@@ -1,11 +1,12 @@
 package redis
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"path"
+	"sync"
 
-	"github.com/BurntSushi/toml"
 	"github.com/JREAMLU/j-kit/consul"
 	"github.com/hashicorp/consul/api"
 )
@@ -13,13 +14,20 @@ import (
 // MasterSlave master slave
 type MasterSlave bool
 
+var (
+	cancels    []context.CancelFunc
+	watchMutex sync.Mutex
+)
+
 // Configs redis configs in consul
+// with Sentinel the master and slaves are discovered and followed on failover, Master and Slave are ignored
 type Configs struct {
 	InstanceName string
 	PoolSize     int64
 	IsCluster    bool
 	Master       []msConn
 	Slave        []msConn
+	Sentinel     *SentinelConfig
 }
 
 // msConn master & slave conn
@@ -37,32 +45,79 @@ func (masterSlave MasterSlave) String() string {
 	return "slave"
 }
 
-// Watch watch config
-func Watch(consulAddr string, reloadConfig chan string, names ...string) {
+// Watch watch config, the returned watcher is already running and can be stopped
+func Watch(consulAddr string, reloadConfig chan string, names ...string) *consul.Watcher {
+	w := consul.NewWatcher(consulAddr)
 	for i := range names {
-		go func(name string) {
-			consul.WatchKey(consulAddr, path.Join(consul.Redis, name), func(kvPair *api.KVPair) {
-				reloadConfig <- name
+		name := names[i]
+		w.AddKey(path.Join(consul.Redis, name), func(idx uint64, kvPair *api.KVPair) {
+			select {
+			case reloadConfig <- name:
+			case <-w.Done():
+			}
+		})
+	}
+
+	go func() {
+		if err := w.Start(context.Background()); err != nil {
+			log.Printf("Failed on redis Watch, names: %v, err: %v \r\n", names, err)
+		}
+	}()
+
+	return w
+}
+
+// WatchSource watch config of source until ctx done
+func WatchSource(ctx context.Context, source consul.ConfigSource, reloadConfig chan string, names ...string) {
+	for i := range names {
+		name := names[i]
+		go func() {
+			err := source.Watch(ctx, path.Join(consul.Redis, name), func(value string) {
+				select {
+				case reloadConfig <- name:
+				case <-ctx.Done():
+				}
 			})
-		}(names[i])
+			if err != nil {
+				log.Printf("Failed on redis WatchSource, name: %v, err: %v \r\n", name, err)
+			}
+		}()
+	}
+}
+
+// StopWatching stop all watchers and sentinels started by Load
+func StopWatching() {
+	watchMutex.Lock()
+	for i := range cancels {
+		cancels[i]()
 	}
+	cancels = nil
+	watchMutex.Unlock()
+
+	stopSentinels()
 }
 
-func watching(consulAddr string, names ...string) {
+func addCancel(cancel context.CancelFunc) {
+	watchMutex.Lock()
+	cancels = append(cancels, cancel)
+	watchMutex.Unlock()
+}
+
+func watching(source consul.ConfigSource, names ...string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	addCancel(cancel)
+
 	watchdNode := make(chan string)
-	Watch(consulAddr, watchdNode, names...)
+	WatchSource(ctx, source, watchdNode, names...)
 	go func() {
 		for {
 			select {
+			case <-ctx.Done():
+				return
 			case node := <-watchdNode:
 				log.Printf("changed: %v \r\n", node)
-				if err := LoadConfig(consulAddr, false, node); err != nil {
+				if err := LoadSource(source, false, node); err != nil {
 					log.Printf("Failed on redis LoadConfig, watchedNode: %v, err: %v \r\n", node, err)
-					continue
-				}
-
-				if group, ok := settings[node]; ok {
-					group.RefreshSetting = true
 				}
 			}
 		}
@@ -81,53 +136,53 @@ func LoadConfig(consulAddr string, isWatching bool, names ...string) error {
 		return err
 	}
 
+	return LoadSource(client, isWatching, names...)
+}
+
+// LoadSource load config from source
+func LoadSource(source consul.ConfigSource, isWatching bool, names ...string) error {
 	if len(names) == 0 {
-		if err = loadAll(client); err != nil {
+		if err := loadAll(source); err != nil {
 			return err
 		}
 	} else {
-		if err = loadByNames(client, names); err != nil {
+		if err := loadByNames(source, names); err != nil {
 			return err
 		}
 	}
 
 	if isWatching {
-		var nodes []string
-		for node := range settings {
-			nodes = append(nodes, node)
-		}
-
-		watching(consulAddr, nodes...)
+		watching(source, settings.Names()...)
 	}
 
 	return nil
 }
 
-func loadByNames(client *consul.Client, names []string) error {
+func loadByNames(source consul.ConfigSource, names []string) error {
 	for i := range names {
 		names[i] = path.Join(consul.Redis, names[i])
 	}
 
-	return loadConfig(client, names)
+	return loadConfig(source, names)
 }
 
-func loadAll(client *consul.Client) error {
-	keys, err := client.GetChildKeys(consul.Redis)
+func loadAll(source consul.ConfigSource) error {
+	keys, err := source.List(consul.Redis)
 	if err != nil {
 		return err
 	}
 
-	return loadConfig(client, keys)
+	return loadConfig(source, keys)
 }
 
-func loadConfig(client *consul.Client, prefixKeys []string) error {
+func loadConfig(source consul.ConfigSource, prefixKeys []string) error {
 	load := func(key, instanceName string, isMaster MasterSlave) error {
-		val, err := client.Get(key)
+		val, err := source.Get(key)
 		if err != nil {
 			return err
 		}
 
-		if err = loadNode(client, isMaster, val, instanceName); err != nil {
+		if err = loadNode(source, isMaster, val, instanceName); err != nil {
 			return err
 		}
 
@@ -144,39 +199,24 @@ func loadConfig(client *consul.Client, prefixKeys []string) error {
 	return nil
 }
 
-func loadNode(client *consul.Client, isMaster MasterSlave, val, instanceName string) error {
+func loadNode(source consul.ConfigSource, isMaster MasterSlave, val, instanceName string) error {
 	var configs Configs
-	if _, err := toml.Decode(val, &configs); err != nil {
+	if err := consul.DecodeSourceValue(source, path.Join(consul.Redis, instanceName), []byte(val), &configs); err != nil {
 		return err
 	}
 
-	_redisSettings := make(map[string]*Group, len(settings))
-	for k, v := range settings {
-		_redisSettings[k] = v
+	// a reload stops the sentinel of the previous config
+	swapSentinel(instanceName, nil)
+	if configs.Sentinel != nil {
+		return loadSentinel(instanceName, configs)
 	}
 
-	group, ok := _redisSettings[instanceName]
-	if !ok {
-		group = &Group{
-			Name:       instanceName,
-			PoolSize:   configs.PoolSize,
-			RedisConns: make([]Conn, 0),
-			IsCluster:  configs.IsCluster,
-		}
-
-		_redisSettings[instanceName] = group
-	} else {
-		if group.RefreshSetting {
-			group = &Group{
-				Name:        instanceName,
-				PoolSize:    configs.PoolSize,
-				RedisConns:  make([]Conn, 0),
-				IsCluster:   configs.IsCluster,
-				RefreshPool: true,
-			}
-
-			_redisSettings[instanceName] = group
-		}
+	// a new group every load, the stored one is never changed
+	group := &Group{
+		Name:       instanceName,
+		PoolSize:   configs.PoolSize,
+		RedisConns: make([]Conn, 0),
+		IsCluster:  configs.IsCluster,
 	}
 
 	if len(configs.Master) > 0 {
@@ -193,7 +233,7 @@ func loadNode(client *consul.Client, isMaster MasterSlave, val, instanceName str
 		}
 	}
 
-	settings = _redisSettings
+	settings.Store(instanceName, group)
 	return nil
 }
 
@@ -1,13 +1,29 @@
 package redis
 
 import (
+	"path"
+	"strings"
 	"testing"
 
+	"github.com/JREAMLU/j-kit/consul"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
 const (
 	consulAddr = "10.200.202.35:8500"
+	redisToml  = `InstanceName = "SourceTest"
+PoolSize = 10
+IsCluster = false
+
+[[master]]
+DB = "0"
+IP = "127.0.0.1"
+Port = "6379"
+
+[[slave]]
+DB = "1"
+IP = "127.0.0.1"
+Port = "6380"`
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -20,6 +36,44 @@ func TestLoadConfig(t *testing.T) {
 		Convey("load by names", func() {
 			err := Load(consulAddr, true, "CrawlerCluster")
 			So(err, ShouldBeNil)
+			StopWatching()
+		})
+	})
+}
+
+func TestLoadSource(t *testing.T) {
+	Convey("load redis from source test", t, func() {
+		source := consul.NewMemorySource(map[string]string{
+			path.Join(consul.Redis, "SourceTest"): redisToml,
+		})
+
+		err := LoadSource(source, false)
+		So(err, ShouldBeNil)
+
+		group, ok := settings.Get("SourceTest")
+		So(ok, ShouldBeTrue)
+		So(group.PoolSize, ShouldEqual, 10)
+		So(len(group.RedisConns), ShouldEqual, 2)
+		So(group.RedisConns[0].ConnStr, ShouldEqual, "127.0.0.1:6379")
+		So(group.RedisConns[0].IsMaster, ShouldBeTrue)
+		So(group.RedisConns[1].IsMaster, ShouldBeFalse)
+
+		err = LoadSource(source, false, "None")
+		So(err, ShouldNotBeNil)
+
+		Convey("reload", func() {
+			var reloaded []string
+			OnReload(func(name string, group *Group) {
+				reloaded = append(reloaded, name)
+			})
+
+			s := NewStructure("SourceTest", "test:%s")
+			So(s.getConnstr(SLAVE), ShouldEqual, "127.0.0.1:6380")
+
+			source.Set(path.Join(consul.Redis, "SourceTest"), strings.Replace(redisToml, "6380", "6381", 1))
+			So(LoadSource(source, false, "SourceTest"), ShouldBeNil)
+			So(reloaded, ShouldResemble, []string{"SourceTest"})
+			So(s.getConnstr(SLAVE), ShouldEqual, "127.0.0.1:6381")
 		})
 	})
 }
@@ -0,0 +1,83 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// doContext do cmd honor ctx, conns without context support fall back to the deadline of ctx
+func doContext(ctx context.Context, conn redis.Conn, cmd string, params ...interface{}) (interface{}, error) {
+	if ctx.Done() == nil {
+		return conn.Do(cmd, params...)
+	}
+
+	if c, ok := conn.(redis.ConnWithContext); ok {
+		return c.DoContext(ctx, cmd, params...)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if c, ok := conn.(redis.ConnWithTimeout); ok {
+			return c.DoWithTimeout(time.Until(deadline), cmd, params...)
+		}
+	}
+
+	return conn.Do(cmd, params...)
+}
+
+// receiveContext receive reply honor ctx
+func receiveContext(ctx context.Context, conn redis.Conn) (interface{}, error) {
+	if ctx.Done() == nil {
+		return conn.Receive()
+	}
+
+	if c, ok := conn.(redis.ConnWithContext); ok {
+		return c.ReceiveContext(ctx)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if c, ok := conn.(redis.ConnWithTimeout); ok {
+			return c.ReceiveWithTimeout(time.Until(deadline))
+		}
+	}
+
+	return conn.Receive()
+}
+
+// scriptDo do lua script honor ctx
+func scriptDo(ctx context.Context, script *redis.Script, conn redis.Conn, keysAndArgs ...interface{}) (interface{}, error) {
+	if _, ok := conn.(redis.ConnWithContext); ok && ctx.Done() != nil {
+		return script.DoContext(ctx, conn, keysAndArgs...)
+	}
+
+	return script.Do(conn, keysAndArgs...)
+}
+
+// startSpan start a child span of the span in ctx, nil if ctx has none
+func startSpan(ctx context.Context, instanceName, cmd string) opentracing.Span {
+	parent := opentracing.SpanFromContext(ctx)
+	if parent == nil {
+		return nil
+	}
+
+	span := parent.Tracer().StartSpan("redis "+cmd, opentracing.ChildOf(parent.Context()))
+	span.SetTag("db.type", "redis")
+	span.SetTag("db.instance", instanceName)
+
+	return span
+}
+
+func finishSpan(span opentracing.Span, err error) {
+	if span == nil {
+		return
+	}
+
+	if err != nil && err != redis.ErrNil {
+		span.SetTag("error", true)
+		span.LogKV("event", "error", "message", err.Error())
+	}
+
+	span.Finish()
+}
@@ -0,0 +1,98 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JREAMLU/j-kit/consul"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// slowRedis fake redis, replies SELECT and never replies other commands
+func slowRedis(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+
+					// bulk string of command name, eg. $6\r\nSELECT\r\n
+					if strings.HasPrefix(line, "$") {
+						name, err := reader.ReadString('\n')
+						if err != nil {
+							return
+						}
+
+						if strings.EqualFold(strings.TrimSpace(name), "SELECT") {
+							conn.Write([]byte("+OK\r\n"))
+						}
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return listener
+}
+
+func TestContext(t *testing.T) {
+	Convey("redis context test", t, func() {
+		listener := slowRedis(t)
+		defer listener.Close()
+
+		host, port, _ := net.SplitHostPort(listener.Addr().String())
+		source := consul.NewMemorySource(map[string]string{
+			path.Join(consul.Redis, "ContextTest"): "InstanceName = \"ContextTest\"\n[[master]]\nDB = \"0\"\nIP = \"" + host + "\"\nPort = \"" + port + "\"",
+		})
+		So(LoadSource(source, false, "ContextTest"), ShouldBeNil)
+
+		s := NewString("ContextTest", "test:%s")
+
+		Convey("deadline", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			_, err := s.WithContext(ctx).Get("a")
+			So(err, ShouldNotBeNil)
+			So(time.Since(start), ShouldBeLessThan, ReadTimeout)
+		})
+
+		Convey("canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err := s.WithContext(ctx).Set("a", "b", 0)
+			So(err, ShouldEqual, context.Canceled)
+
+			_, err = s.DoContext(ctx, MASTER, GET, s.InitKey("a"))
+			So(err, ShouldEqual, context.Canceled)
+		})
+
+		Convey("not loaded", func() {
+			none := NewString("None", "test:%s")
+			_, err := none.WithContext(context.Background()).Get("a")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
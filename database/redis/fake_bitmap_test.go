@@ -0,0 +1,169 @@
+package redis
+
+import (
+	"strconv"
+	"strings"
+)
+
+// bitmap bitmap and hyperloglog commands of the fake redis
+// bitmaps are strings, hyperloglogs are exact sets in hashes
+func (f *fakeRedis) bitmap(args []string) (interface{}, bool) {
+	switch strings.ToUpper(args[0]) {
+	case "SETBIT":
+		offset, _ := strconv.ParseInt(args[2], 10, 64)
+		old := fakeGetBit(f.strings[args[1]], offset)
+		f.strings[args[1]] = fakeSetBit(f.strings[args[1]], offset, args[3] == "1")
+		return old, true
+	case "GETBIT":
+		offset, _ := strconv.ParseInt(args[2], 10, 64)
+		return fakeGetBit(f.strings[args[1]], offset), true
+	case "BITCOUNT":
+		value := f.strings[args[1]]
+		var n int64
+		for i := int64(0); i < int64(len(value))*8; i++ {
+			n += fakeGetBit(value, i)
+		}
+		return n, true
+	case "BITFIELD":
+		return f.bitfield(args), true
+	case "BITFIELD_RO":
+		for i := 2; i < len(args); i += 3 {
+			if strings.ToUpper(args[i]) != "GET" {
+				return fakeError("ERR BITFIELD_RO only supports the GET subcommand"), true
+			}
+		}
+		return f.bitfield(args), true
+	case "PFADD":
+		hll, ok := f.hashes[args[1]]
+		if !ok {
+			hll = make(map[string]string)
+			f.hashes[args[1]] = hll
+		}
+
+		var changed int64
+		for _, element := range args[2:] {
+			if _, ok := hll[element]; !ok {
+				hll[element] = ""
+				changed = 1
+			}
+		}
+		return changed, true
+	case "PFCOUNT":
+		union := make(map[string]bool)
+		for _, key := range args[1:] {
+			for element := range f.hashes[key] {
+				union[element] = true
+			}
+		}
+		return int64(len(union)), true
+	case "PFMERGE":
+		dest, ok := f.hashes[args[1]]
+		if !ok {
+			dest = make(map[string]string)
+			f.hashes[args[1]] = dest
+		}
+
+		for _, key := range args[2:] {
+			for element := range f.hashes[key] {
+				dest[element] = ""
+			}
+		}
+		return fakeStatus("OK"), true
+	}
+
+	return nil, false
+}
+
+// bitfield GET, SET, INCRBY of signed and unsigned fields, OVERFLOW WRAP and FAIL
+func (f *fakeRedis) bitfield(args []string) interface{} {
+	key := args[1]
+	overflow := "WRAP"
+
+	var reply []interface{}
+	for i := 2; i < len(args); {
+		op := strings.ToUpper(args[i])
+		if op == "OVERFLOW" {
+			overflow = strings.ToUpper(args[i+1])
+			i += 2
+			continue
+		}
+
+		signed := args[i+1][0] == 'i'
+		bits, _ := strconv.ParseInt(args[i+1][1:], 10, 64)
+		offset, _ := strconv.ParseInt(args[i+2], 10, 64)
+
+		var old uint64
+		for b := int64(0); b < bits; b++ {
+			old = old<<1 | uint64(fakeGetBit(f.strings[key], offset+b))
+		}
+		oldValue := int64(old)
+		if signed && bits < 64 && old&(1<<uint(bits-1)) != 0 {
+			oldValue -= 1 << uint(bits)
+		}
+
+		if op == "GET" {
+			reply = append(reply, oldValue)
+			i += 3
+			continue
+		}
+
+		n, _ := strconv.ParseInt(args[i+3], 10, 64)
+		i += 4
+
+		value := n
+		if op == "INCRBY" {
+			value = oldValue + n
+		}
+
+		min, max := int64(0), int64(1)<<uint(bits)-1
+		if signed {
+			min, max = -(int64(1) << uint(bits-1)), int64(1)<<uint(bits-1)-1
+		}
+		if value < min || value > max {
+			if overflow == "FAIL" {
+				reply = append(reply, nil)
+				continue
+			}
+			value &= int64(1)<<uint(bits) - 1
+			if signed && value > max {
+				value -= 1 << uint(bits)
+			}
+		}
+
+		for b := int64(0); b < bits; b++ {
+			set := uint64(value)>>uint(bits-1-b)&1 == 1
+			f.strings[key] = fakeSetBit(f.strings[key], offset+b, set)
+		}
+
+		if op == "SET" {
+			reply = append(reply, oldValue)
+		} else {
+			reply = append(reply, value)
+		}
+	}
+
+	return reply
+}
+
+func fakeGetBit(value string, offset int64) int64 {
+	if offset/8 >= int64(len(value)) {
+		return 0
+	}
+
+	return int64(value[offset/8]>>uint(7-offset%8)) & 1
+}
+
+func fakeSetBit(value string, offset int64, set bool) string {
+	buf := []byte(value)
+	for int64(len(buf)) <= offset/8 {
+		buf = append(buf, 0)
+	}
+
+	if set {
+		buf[offset/8] |= 1 << uint(7-offset%8)
+	} else {
+		buf[offset/8] &^= 1 << uint(7-offset%8)
+	}
+
+	return string(buf)
+}
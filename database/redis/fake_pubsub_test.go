@@ -0,0 +1,71 @@
+package redis
+
+import (
+	"path"
+	"strings"
+)
+
+// pubsub exec pubsub commands, false if args is not one
+func (f *fakeRedis) pubsub(session *fakeSession, args []string) (interface{}, bool) {
+	cmd := strings.ToUpper(args[0])
+	switch cmd {
+	case "SUBSCRIBE", "PSUBSCRIBE", "UNSUBSCRIBE", "PUNSUBSCRIBE":
+		if session.channels == nil {
+			session.channels = make(map[string]bool)
+			session.patterns = make(map[string]bool)
+		}
+
+		names := session.channels
+		if strings.HasPrefix(cmd, "P") {
+			names = session.patterns
+		}
+
+		keys := args[1:]
+		if len(keys) == 0 && strings.Contains(cmd, "UNSUBSCRIBE") {
+			for name := range names {
+				keys = append(keys, name)
+			}
+		}
+
+		replies := fakeReplies{}
+		for _, key := range keys {
+			if strings.Contains(cmd, "UNSUBSCRIBE") {
+				delete(names, key)
+			} else {
+				names[key] = true
+			}
+
+			count := int64(len(session.channels) + len(session.patterns))
+			replies = append(replies, []interface{}{strings.ToLower(cmd), key, count})
+		}
+		return replies, true
+	case "PING":
+		if len(session.channels)+len(session.patterns) == 0 {
+			return nil, false
+		}
+
+		data := ""
+		if len(args) > 1 {
+			data = args[1]
+		}
+		return []interface{}{"pong", data}, true
+	case "PUBLISH":
+		var n int64
+		for other := range f.sessions {
+			if other.channels[args[1]] {
+				other.push([]interface{}{"message", args[1], args[2]})
+				n++
+			}
+
+			for pattern := range other.patterns {
+				if ok, _ := path.Match(pattern, args[1]); ok {
+					other.push([]interface{}{"pmessage", pattern, args[1], args[2]})
+					n++
+				}
+			}
+		}
+		return n, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,500 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JREAMLU/j-kit/consul"
+)
+
+// fakeRedis fake redis of a few commands in memory
+type fakeRedis struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	strings  map[string]string
+	hashes   map[string]map[string]string
+	versions map[string]int64
+	expires  map[string]time.Time
+	streams  map[string]*fakeStream
+	sessions map[*fakeSession]bool
+	// masters monitored when it is a fake sentinel
+	masters map[string]*fakeMaster
+	// scripts go implementations of lua scripts, by body
+	scripts map[string]func(f *fakeRedis, keys, args []string) interface{}
+	// readonly replica, write commands are rejected
+	readonly bool
+	// legacyClaim xclaim of redis before 7, deleted messages reply nil and stay pending
+	legacyClaim bool
+}
+
+// fakeSession transaction and subscription state of one conn
+type fakeSession struct {
+	multi    bool
+	queued   [][]string
+	watched  map[string]int64
+	channels map[string]bool
+	patterns map[string]bool
+
+	conn   net.Conn
+	writer *bufio.Writer
+	// wmutex writes of replies and pushed messages
+	wmutex sync.Mutex
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeRedis{
+		listener: listener,
+		strings:  make(map[string]string),
+		hashes:   make(map[string]map[string]string),
+		versions: make(map[string]int64),
+		expires:  make(map[string]time.Time),
+		sessions: make(map[*fakeSession]bool),
+		masters:  make(map[string]*fakeMaster),
+		scripts:  make(map[string]func(f *fakeRedis, keys, args []string) interface{}),
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go f.serve(conn)
+		}
+	}()
+
+	return f
+}
+
+// script register the go implementation of lua body
+func (f *fakeRedis) script(body string, fn func(f *fakeRedis, keys, args []string) interface{}) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.scripts[body] = fn
+}
+
+// expire delete keys past their expiry
+func (f *fakeRedis) expire() {
+	now := time.Now()
+	for key, at := range f.expires {
+		if now.After(at) {
+			delete(f.strings, key)
+			delete(f.hashes, key)
+			delete(f.expires, key)
+			f.versions[key]++
+		}
+	}
+}
+
+// load load instanceName of the fake redis
+func (f *fakeRedis) load(t *testing.T, instanceName string) {
+	host, port, _ := net.SplitHostPort(f.listener.Addr().String())
+	source := consul.NewMemorySource(map[string]string{
+		path.Join(consul.Redis, instanceName): fmt.Sprintf("InstanceName = %q\n[[master]]\nDB = \"0\"\nIP = %q\nPort = %q\n[[slave]]\nDB = \"0\"\nIP = %q\nPort = %q",
+			instanceName, host, port, host, port),
+	})
+
+	if err := LoadSource(source, false, instanceName); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// loadReplica load instanceName with f as master and replica as the read-only slave
+func (f *fakeRedis) loadReplica(t *testing.T, instanceName string, replica *fakeRedis) {
+	host, port, _ := net.SplitHostPort(f.listener.Addr().String())
+	replicaHost, replicaPort, _ := net.SplitHostPort(replica.listener.Addr().String())
+	replica.mutex.Lock()
+	replica.readonly = true
+	replica.mutex.Unlock()
+
+	source := consul.NewMemorySource(map[string]string{
+		path.Join(consul.Redis, instanceName): fmt.Sprintf("InstanceName = %q\n[[master]]\nDB = \"0\"\nIP = %q\nPort = %q\n[[slave]]\nDB = \"0\"\nIP = %q\nPort = %q",
+			instanceName, host, port, replicaHost, replicaPort),
+	})
+
+	if err := LoadSource(source, false, instanceName); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *fakeRedis) close() {
+	f.listener.Close()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	session := &fakeSession{conn: conn, writer: bufio.NewWriter(conn)}
+
+	f.mutex.Lock()
+	f.sessions[session] = true
+	f.mutex.Unlock()
+
+	defer func() {
+		f.mutex.Lock()
+		delete(f.sessions, session)
+		f.mutex.Unlock()
+	}()
+
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+
+		// blocking reads wait for data until BLOCK elapsed
+		var reply interface{}
+		for deadline := time.Now().Add(streamBlock(args)); ; time.Sleep(10 * time.Millisecond) {
+			f.mutex.Lock()
+			reply = f.transact(session, args)
+			f.mutex.Unlock()
+
+			if _, ok := reply.(fakeNilArray); !ok || session.multi || time.Now().After(deadline) {
+				break
+			}
+		}
+
+		session.wmutex.Lock()
+		writeReply(session.writer, reply)
+
+		// flush when no more pipelined commands buffered
+		if reader.Buffered() == 0 {
+			err = session.writer.Flush()
+		}
+		session.wmutex.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// push write reply to the conn of session at once
+func (session *fakeSession) push(reply interface{}) {
+	session.wmutex.Lock()
+	defer session.wmutex.Unlock()
+
+	writeReply(session.writer, reply)
+	session.writer.Flush()
+}
+
+// kick close all conns, clients see a lost conn
+func (f *fakeRedis) kick() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for session := range f.sessions {
+		session.conn.Close()
+	}
+}
+
+// has true if key of a string exists
+func (f *fakeRedis) has(key string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	_, ok := f.strings[key]
+	return ok
+}
+
+type fakeStatus string
+
+type fakeError string
+
+// fakeReplies several replies of one command, eg. SUBSCRIBE of channels
+type fakeReplies []interface{}
+
+// fakeNilArray nil multi bulk, reply of aborted EXEC
+type fakeNilArray struct{}
+
+func (f *fakeRedis) transact(session *fakeSession, args []string) interface{} {
+	if reply, ok := f.pubsub(session, args); ok {
+		return reply
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "MULTI":
+		session.multi = true
+		return fakeStatus("OK")
+	case "WATCH":
+		if session.watched == nil {
+			session.watched = make(map[string]int64)
+		}
+		for _, key := range args[1:] {
+			session.watched[key] = f.versions[key]
+		}
+		return fakeStatus("OK")
+	case "UNWATCH":
+		session.watched = nil
+		return fakeStatus("OK")
+	case "DISCARD":
+		session.multi, session.queued, session.watched = false, nil, nil
+		return fakeStatus("OK")
+	case "EXEC":
+		queued, watched := session.queued, session.watched
+		session.multi, session.queued, session.watched = false, nil, nil
+		for key, version := range watched {
+			if f.versions[key] != version {
+				return fakeNilArray{}
+			}
+		}
+
+		replies := make([]interface{}, len(queued))
+		for i := range queued {
+			replies[i] = f.exec(queued[i])
+		}
+		return replies
+	}
+
+	if session.multi {
+		session.queued = append(session.queued, args)
+		return fakeStatus("QUEUED")
+	}
+
+	return f.exec(args)
+}
+
+func (f *fakeRedis) exec(args []string) interface{} {
+	f.expire()
+
+	switch strings.ToUpper(args[0]) {
+	case "DEL":
+		for _, key := range args[1:] {
+			f.versions[key]++
+		}
+	case "SET", "SETEX", "HSET", "HMSET", "HDEL", "INCR", "PEXPIRE", "SETBIT", "BITFIELD", "PFADD", "PFMERGE":
+		if f.readonly {
+			return fakeError("READONLY You can't write against a read only replica.")
+		}
+		f.versions[args[1]]++
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "SELECT":
+		return fakeStatus("OK")
+	case "PING":
+		return fakeStatus("PONG")
+	case "GET":
+		if value, ok := f.strings[args[1]]; ok {
+			return value
+		}
+		return nil
+	case "SET":
+		var ttl time.Duration
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				if _, ok := f.strings[args[1]]; ok {
+					return nil
+				}
+			case "PX", "EX":
+				n, _ := strconv.ParseInt(args[i+1], 10, 64)
+				ttl = time.Duration(n) * time.Millisecond
+				if strings.ToUpper(args[i]) == "EX" {
+					ttl *= 1000
+				}
+				i++
+			}
+		}
+
+		f.strings[args[1]] = args[2]
+		delete(f.expires, args[1])
+		if ttl > 0 {
+			f.expires[args[1]] = time.Now().Add(ttl)
+		}
+		return fakeStatus("OK")
+	case "SETEX":
+		n, _ := strconv.ParseInt(args[2], 10, 64)
+		f.strings[args[1]] = args[3]
+		f.expires[args[1]] = time.Now().Add(time.Duration(n) * time.Second)
+		return fakeStatus("OK")
+	case "PEXPIRE":
+		if _, ok := f.strings[args[1]]; !ok {
+			return int64(0)
+		}
+		n, _ := strconv.ParseInt(args[2], 10, 64)
+		f.expires[args[1]] = time.Now().Add(time.Duration(n) * time.Millisecond)
+		return int64(1)
+	case "PTTL":
+		if _, ok := f.strings[args[1]]; !ok {
+			return int64(-2)
+		}
+		at, ok := f.expires[args[1]]
+		if !ok {
+			return int64(-1)
+		}
+		return int64(time.Until(at) / time.Millisecond)
+	case "EVALSHA":
+		return fakeError("NOSCRIPT No matching script")
+	case "EVAL":
+		fn, ok := f.scripts[args[1]]
+		if !ok {
+			return fakeError("ERR unknown script")
+		}
+		n, _ := strconv.Atoi(args[2])
+		return fn(f, args[3:3+n], args[3+n:])
+	case "INCR":
+		n, err := strconv.ParseInt(f.strings[args[1]], 10, 64)
+		if err != nil && f.strings[args[1]] != "" {
+			return fakeError("ERR value is not an integer")
+		}
+		f.strings[args[1]] = strconv.FormatInt(n+1, 10)
+		return n + 1
+	case "DEL":
+		var n int64
+		for _, key := range args[1:] {
+			if _, ok := f.strings[key]; ok {
+				n++
+			}
+			if _, ok := f.hashes[key]; ok {
+				n++
+			}
+			delete(f.strings, key)
+			delete(f.hashes, key)
+			delete(f.expires, key)
+			delete(f.streams, key)
+		}
+		return n
+	case "HSET", "HMSET":
+		hash, ok := f.hashes[args[1]]
+		if !ok {
+			hash = make(map[string]string)
+			f.hashes[args[1]] = hash
+		}
+
+		var n int64
+		for i := 2; i+1 < len(args); i += 2 {
+			if _, ok := hash[args[i]]; !ok {
+				n++
+			}
+			hash[args[i]] = args[i+1]
+		}
+
+		if strings.ToUpper(args[0]) == "HMSET" {
+			return fakeStatus("OK")
+		}
+		return n
+	case "HGET":
+		if value, ok := f.hashes[args[1]][args[2]]; ok {
+			return value
+		}
+		return nil
+	case "HDEL":
+		var n int64
+		for _, field := range args[2:] {
+			if _, ok := f.hashes[args[1]][field]; ok {
+				delete(f.hashes[args[1]], field)
+				n++
+			}
+		}
+		return n
+	case "HGETALL":
+		hash := f.hashes[args[1]]
+		fields := make([]string, 0, len(hash))
+		for field := range hash {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		reply := make([]interface{}, 0, len(hash)*2)
+		for _, field := range fields {
+			reply = append(reply, field, hash[field])
+		}
+		return reply
+	default:
+		if reply, ok := f.stream(args); ok {
+			return reply
+		}
+		if reply, ok := f.bitmap(args); ok {
+			return reply
+		}
+		if reply, ok := f.sentinel(args); ok {
+			return reply
+		}
+		return fakeError("ERR unknown command '" + args[0] + "'")
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		if line, err = readLine(reader); err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+
+	return args, nil
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func writeReply(writer *bufio.Writer, reply interface{}) {
+	switch r := reply.(type) {
+	case nil:
+		writer.WriteString("$-1\r\n")
+	case fakeNilArray:
+		writer.WriteString("*-1\r\n")
+	case fakeReplies:
+		for _, item := range r {
+			writeReply(writer, item)
+		}
+	case fakeStatus:
+		writer.WriteString("+" + string(r) + "\r\n")
+	case fakeError:
+		writer.WriteString("-" + string(r) + "\r\n")
+	case int64:
+		writer.WriteString(":" + strconv.FormatInt(r, 10) + "\r\n")
+	case string:
+		writer.WriteString("$" + strconv.Itoa(len(r)) + "\r\n" + r + "\r\n")
+	case []interface{}:
+		writer.WriteString("*" + strconv.Itoa(len(r)) + "\r\n")
+		for _, item := range r {
+			writeReply(writer, item)
+		}
+	}
+}
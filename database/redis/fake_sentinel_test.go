@@ -0,0 +1,88 @@
+package redis
+
+import (
+	"net"
+	"strings"
+)
+
+// fakeMaster master monitored by the fake sentinel, addrs are ip:port
+type fakeMaster struct {
+	addr     string
+	replicas []string
+	down     map[string]bool
+}
+
+// addr ip:port of the fake redis
+func (f *fakeRedis) addr() string {
+	return f.listener.Addr().String()
+}
+
+// monitor set master and replicas of name
+func (f *fakeRedis) monitor(name, master string, replicas ...string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.masters[name] = &fakeMaster{addr: master, replicas: replicas, down: make(map[string]bool)}
+}
+
+// failover switch master of name and publish +switch-master
+func (f *fakeRedis) failover(name, master string, replicas ...string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	old := f.masters[name].addr
+	f.masters[name] = &fakeMaster{addr: master, replicas: replicas, down: make(map[string]bool)}
+
+	oldHost, oldPort, _ := net.SplitHostPort(old)
+	host, port, _ := net.SplitHostPort(master)
+	f.pubsub(nil, []string{"PUBLISH", "+switch-master", strings.Join([]string{name, oldHost, oldPort, host, port}, " ")})
+}
+
+// sdown mark replica of name down and publish +sdown
+func (f *fakeRedis) sdown(name, replica string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	master := f.masters[name]
+	master.down[replica] = true
+
+	host, port, _ := net.SplitHostPort(replica)
+	masterHost, masterPort, _ := net.SplitHostPort(master.addr)
+	f.pubsub(nil, []string{"PUBLISH", "+sdown", strings.Join([]string{"slave", replica, host, port, "@", name, masterHost, masterPort}, " ")})
+}
+
+// sentinel SENTINEL get-master-addr-by-name and slaves of the fake sentinel
+func (f *fakeRedis) sentinel(args []string) (interface{}, bool) {
+	if strings.ToUpper(args[0]) != "SENTINEL" {
+		return nil, false
+	}
+
+	master, ok := f.masters[args[2]]
+	switch strings.ToLower(args[1]) {
+	case "get-master-addr-by-name":
+		if !ok {
+			return fakeNilArray{}, true
+		}
+
+		host, port, _ := net.SplitHostPort(master.addr)
+		return []interface{}{host, port}, true
+	case "slaves", "replicas":
+		if !ok {
+			return fakeError("ERR No such master with that name"), true
+		}
+
+		reply := []interface{}{}
+		for _, replica := range master.replicas {
+			host, port, _ := net.SplitHostPort(replica)
+			flags := "slave"
+			if master.down[replica] {
+				flags = "slave,s_down"
+			}
+
+			reply = append(reply, []interface{}{"name", replica, "ip", host, "port", port, "flags", flags, "master-link-status", "ok"})
+		}
+		return reply, true
+	}
+
+	return fakeError("ERR unknown sentinel subcommand"), true
+}
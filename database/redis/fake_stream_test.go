@@ -0,0 +1,269 @@
+package redis
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fakeStream stream of the fake redis, ids are "<seq>-0"
+type fakeStream struct {
+	seq     int64
+	entries []fakeEntry
+	groups  map[string]*fakeGroup
+}
+
+type fakeEntry struct {
+	seq    int64
+	fields []string
+}
+
+type fakeGroup struct {
+	last    int64
+	pending map[int64]*fakePending
+}
+
+type fakePending struct {
+	consumer   string
+	delivered  time.Time
+	deliveries int64
+}
+
+// streamBlock BLOCK of XREADGROUP, 0 for others
+func streamBlock(args []string) time.Duration {
+	if strings.ToUpper(args[0]) != "XREADGROUP" {
+		return 0
+	}
+
+	for i := 1; i+1 < len(args); i++ {
+		if strings.ToUpper(args[i]) == "BLOCK" {
+			n, _ := strconv.ParseInt(args[i+1], 10, 64)
+			return time.Duration(n) * time.Millisecond
+		}
+	}
+
+	return 0
+}
+
+func fakeSeq(id string, def int64) int64 {
+	switch id {
+	case "-", "0":
+		return 0
+	case "+":
+		return def
+	}
+
+	n, _ := strconv.ParseInt(strings.SplitN(id, "-", 2)[0], 10, 64)
+	return n
+}
+
+func (e fakeEntry) reply() []interface{} {
+	fields := make([]interface{}, len(e.fields))
+	for i := range e.fields {
+		fields[i] = e.fields[i]
+	}
+
+	return []interface{}{fmt.Sprintf("%d-0", e.seq), fields}
+}
+
+func (st *fakeStream) find(seq int64) (fakeEntry, bool) {
+	for _, entry := range st.entries {
+		if entry.seq == seq {
+			return entry, true
+		}
+	}
+
+	return fakeEntry{}, false
+}
+
+// stream exec stream commands, false if args is not one
+func (f *fakeRedis) stream(args []string) (interface{}, bool) {
+	cmd := strings.ToUpper(args[0])
+	if !strings.HasPrefix(cmd, "X") {
+		return nil, false
+	}
+
+	key := args[1]
+	switch cmd {
+	case "XGROUP":
+		key = args[2]
+	case "XREADGROUP":
+		key = args[len(args)-2]
+	}
+
+	if f.streams == nil {
+		f.streams = make(map[string]*fakeStream)
+	}
+
+	st, ok := f.streams[key]
+	if !ok {
+		st = &fakeStream{groups: make(map[string]*fakeGroup)}
+	}
+
+	switch cmd {
+	case "XADD":
+		var maxLen int
+		i := 2
+		if strings.ToUpper(args[i]) == "MAXLEN" {
+			i++
+			if args[i] == "~" {
+				i++
+			}
+			maxLen, _ = strconv.Atoi(args[i])
+			i++
+		}
+
+		st.seq++
+		st.entries = append(st.entries, fakeEntry{seq: st.seq, fields: args[i+1:]})
+		if maxLen > 0 && len(st.entries) > maxLen {
+			st.entries = st.entries[len(st.entries)-maxLen:]
+		}
+		f.streams[key] = st
+		return fmt.Sprintf("%d-0", st.seq), true
+	case "XLEN":
+		return int64(len(st.entries)), true
+	case "XRANGE", "XREVRANGE":
+		start, end := fakeSeq(args[2], st.seq), fakeSeq(args[3], st.seq)
+		if cmd == "XREVRANGE" {
+			start, end = end, start
+		}
+
+		count := len(st.entries)
+		if len(args) == 6 {
+			count, _ = strconv.Atoi(args[5])
+		}
+
+		var entries []fakeEntry
+		for _, entry := range st.entries {
+			if entry.seq >= start && entry.seq <= end {
+				entries = append(entries, entry)
+			}
+		}
+
+		if cmd == "XREVRANGE" {
+			sort.Slice(entries, func(i, j int) bool { return entries[i].seq > entries[j].seq })
+		}
+
+		reply := []interface{}{}
+		for i := 0; i < len(entries) && i < count; i++ {
+			reply = append(reply, entries[i].reply())
+		}
+		return reply, true
+	case "XGROUP":
+		if _, ok := st.groups[args[3]]; ok {
+			return fakeError("BUSYGROUP Consumer Group name already exists"), true
+		}
+
+		last := st.seq
+		if args[4] != "$" {
+			last = fakeSeq(args[4], st.seq)
+		}
+		st.groups[args[3]] = &fakeGroup{last: last, pending: make(map[int64]*fakePending)}
+		f.streams[key] = st
+		return fakeStatus("OK"), true
+	case "XREADGROUP":
+		group, ok := st.groups[args[2]]
+		if !ok {
+			return fakeError("NOGROUP No such key or consumer group"), true
+		}
+
+		count := len(st.entries)
+		for i := 4; i+1 < len(args); i++ {
+			if strings.ToUpper(args[i]) == "COUNT" {
+				count, _ = strconv.Atoi(args[i+1])
+			}
+		}
+
+		entries := []interface{}{}
+		for _, entry := range st.entries {
+			if entry.seq > group.last && len(entries) < count {
+				group.last = entry.seq
+				group.pending[entry.seq] = &fakePending{consumer: args[3], delivered: time.Now(), deliveries: 1}
+				entries = append(entries, entry.reply())
+			}
+		}
+
+		if len(entries) == 0 {
+			return fakeNilArray{}, true
+		}
+		return []interface{}{[]interface{}{key, entries}}, true
+	case "XACK":
+		var n int64
+		if group, ok := st.groups[args[2]]; ok {
+			for _, id := range args[3:] {
+				if _, ok := group.pending[fakeSeq(id, 0)]; ok {
+					delete(group.pending, fakeSeq(id, 0))
+					n++
+				}
+			}
+		}
+		return n, true
+	case "XPENDING":
+		group, ok := st.groups[args[2]]
+		if !ok {
+			return fakeError("NOGROUP No such key or consumer group"), true
+		}
+
+		seqs := make([]int64, 0, len(group.pending))
+		for seq := range group.pending {
+			seqs = append(seqs, seq)
+		}
+		sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+		count, _ := strconv.Atoi(args[5])
+		reply := []interface{}{}
+		for i := 0; i < len(seqs) && i < count; i++ {
+			pending := group.pending[seqs[i]]
+			reply = append(reply, []interface{}{
+				fmt.Sprintf("%d-0", seqs[i]), pending.consumer,
+				int64(time.Since(pending.delivered) / time.Millisecond), pending.deliveries,
+			})
+		}
+		return reply, true
+	case "XCLAIM":
+		group, ok := st.groups[args[2]]
+		if !ok {
+			return fakeError("NOGROUP No such key or consumer group"), true
+		}
+
+		minIdle, _ := strconv.ParseInt(args[4], 10, 64)
+		reply := []interface{}{}
+		for _, id := range args[5:] {
+			pending, ok := group.pending[fakeSeq(id, 0)]
+			if !ok || time.Since(pending.delivered) < time.Duration(minIdle)*time.Millisecond {
+				continue
+			}
+
+			pending.consumer, pending.delivered = args[3], time.Now()
+			pending.deliveries++
+
+			entry, ok := st.find(fakeSeq(id, 0))
+			if !ok && f.legacyClaim {
+				reply = append(reply, nil)
+				continue
+			}
+			if !ok {
+				delete(group.pending, fakeSeq(id, 0))
+				continue
+			}
+			reply = append(reply, entry.reply())
+		}
+		return reply, true
+	case "XDEL":
+		var n int64
+		for _, id := range args[2:] {
+			for i, entry := range st.entries {
+				if entry.seq == fakeSeq(id, 0) {
+					st.entries = append(st.entries[:i], st.entries[i+1:]...)
+					n++
+					break
+				}
+			}
+		}
+		return n, true
+	}
+
+	return nil, false
+}
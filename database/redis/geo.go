@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"errors"
 
 	"github.com/JREAMLU/j-kit/constant"
@@ -27,6 +28,11 @@ func NewGeo(instanceName, keyPrefixFmt string) Geo {
 	}
 }
 
+// WithContext copy of geo, commands honor deadline and cancellation of ctx
+func (g *Geo) WithContext(ctx context.Context) *Geo {
+	return &Geo{Structure: g.Structure.WithContext(ctx)}
+}
+
 // Add add
 func (g *Geo) Add(keySuffix string, longitude, latitude, member interface{}) (int64, error) {
 	return g.Int64(MASTER, GEOADD, g.InitKey(keySuffix), longitude, latitude, member)
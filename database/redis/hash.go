@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"errors"
 	"strconv"
 
@@ -52,6 +53,11 @@ func NewHash(instanceName, keyPrefixFmt string) Hash {
 	}
 }
 
+// WithContext copy of hash, commands honor deadline and cancellation of ctx
+func (h *Hash) WithContext(ctx context.Context) *Hash {
+	return &Hash{Structure: h.Structure.WithContext(ctx)}
+}
+
 // Delete hash delete
 func (h *Hash) Delete(keySuffix string, fields ...interface{}) (bool, error) {
 	if len(fields) == 0 {
@@ -202,25 +208,57 @@ func (h *Hash) MSetSafe(keySuffix string, blockSize int, fields ...interface{})
 		blockSize = _blockSize
 	}
 
-	index := 0
-	for {
-		if index >= len(fields) {
-			return OK, nil
+	// blocks sent in one round trip
+	pipe := h.Pipeline(MASTER)
+	replies := make([]*PipelineReply, 0, len(fields)/blockSize+1)
+	for index := 0; index < len(fields); index += blockSize {
+		end := index + blockSize
+		if end > len(fields) {
+			end = len(fields)
 		}
 
-		if blockSize >= len(fields[index:]) {
-			args := append([]interface{}{key}, fields[index:]...)
-			return h.String(MASTER, HMSET, args...)
+		args := append([]interface{}{key}, fields[index:end]...)
+		replies = append(replies, pipe.Send(HMSET, args...))
+	}
+
+	if err := pipe.Flush(); err != nil {
+		return constant.EmptyStr, err
+	}
+
+	for _, reply := range replies {
+		if _, err := reply.String(); err != nil {
+			return constant.EmptyStr, err
 		}
+	}
+
+	return OK, nil
+}
 
-		args := append([]interface{}{key}, fields[index:index+blockSize]...)
-		_, err := h.String(MASTER, HMSET, args...)
+// GetAlls hgetall of keys in one round trip, keys not exist are omitted
+func (h *Hash) GetAlls(keySuffixes []string) (map[string]map[string]string, error) {
+	pipe := h.Pipeline(SLAVE)
+	replies := make([]*PipelineReply, len(keySuffixes))
+	for i := range keySuffixes {
+		replies[i] = pipe.Send(HGETALL, h.InitKey(keySuffixes[i]))
+	}
+
+	if err := pipe.Flush(); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]map[string]string, len(keySuffixes))
+	for i, reply := range replies {
+		values, err := reply.StringMap()
 		if err != nil {
-			return constant.EmptyStr, err
+			return nil, err
 		}
 
-		index += blockSize
+		if len(values) > 0 {
+			result[keySuffixes[i]] = values
+		}
 	}
+
+	return result, nil
 }
 
 // GetAllSafe hash get all by safe, hash scan
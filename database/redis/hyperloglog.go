@@ -0,0 +1,49 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// HyperLogLog redis hyperloglog, cardinality estimate with 0.81% standard error in 12KB per key
+type HyperLogLog struct {
+	Structure
+}
+
+const (
+	// PFADD pfadd
+	PFADD = "PFADD"
+	// PFCOUNT pfcount
+	PFCOUNT = "PFCOUNT"
+	// PFMERGE pfmerge
+	PFMERGE = "PFMERGE"
+)
+
+// NewHyperLogLog new hyperloglog
+func NewHyperLogLog(instanceName, keyPrefixFmt string) HyperLogLog {
+	return HyperLogLog{
+		Structure: NewStructure(instanceName, keyPrefixFmt),
+	}
+}
+
+// WithContext copy of hyperloglog, commands honor deadline and cancellation of ctx
+func (h *HyperLogLog) WithContext(ctx context.Context) *HyperLogLog {
+	return &HyperLogLog{Structure: h.Structure.WithContext(ctx)}
+}
+
+// PFAdd pfadd, true if the estimate changed
+func (h *HyperLogLog) PFAdd(keySuffix string, elements ...interface{}) (bool, error) {
+	return h.Bool(MASTER, PFADD, redis.Args{}.Add(h.InitKey(keySuffix)).Add(elements...)...)
+}
+
+// PFCount estimate of the union of keys, keys must be in one slot in cluster mode
+func (h *HyperLogLog) PFCount(keySuffix ...string) (int64, error) {
+	return h.Int64(SLAVE, PFCOUNT, h.initKeys(keySuffix)...)
+}
+
+// PFMerge merge keys into dest, keys must be in one slot in cluster mode
+func (h *HyperLogLog) PFMerge(destKeySuffix string, keySuffix ...string) error {
+	_, err := h.Do(MASTER, PFMERGE, append([]interface{}{h.InitKey(destKeySuffix)}, h.initKeys(keySuffix)...)...)
+	return err
+}
@@ -0,0 +1,41 @@
+package redis
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	Convey("hyperloglog test", t, func() {
+		fake := newFakeRedis(t)
+		defer fake.close()
+		fake.load(t, "HyperLogLogTest")
+
+		h := NewHyperLogLog("HyperLogLogTest", "uv:%s")
+
+		changed, err := h.PFAdd("day1", "u1", "u2", "u3")
+		So(err, ShouldBeNil)
+		So(changed, ShouldBeTrue)
+
+		changed, err = h.PFAdd("day1", "u1")
+		So(err, ShouldBeNil)
+		So(changed, ShouldBeFalse)
+
+		_, err = h.PFAdd("day2", "u3", "u4")
+		So(err, ShouldBeNil)
+
+		n, err := h.PFCount("day1")
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 3)
+
+		n, err = h.PFCount("day1", "day2")
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 4)
+
+		So(h.PFMerge("week", "day1", "day2"), ShouldBeNil)
+		n, err = h.PFCount("week")
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 4)
+	})
+}
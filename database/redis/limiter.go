@@ -0,0 +1,244 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// count of the current window, the key expires with the window
+	_fixedWindowLua = `local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local pttl = redis.call('PTTL', KEYS[1])
+local ttl = pttl
+if ttl < 0 then
+    ttl = period
+end
+if current + cost > limit then
+    local retry = ttl
+    if cost > limit then
+        retry = -1
+    end
+    return {0, math.max(limit - current, 0), retry, ttl}
+end
+current = redis.call('INCRBY', KEYS[1], cost)
+if pttl < 0 then
+    redis.call('PEXPIRE', KEYS[1], period)
+end
+return {1, limit - current, 0, ttl}`
+
+	// sorted set of request times in the last period, scored by server time in ms
+	_slidingLogLua = `redis.replicate_commands()
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - period)
+local count = redis.call('ZCARD', KEYS[1])
+if count + cost > limit then
+    local reset = 0
+    if count > 0 then
+        local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+        reset = tonumber(newest[2]) + period - now
+    end
+    if cost > limit then
+        return {0, math.max(limit - count, 0), -1, reset}
+    end
+    local i = count + cost - limit - 1
+    local oldest = redis.call('ZRANGE', KEYS[1], i, i, 'WITHSCORES')
+    return {0, math.max(limit - count, 0), tonumber(oldest[2]) + period - now, reset}
+end
+for i = 1, cost do
+    redis.call('ZADD', KEYS[1], now, ARGV[4] .. ':' .. i)
+end
+redis.call('PEXPIRE', KEYS[1], period)
+return {1, limit - count - cost, 0, period}`
+
+	// theoretical arrival time of the next request, by server time in ms
+	_gcraLua = `redis.replicate_commands()
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local burst = tonumber(ARGV[4])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + tonumber(t[2]) / 1000
+local interval = period / limit
+local tat = math.max(tonumber(redis.call('GET', KEYS[1]) or now), now)
+local newTat = tat + interval * cost
+local allowAt = newTat - interval * burst
+if allowAt > now then
+    local retry = -1
+    if cost <= burst then
+        retry = math.ceil(allowAt - now)
+    end
+    local remaining = math.floor((now - tat + interval * burst) / interval)
+    return {0, math.max(remaining, 0), retry, math.ceil(tat - now)}
+end
+redis.call('SET', KEYS[1], string.format('%.3f', newTat), 'PX', math.ceil(newTat - now))
+return {1, math.floor((now - allowAt) / interval), 0, math.ceil(newTat - now)}`
+)
+
+// LimiterAlgorithm algorithm of limiter
+type LimiterAlgorithm int
+
+const (
+	// LimitFixedWindow count per period, cheap, allows 2x limit across a window edge
+	LimitFixedWindow LimiterAlgorithm = iota
+	// LimitSlidingLog exact count of the last period, memory per request
+	LimitSlidingLog
+	// LimitGCRA smooth rate of limit per period with burst, one key per limit
+	LimitGCRA
+)
+
+var (
+	// ErrLimiterCostExceeded cost greater than limit or burst is never allowed
+	ErrLimiterCostExceeded = errors.New("redis: limiter cost exceeds limit")
+)
+
+// LimitResult result of a limit
+type LimitResult struct {
+	Allowed   bool
+	Remaining int64
+	// RetryAfter wait before allowed, -1 never allowed
+	RetryAfter time.Duration
+	// ResetAfter wait before the limit is full again
+	ResetAfter time.Duration
+}
+
+// Limiter distributed rate limiter of limit per period, shared by every replica
+// the lua scripts run on master through GetScript
+type Limiter struct {
+	Structure
+	limit     int64
+	period    time.Duration
+	burst     int64
+	algorithm LimiterAlgorithm
+}
+
+// LimiterOptionFunc limiter option func
+type LimiterOptionFunc func(*Limiter)
+
+// SetLimiterAlgorithm set algorithm, default is LimitGCRA
+func SetLimiterAlgorithm(algorithm LimiterAlgorithm) LimiterOptionFunc {
+	return func(l *Limiter) {
+		l.algorithm = algorithm
+	}
+}
+
+// SetLimiterBurst set burst of gcra, default is limit
+func SetLimiterBurst(burst int64) LimiterOptionFunc {
+	return func(l *Limiter) {
+		if burst > 0 {
+			l.burst = burst
+		}
+	}
+}
+
+// NewLimiter new limiter of limit per period
+func NewLimiter(instanceName, keyPrefixFmt string, limit int64, period time.Duration, opts ...LimiterOptionFunc) *Limiter {
+	l := &Limiter{
+		Structure: NewStructure(instanceName, keyPrefixFmt),
+		limit:     limit,
+		period:    period,
+		burst:     limit,
+		algorithm: LimitGCRA,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Allow allow one request of keySuffix
+func (l *Limiter) Allow(ctx context.Context, keySuffix string) (*LimitResult, error) {
+	return l.AllowN(ctx, keySuffix, 1)
+}
+
+// AllowN allow n requests of keySuffix, nothing is taken if not allowed
+func (l *Limiter) AllowN(ctx context.Context, keySuffix string, n int64) (*LimitResult, error) {
+	connStr := l.getConnstr(MASTER)
+	if connStr == "" {
+		return nil, configNotExistsOrLoad(l.InstanceName, MASTER)
+	}
+
+	period := int64(l.period / time.Millisecond)
+	keysAndArgs := []interface{}{l.InitKey(keySuffix), l.limit, period, n}
+
+	var luaBody string
+	switch l.algorithm {
+	case LimitFixedWindow:
+		luaBody = _fixedWindowLua
+	case LimitSlidingLog:
+		// members of the log must be unique
+		token, err := newToken()
+		if err != nil {
+			return nil, err
+		}
+		luaBody = _slidingLogLua
+		keysAndArgs = append(keysAndArgs, token)
+	default:
+		luaBody = _gcraLua
+		keysAndArgs = append(keysAndArgs, l.burst)
+	}
+
+	script := GetScript(connStr, luaBody)
+	if script == nil {
+		return nil, configNotExistsOrLoad(l.InstanceName, MASTER)
+	}
+
+	reply, err := redis.Int64s(l.eval(ctx, script, keysAndArgs...))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LimitResult{
+		Allowed:    reply[0] == 1,
+		Remaining:  reply[1],
+		RetryAfter: time.Duration(reply[2]) * time.Millisecond,
+		ResetAfter: time.Duration(reply[3]) * time.Millisecond,
+	}
+	if reply[2] < 0 {
+		result.RetryAfter = -1
+	}
+
+	return result, nil
+}
+
+// Wait block until one request of keySuffix allowed or ctx done
+func (l *Limiter) Wait(ctx context.Context, keySuffix string) error {
+	return l.WaitN(ctx, keySuffix, 1)
+}
+
+// WaitN block until n requests of keySuffix allowed or ctx done
+func (l *Limiter) WaitN(ctx context.Context, keySuffix string, n int64) error {
+	for {
+		result, err := l.AllowN(ctx, keySuffix, n)
+		if err != nil {
+			return err
+		}
+
+		if result.Allowed {
+			return nil
+		}
+
+		if result.RetryAfter < 0 {
+			return ErrLimiterCostExceeded
+		}
+
+		timer := time.NewTimer(result.RetryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/JREAMLU/j-kit/consul"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeLimiterScripts go implementations of the limiter scripts
+func fakeLimiterScripts(f *fakeRedis) {
+	now := func() float64 {
+		return float64(time.Now().UnixNano()) / float64(time.Millisecond)
+	}
+	ints := func(args []string) (limit, period, cost int64) {
+		limit, _ = strconv.ParseInt(args[0], 10, 64)
+		period, _ = strconv.ParseInt(args[1], 10, 64)
+		cost, _ = strconv.ParseInt(args[2], 10, 64)
+		return
+	}
+	reply := func(values ...int64) interface{} {
+		r := make([]interface{}, len(values))
+		for i, v := range values {
+			r[i] = v
+		}
+		return r
+	}
+	max := func(a, b int64) int64 {
+		if a > b {
+			return a
+		}
+		return b
+	}
+
+	f.script(_fixedWindowLua, func(f *fakeRedis, keys, args []string) interface{} {
+		limit, period, cost := ints(args)
+		current, _ := strconv.ParseInt(f.strings[keys[0]], 10, 64)
+		pttl := f.exec([]string{"PTTL", keys[0]}).(int64)
+		ttl := pttl
+		if ttl < 0 {
+			ttl = period
+		}
+
+		if current+cost > limit {
+			retry := ttl
+			if cost > limit {
+				retry = -1
+			}
+			return reply(0, max(limit-current, 0), retry, ttl)
+		}
+
+		current += cost
+		f.strings[keys[0]] = strconv.FormatInt(current, 10)
+		if pttl < 0 {
+			f.exec([]string{"PEXPIRE", keys[0], strconv.FormatInt(period, 10)})
+		}
+		return reply(1, limit-current, 0, ttl)
+	})
+
+	logs := make(map[string][]int64)
+	f.script(_slidingLogLua, func(f *fakeRedis, keys, args []string) interface{} {
+		limit, period, cost := ints(args)
+		now := int64(now())
+
+		var log []int64
+		for _, score := range logs[keys[0]] {
+			if score > now-period {
+				log = append(log, score)
+			}
+		}
+		sort.Slice(log, func(i, j int) bool { return log[i] < log[j] })
+		logs[keys[0]] = log
+
+		count := int64(len(log))
+		if count+cost > limit {
+			var reset int64
+			if count > 0 {
+				reset = log[count-1] + period - now
+			}
+			if cost > limit {
+				return reply(0, max(limit-count, 0), -1, reset)
+			}
+			return reply(0, max(limit-count, 0), log[count+cost-limit-1]+period-now, reset)
+		}
+
+		for i := int64(0); i < cost; i++ {
+			logs[keys[0]] = append(logs[keys[0]], now)
+		}
+		return reply(1, limit-count-cost, 0, period)
+	})
+
+	f.script(_gcraLua, func(f *fakeRedis, keys, args []string) interface{} {
+		limit, period, cost := ints(args)
+		burst, _ := strconv.ParseFloat(args[3], 64)
+		now := now()
+
+		interval := float64(period) / float64(limit)
+		tat := now
+		if value, ok := f.exec([]string{GET, keys[0]}).(string); ok {
+			tat, _ = strconv.ParseFloat(value, 64)
+		}
+		tat = math.Max(tat, now)
+		newTat := tat + interval*float64(cost)
+		allowAt := newTat - interval*burst
+
+		if allowAt > now {
+			retry := int64(-1)
+			if float64(cost) <= burst {
+				retry = int64(math.Ceil(allowAt - now))
+			}
+			remaining := int64(math.Floor((now - tat + interval*burst) / interval))
+			return reply(0, max(remaining, 0), retry, int64(math.Ceil(tat-now)))
+		}
+
+		ttl := int64(math.Ceil(newTat - now))
+		f.exec([]string{SET, keys[0], fmt.Sprintf("%.3f", newTat), "PX", strconv.FormatInt(ttl, 10)})
+		return reply(1, int64(math.Floor((now-allowAt)/interval)), 0, ttl)
+	})
+}
+
+func TestLimiter(t *testing.T) {
+	Convey("limiter test", t, func() {
+		fake := newFakeRedis(t)
+		defer fake.close()
+		fake.load(t, "LimiterTest")
+		fakeLimiterScripts(fake)
+
+		ctx := context.Background()
+
+		Convey("fixed window", func() {
+			l := NewLimiter("LimiterTest", "limit:%s", 3, 200*time.Millisecond, SetLimiterAlgorithm(LimitFixedWindow))
+
+			for i := int64(0); i < 3; i++ {
+				result, err := l.Allow(ctx, "u1")
+				So(err, ShouldBeNil)
+				So(result.Allowed, ShouldBeTrue)
+				So(result.Remaining, ShouldEqual, 2-i)
+			}
+
+			result, err := l.Allow(ctx, "u1")
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeFalse)
+			So(result.RetryAfter, ShouldBeGreaterThan, 0)
+			So(result.RetryAfter, ShouldBeLessThanOrEqualTo, 200*time.Millisecond)
+
+			// other keys are not limited
+			result, err = l.Allow(ctx, "u2")
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeTrue)
+
+			So(l.Wait(ctx, "u1"), ShouldBeNil)
+		})
+
+		Convey("sliding log", func() {
+			l := NewLimiter("LimiterTest", "limit:%s", 2, 100*time.Millisecond, SetLimiterAlgorithm(LimitSlidingLog))
+
+			result, err := l.AllowN(ctx, "u1", 2)
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeTrue)
+			So(result.Remaining, ShouldEqual, 0)
+
+			result, err = l.Allow(ctx, "u1")
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeFalse)
+			So(result.RetryAfter, ShouldBeGreaterThan, 0)
+
+			start := time.Now()
+			So(l.Wait(ctx, "u1"), ShouldBeNil)
+			So(time.Since(start), ShouldBeGreaterThan, 50*time.Millisecond)
+		})
+
+		Convey("gcra", func() {
+			l := NewLimiter("LimiterTest", "limit:%s", 10, time.Second, SetLimiterBurst(2))
+
+			for i := 0; i < 2; i++ {
+				result, err := l.Allow(ctx, "u1")
+				So(err, ShouldBeNil)
+				So(result.Allowed, ShouldBeTrue)
+			}
+
+			result, err := l.Allow(ctx, "u1")
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeFalse)
+			So(result.Remaining, ShouldEqual, 0)
+			So(result.RetryAfter, ShouldBeGreaterThan, 0)
+			So(result.RetryAfter, ShouldBeLessThanOrEqualTo, 100*time.Millisecond)
+
+			So(l.Wait(ctx, "u1"), ShouldBeNil)
+		})
+
+		Convey("shared by replicas", func() {
+			a := NewLimiter("LimiterTest", "limit:%s", 2, time.Minute)
+			b := NewLimiter("LimiterTest", "limit:%s", 2, time.Minute)
+
+			result, err := a.AllowN(ctx, "shared", 2)
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeTrue)
+
+			result, err = b.Allow(ctx, "shared")
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeFalse)
+		})
+
+		Convey("cost exceeds limit", func() {
+			for _, algorithm := range []LimiterAlgorithm{LimitFixedWindow, LimitSlidingLog, LimitGCRA} {
+				l := NewLimiter("LimiterTest", "exceed:%s", 2, time.Minute, SetLimiterAlgorithm(algorithm))
+				key := strconv.Itoa(int(algorithm))
+
+				result, err := l.Allow(ctx, key)
+				So(err, ShouldBeNil)
+				So(result.Allowed, ShouldBeTrue)
+
+				result, err = l.AllowN(ctx, key, 3)
+				So(err, ShouldBeNil)
+				So(result.RetryAfter, ShouldEqual, -1)
+				So(l.WaitN(ctx, key, 3), ShouldEqual, ErrLimiterCostExceeded)
+			}
+		})
+
+		Convey("cost exceeds limit of an empty key", func() {
+			for _, algorithm := range []LimiterAlgorithm{LimitFixedWindow, LimitSlidingLog, LimitGCRA} {
+				l := NewLimiter("LimiterTest", "empty:%s", 2, time.Minute, SetLimiterAlgorithm(algorithm))
+				key := strconv.Itoa(int(algorithm))
+
+				result, err := l.AllowN(ctx, key, 3)
+				So(err, ShouldBeNil)
+				So(result.Allowed, ShouldBeFalse)
+				So(result.Remaining, ShouldEqual, 2)
+				So(result.RetryAfter, ShouldEqual, -1)
+			}
+		})
+
+		Convey("wait canceled", func() {
+			l := NewLimiter("LimiterTest", "limit:%s", 1, time.Minute)
+			So(l.Wait(ctx, "cancel"), ShouldBeNil)
+
+			ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancel()
+			So(l.Wait(ctx, "cancel"), ShouldBeError, context.DeadlineExceeded.Error())
+		})
+
+		Convey("not loaded instance", func() {
+			l := NewLimiter("None", "limit:%s", 1, time.Minute)
+			_, err := l.Allow(ctx, "u1")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+// TestLimiterScripts run the lua scripts on the redis server of JKIT_REDIS_TEST_ADDR, eg. 127.0.0.1:6379
+func TestLimiterScripts(t *testing.T) {
+	addr := os.Getenv("JKIT_REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("JKIT_REDIS_TEST_ADDR not set")
+	}
+
+	Convey("limiter scripts test", t, func() {
+		host, port, err := net.SplitHostPort(addr)
+		So(err, ShouldBeNil)
+		source := consul.NewMemorySource(map[string]string{
+			path.Join(consul.Redis, "LimiterScripts"): fmt.Sprintf("InstanceName = \"LimiterScripts\"\n[[master]]\nDB = \"0\"\nIP = %q\nPort = %q\n[[slave]]\nDB = \"0\"\nIP = %q\nPort = %q",
+				host, port, host, port),
+		})
+		So(LoadSource(source, false, "LimiterScripts"), ShouldBeNil)
+
+		ctx := context.Background()
+		prefix := "jkit:limiter:test:" + strconv.FormatInt(time.Now().UnixNano(), 10) + ":%s"
+
+		for _, algorithm := range []LimiterAlgorithm{LimitFixedWindow, LimitSlidingLog, LimitGCRA} {
+			l := NewLimiter("LimiterScripts", prefix, 2, 200*time.Millisecond, SetLimiterAlgorithm(algorithm))
+			key := strconv.Itoa(int(algorithm))
+
+			// cost over limit on an empty key
+			result, err := l.AllowN(ctx, "empty"+key, 3)
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeFalse)
+			So(result.RetryAfter, ShouldEqual, -1)
+
+			result, err = l.AllowN(ctx, key, 2)
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeTrue)
+			So(result.Remaining, ShouldEqual, 0)
+
+			result, err = l.Allow(ctx, key)
+			So(err, ShouldBeNil)
+			So(result.Allowed, ShouldBeFalse)
+			So(result.RetryAfter, ShouldBeGreaterThan, 0)
+
+			result, err = l.AllowN(ctx, key, 3)
+			So(err, ShouldBeNil)
+			So(result.RetryAfter, ShouldEqual, -1)
+
+			So(l.Wait(ctx, key), ShouldBeNil)
+		}
+	})
+}
@@ -1,5 +1,7 @@
 package redis
 
+import "context"
+
 // List redis list
 type List struct {
 	Structure
@@ -53,6 +55,11 @@ func NewList(instanceName, keyPrefixFmt string) List {
 	}
 }
 
+// WithContext copy of list, commands honor deadline and cancellation of ctx
+func (l *List) WithContext(ctx context.Context) *List {
+	return &List{Structure: l.Structure.WithContext(ctx)}
+}
+
 // BLPop blpop
 func (l *List) BLPop(keySuffix string, timeout int) ([]string, error) {
 	return l.Strings(MASTER, BLPOP, l.InitKey(keySuffix), timeout)
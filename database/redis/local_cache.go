@@ -0,0 +1,216 @@
+package redis
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	_defaultLocalCacheMaxEntries = 10000
+	_defaultLocalCacheMaxBytes   = 64 << 20
+	_defaultLocalCacheTTL        = time.Minute
+)
+
+// LocalCache in-process lru of values with ttl, bounded by entries and bytes
+type LocalCache struct {
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+
+	mutex sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	bytes int64
+	// gen changed by every delete, a load started before it is stale
+	gen   uint64
+	stats LocalCacheStats
+}
+
+// LocalCacheStats stats of local cache
+type LocalCacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Entries   int64
+	Bytes     int64
+}
+
+type localEntry struct {
+	key      string
+	value    interface{}
+	size     int64
+	expireAt time.Time
+}
+
+// LocalCacheOptionFunc local cache option func
+type LocalCacheOptionFunc func(*LocalCache)
+
+// SetLocalCacheMaxEntries set max entries, default is 10000
+func SetLocalCacheMaxEntries(maxEntries int) LocalCacheOptionFunc {
+	return func(c *LocalCache) {
+		if maxEntries > 0 {
+			c.maxEntries = maxEntries
+		}
+	}
+}
+
+// SetLocalCacheMaxBytes set max bytes of keys and values, default is 64MB
+func SetLocalCacheMaxBytes(maxBytes int64) LocalCacheOptionFunc {
+	return func(c *LocalCache) {
+		if maxBytes > 0 {
+			c.maxBytes = maxBytes
+		}
+	}
+}
+
+// SetLocalCacheTTL set ttl of entries, bounds staleness when invalidations are lost, default is 1m
+func SetLocalCacheTTL(ttl time.Duration) LocalCacheOptionFunc {
+	return func(c *LocalCache) {
+		if ttl > 0 {
+			c.ttl = ttl
+		}
+	}
+}
+
+// NewLocalCache new local cache
+func NewLocalCache(opts ...LocalCacheOptionFunc) *LocalCache {
+	c := &LocalCache{
+		maxEntries: _defaultLocalCacheMaxEntries,
+		maxBytes:   _defaultLocalCacheMaxBytes,
+		ttl:        _defaultLocalCacheTTL,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Get value of key
+func (c *LocalCache) Get(key string) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.items[key]
+	if ok && time.Now().After(elem.Value.(*localEntry).expireAt) {
+		c.remove(elem)
+		ok = false
+	}
+
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	c.ll.MoveToFront(elem)
+	return elem.Value.(*localEntry).value, true
+}
+
+// Gen generation of deletes, pass it to SetIfGen after loading
+func (c *LocalCache) Gen() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.gen
+}
+
+// Set set value of key, size is the bytes of key and value
+func (c *LocalCache) Set(key string, value interface{}, size int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.set(key, value, size)
+}
+
+// SetIfGen set value loaded at gen, false if deleted since gen
+func (c *LocalCache) SetIfGen(key string, value interface{}, size int64, gen uint64) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.gen != gen {
+		return false
+	}
+
+	c.set(key, value, size)
+	return true
+}
+
+// Delete delete keys
+func (c *LocalCache) Delete(keys ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.gen++
+	for _, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.remove(elem)
+		}
+	}
+}
+
+// Purge delete all
+func (c *LocalCache) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.gen++
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.bytes = 0
+}
+
+// Stats stats of local cache
+func (c *LocalCache) Stats() LocalCacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := c.stats
+	stats.Entries = int64(c.ll.Len())
+	stats.Bytes = c.bytes
+	return stats
+}
+
+// HitRate hits / (hits + misses)
+func (stats LocalCacheStats) HitRate() float64 {
+	if stats.Hits+stats.Misses == 0 {
+		return 0
+	}
+
+	return float64(stats.Hits) / float64(stats.Hits+stats.Misses)
+}
+
+func (c *LocalCache) set(key string, value interface{}, size int64) {
+	// never cached, it would evict everything
+	if size > c.maxBytes {
+		if elem, ok := c.items[key]; ok {
+			c.remove(elem)
+		}
+		return
+	}
+
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*localEntry)
+		c.bytes += size - entry.size
+		entry.value, entry.size, entry.expireAt = value, size, time.Now().Add(c.ttl)
+		c.ll.MoveToFront(elem)
+	} else {
+		c.items[key] = c.ll.PushFront(&localEntry{key: key, value: value, size: size, expireAt: time.Now().Add(c.ttl)})
+		c.bytes += size
+	}
+
+	for c.ll.Len() > c.maxEntries || c.bytes > c.maxBytes {
+		c.remove(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *LocalCache) remove(elem *list.Element) {
+	entry := c.ll.Remove(elem).(*localEntry)
+	delete(c.items, entry.key)
+	c.bytes -= entry.size
+}
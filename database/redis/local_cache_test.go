@@ -0,0 +1,80 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLocalCache(t *testing.T) {
+	Convey("local cache test", t, func() {
+		Convey("lru by entries", func() {
+			c := NewLocalCache(SetLocalCacheMaxEntries(2))
+			c.Set("a", "1", 2)
+			c.Set("b", "2", 2)
+
+			_, ok := c.Get("a")
+			So(ok, ShouldBeTrue)
+
+			c.Set("c", "3", 2)
+			_, ok = c.Get("b")
+			So(ok, ShouldBeFalse)
+
+			value, ok := c.Get("a")
+			So(ok, ShouldBeTrue)
+			So(value, ShouldEqual, "1")
+
+			stats := c.Stats()
+			So(stats.Entries, ShouldEqual, 2)
+			So(stats.Bytes, ShouldEqual, 4)
+			So(stats.Evictions, ShouldEqual, 1)
+			So(stats.Hits, ShouldEqual, 2)
+			So(stats.Misses, ShouldEqual, 1)
+			So(stats.HitRate(), ShouldAlmostEqual, 2.0/3)
+		})
+
+		Convey("lru by bytes", func() {
+			c := NewLocalCache(SetLocalCacheMaxBytes(10))
+			c.Set("a", "1", 4)
+			c.Set("b", "2", 4)
+			c.Set("b", "2", 6)
+			So(c.Stats().Bytes, ShouldEqual, 10)
+
+			c.Set("c", "3", 4)
+			_, ok := c.Get("a")
+			So(ok, ShouldBeFalse)
+			So(c.Stats().Entries, ShouldEqual, 2)
+			So(c.Stats().Bytes, ShouldEqual, 10)
+
+			// larger than max bytes is never cached
+			c.Set("d", "4", 11)
+			_, ok = c.Get("d")
+			So(ok, ShouldBeFalse)
+			So(c.Stats().Entries, ShouldEqual, 2)
+		})
+
+		Convey("ttl", func() {
+			c := NewLocalCache(SetLocalCacheTTL(20 * time.Millisecond))
+			c.Set("a", "1", 2)
+			time.Sleep(40 * time.Millisecond)
+
+			_, ok := c.Get("a")
+			So(ok, ShouldBeFalse)
+			So(c.Stats().Entries, ShouldEqual, 0)
+		})
+
+		Convey("delete and gen", func() {
+			c := NewLocalCache()
+			gen := c.Gen()
+			c.Delete("a")
+			So(c.SetIfGen("a", "stale", 6, gen), ShouldBeFalse)
+			So(c.SetIfGen("a", "1", 2, c.Gen()), ShouldBeTrue)
+
+			c.Purge()
+			_, ok := c.Get("a")
+			So(ok, ShouldBeFalse)
+			So(c.Stats().Bytes, ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,399 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	_defaultMutexExpiry     = 8 * time.Second
+	_defaultMutexRetryDelay = 50 * time.Millisecond
+	_defaultMutexMaxDelay   = time.Second
+	// clock drift factor of redlock
+	_mutexDriftFactor = 0.01
+
+	// unlock only when the key holds the token, -1 expired, 0 owned by others
+	_unlockLua = `local v = redis.call('GET', KEYS[1])
+if v == false then
+    return -1
+end
+if v == ARGV[1] then
+    return redis.call('DEL', KEYS[1])
+end
+return 0`
+
+	// extend only when the key holds the token
+	_extendLua = `local v = redis.call('GET', KEYS[1])
+if v == false then
+    return -1
+end
+if v == ARGV[1] then
+    return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0`
+)
+
+var (
+	// ErrMutexHeld lock a mutex already locked by this mutex, it is not reentrant
+	ErrMutexHeld = errors.New("redis: mutex already held")
+	// ErrMutexNotHeld unlock or extend a mutex not locked by this mutex
+	ErrMutexNotHeld = errors.New("redis: mutex not held")
+	// ErrMutexExpired the lease expired before unlock or extend
+	ErrMutexExpired = errors.New("redis: mutex expired")
+	// ErrMutexOwnedByOthers the lease expired and the key is locked by others
+	ErrMutexOwnedByOthers = errors.New("redis: mutex owned by others")
+
+	unlockScript = redis.NewScript(1, _unlockLua)
+	extendScript = redis.NewScript(1, _extendLua)
+)
+
+// Mutex distributed lock of a key, every lock owned by a random token
+// unlock and extend only when the key still holds the token
+// with several instances it is a redlock, locked when the majority is acquired
+type Mutex struct {
+	instances  []*String
+	key        string
+	expiry     time.Duration
+	retryDelay time.Duration
+	maxDelay   time.Duration
+	watchdog   bool
+
+	mutex sync.Mutex
+	token string
+	done  chan struct{}
+	stop  chan struct{}
+}
+
+// MutexOptionFunc mutex option func
+type MutexOptionFunc func(*Mutex)
+
+// SetMutexExpiry set lease of lock, default is 8s
+func SetMutexExpiry(expiry time.Duration) MutexOptionFunc {
+	return func(m *Mutex) {
+		if expiry > 0 {
+			m.expiry = expiry
+		}
+	}
+}
+
+// SetMutexRetryDelay set first and max delay between acquire retries, the delay doubles with jitter
+func SetMutexRetryDelay(delay, maxDelay time.Duration) MutexOptionFunc {
+	return func(m *Mutex) {
+		if delay > 0 {
+			m.retryDelay = delay
+		}
+
+		if maxDelay >= m.retryDelay {
+			m.maxDelay = maxDelay
+		}
+	}
+}
+
+// SetMutexWatchdog extend the lease every expiry/3 until unlock
+func SetMutexWatchdog(watchdog bool) MutexOptionFunc {
+	return func(m *Mutex) {
+		m.watchdog = watchdog
+	}
+}
+
+// NewMutex mutex of key keySuffix
+func (s *String) NewMutex(keySuffix string, opts ...MutexOptionFunc) *Mutex {
+	return newMutex([]*String{s}, s.InitKey(keySuffix), opts...)
+}
+
+// NewRedlock mutex of key keySuffix on several independent instances
+// the key prefix of the first instance is used
+func NewRedlock(instances []*String, keySuffix string, opts ...MutexOptionFunc) *Mutex {
+	return newMutex(instances, instances[0].InitKey(keySuffix), opts...)
+}
+
+func newMutex(instances []*String, key string, opts ...MutexOptionFunc) *Mutex {
+	m := &Mutex{
+		instances:  instances,
+		key:        key,
+		expiry:     _defaultMutexExpiry,
+		retryDelay: _defaultMutexRetryDelay,
+		maxDelay:   _defaultMutexMaxDelay,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Key key of mutex
+func (m *Mutex) Key() string {
+	return m.key
+}
+
+// Token token of the current lock, empty if not locked
+func (m *Mutex) Token() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.token
+}
+
+// Done closed when the lock is unlocked or lost by the watchdog, nil if not locked
+func (m *Mutex) Done() <-chan struct{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.done
+}
+
+// Lock acquire, retry with backoff until locked or ctx done
+func (m *Mutex) Lock(ctx context.Context) error {
+	delay := m.retryDelay
+	for {
+		ok, err := m.TryLock(ctx)
+		if err != nil || ok {
+			return err
+		}
+
+		// jitter avoids waiters retrying at the same time
+		wait := delay/2 + time.Duration(mrand.Int63n(int64(delay)))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		delay = time.Duration(math.Min(float64(delay*2), float64(m.maxDelay)))
+	}
+}
+
+// TryLock acquire once, false when locked by others
+// a mutex is not reentrant, ErrMutexHeld until Unlock or the watchdog lost the lease
+func (m *Mutex) TryLock(ctx context.Context) (bool, error) {
+	if m.Token() != "" {
+		return false, ErrMutexHeld
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return false, err
+	}
+
+	start := time.Now()
+	var lastErr error
+	acquired := m.each(func(s *String) (bool, error) {
+		reply, err := s.DoContext(ctx, MASTER, SET, m.key, token, PX, int64(m.expiry/time.Millisecond), NX)
+		if err == nil && reply == nil {
+			return false, nil
+		}
+
+		return err == nil, err
+	}, &lastErr)
+
+	// the lease left after acquiring must be positive
+	drift := time.Duration(float64(m.expiry)*_mutexDriftFactor) + 2*time.Millisecond
+	if acquired >= m.quorum() && time.Since(start) < m.expiry-drift {
+		if m.locked(token) {
+			return true, nil
+		}
+
+		// locked concurrently by another call of this mutex
+		m.release(token)
+		return false, ErrMutexHeld
+	}
+
+	// release the partial redlock
+	m.release(token)
+
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	if acquired == 0 && lastErr != nil && len(m.instances) == 1 {
+		return false, lastErr
+	}
+
+	return false, nil
+}
+
+// Unlock release the lock, ErrMutexExpired or ErrMutexOwnedByOthers when the lease is lost
+func (m *Mutex) Unlock(ctx context.Context) error {
+	token := m.unlocked()
+	if token == "" {
+		return ErrMutexNotHeld
+	}
+
+	return m.script(ctx, unlockScript, token)
+}
+
+// Extend reset the lease to expiry, ErrMutexExpired or ErrMutexOwnedByOthers when the lease is lost
+func (m *Mutex) Extend(ctx context.Context) error {
+	token := m.Token()
+	if token == "" {
+		return ErrMutexNotHeld
+	}
+
+	return m.script(ctx, extendScript, token, int64(m.expiry/time.Millisecond))
+}
+
+// script run compare script of token on every instance, succeeds on the majority
+func (m *Mutex) script(ctx context.Context, script *redis.Script, token string, args ...interface{}) error {
+	var lastErr error
+	ok := m.each(func(s *String) (bool, error) {
+		n, err := redis.Int(s.eval(ctx, script, append([]interface{}{m.key, token}, args...)...))
+		if err != nil {
+			return false, err
+		}
+
+		switch n {
+		case -1:
+			return false, ErrMutexExpired
+		case 0:
+			return false, ErrMutexOwnedByOthers
+		}
+
+		return true, nil
+	}, &lastErr)
+
+	if ok >= m.quorum() {
+		return nil
+	}
+
+	return lastErr
+}
+
+// each call fn on every instance concurrently, returns the number of true
+func (m *Mutex) each(fn func(s *String) (bool, error), lastErr *error) int {
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+		n     int
+	)
+
+	for _, s := range m.instances {
+		wg.Add(1)
+		go func(s *String) {
+			defer wg.Done()
+			ok, err := fn(s)
+
+			mutex.Lock()
+			if ok {
+				n++
+			} else if err != nil {
+				*lastErr = err
+			}
+			mutex.Unlock()
+		}(s)
+	}
+	wg.Wait()
+
+	return n
+}
+
+func (m *Mutex) quorum() int {
+	return len(m.instances)/2 + 1
+}
+
+func (m *Mutex) release(token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.expiry)
+	defer cancel()
+
+	var lastErr error
+	m.each(func(s *String) (bool, error) {
+		_, err := s.eval(ctx, unlockScript, m.key, token)
+		return err == nil, err
+	}, &lastErr)
+}
+
+// locked store the token and start the watchdog, false when already held
+func (m *Mutex) locked(token string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.token != "" {
+		return false
+	}
+
+	m.token = token
+	m.done = make(chan struct{})
+	m.stop = make(chan struct{})
+
+	if m.watchdog {
+		go m.watch(token, m.done, m.stop)
+	}
+
+	return true
+}
+
+// unlocked clear the token and stop the watchdog, returns the token
+func (m *Mutex) unlocked() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	token := m.token
+	if token != "" {
+		close(m.stop)
+		if !m.watchdog {
+			close(m.done)
+		}
+		m.token = ""
+	}
+
+	return token
+}
+
+// watch extend the lease every expiry/3, close done when unlocked or lost
+func (m *Mutex) watch(token string, done, stop chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(m.expiry / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), m.expiry/3)
+			err := m.script(ctx, extendScript, token, int64(m.expiry/time.Millisecond))
+			cancel()
+
+			if err == ErrMutexExpired || err == ErrMutexOwnedByOthers {
+				m.mutex.Lock()
+				if m.token == token {
+					m.token = ""
+				}
+				m.mutex.Unlock()
+				return
+			}
+		}
+	}
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// eval run lua script on master with the context
+func (s *Structure) eval(ctx context.Context, script *redis.Script, keysAndArgs ...interface{}) (interface{}, error) {
+	conn, err := s.getConn(ctx, MASTER)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return scriptDo(ctx, script, conn, keysAndArgs...)
+}
@@ -0,0 +1,201 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// compare scripts of mutex, run by the fake redis with its lock held
+func fakeMutexScripts(f *fakeRedis) {
+	compare := func(fn func(f *fakeRedis, keys, args []string) interface{}) func(f *fakeRedis, keys, args []string) interface{} {
+		return func(f *fakeRedis, keys, args []string) interface{} {
+			value, ok := f.exec([]string{GET, keys[0]}).(string)
+			if !ok {
+				return int64(-1)
+			}
+
+			if value != args[0] {
+				return int64(0)
+			}
+
+			return fn(f, keys, args)
+		}
+	}
+
+	f.script(_unlockLua, compare(func(f *fakeRedis, keys, args []string) interface{} {
+		return f.exec([]string{DEL, keys[0]})
+	}))
+	f.script(_extendLua, compare(func(f *fakeRedis, keys, args []string) interface{} {
+		return f.exec([]string{"PEXPIRE", keys[0], args[1]})
+	}))
+}
+
+func TestMutex(t *testing.T) {
+	Convey("mutex test", t, func() {
+		fake := newFakeRedis(t)
+		defer fake.close()
+		fake.load(t, "MutexTest")
+		fakeMutexScripts(fake)
+
+		s := NewString("MutexTest", "lock:%s")
+		ctx := context.Background()
+
+		Convey("try lock and unlock", func() {
+			m1 := s.NewMutex("job")
+			m2 := s.NewMutex("job")
+			So(m1.Key(), ShouldEqual, "lock:job")
+
+			ok, err := m1.TryLock(ctx)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(m1.Token(), ShouldNotBeEmpty)
+
+			// not reentrant, the held token is kept
+			token := m1.Token()
+			ok, err = m1.TryLock(ctx)
+			So(err, ShouldEqual, ErrMutexHeld)
+			So(ok, ShouldBeFalse)
+			So(m1.Lock(ctx), ShouldEqual, ErrMutexHeld)
+			So(m1.Token(), ShouldEqual, token)
+
+			ok, err = m2.TryLock(ctx)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+			So(m2.Unlock(ctx), ShouldEqual, ErrMutexNotHeld)
+
+			done := m1.Done()
+			So(m1.Unlock(ctx), ShouldBeNil)
+			So(m1.Token(), ShouldBeEmpty)
+			<-done
+
+			ok, err = m2.TryLock(ctx)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(m2.Unlock(ctx), ShouldBeNil)
+		})
+
+		Convey("lease lost", func() {
+			m1 := s.NewMutex("lost", SetMutexExpiry(100*time.Millisecond))
+			m2 := s.NewMutex("lost")
+
+			So(m1.Lock(ctx), ShouldBeNil)
+			time.Sleep(200 * time.Millisecond)
+			So(m1.Extend(ctx), ShouldEqual, ErrMutexExpired)
+			So(m1.Unlock(ctx), ShouldEqual, ErrMutexExpired)
+
+			So(m1.Lock(ctx), ShouldBeNil)
+			time.Sleep(200 * time.Millisecond)
+			So(m2.Lock(ctx), ShouldBeNil)
+
+			// never delete the lock of others
+			So(m1.Unlock(ctx), ShouldEqual, ErrMutexOwnedByOthers)
+			value, err := s.Get("lost")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, m2.Token())
+			So(m2.Unlock(ctx), ShouldBeNil)
+		})
+
+		Convey("blocking lock", func() {
+			m1 := s.NewMutex("block")
+			m2 := s.NewMutex("block", SetMutexRetryDelay(10*time.Millisecond, 50*time.Millisecond))
+			So(m1.Lock(ctx), ShouldBeNil)
+
+			timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+			defer cancel()
+			So(m2.Lock(timeout), ShouldBeError, context.DeadlineExceeded.Error())
+
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				m1.Unlock(ctx)
+			}()
+
+			timeout, cancel = context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+			So(m2.Lock(timeout), ShouldBeNil)
+			So(m2.Unlock(ctx), ShouldBeNil)
+		})
+
+		Convey("watchdog", func() {
+			m1 := s.NewMutex("watch", SetMutexExpiry(150*time.Millisecond), SetMutexWatchdog(true))
+			m2 := s.NewMutex("watch")
+
+			So(m1.Lock(ctx), ShouldBeNil)
+			done := m1.Done()
+			So(m1.Lock(ctx), ShouldEqual, ErrMutexHeld)
+			So(m1.Done(), ShouldEqual, done)
+			time.Sleep(500 * time.Millisecond)
+
+			ok, err := m2.TryLock(ctx)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+
+			So(m1.Unlock(ctx), ShouldBeNil)
+			<-done
+		})
+	})
+}
+
+func TestRedlock(t *testing.T) {
+	Convey("redlock test", t, func() {
+		var instances []*String
+		for i := 0; i < 3; i++ {
+			fake := newFakeRedis(t)
+			defer fake.close()
+
+			instanceName := fmt.Sprintf("RedlockTest%d", i)
+			fake.load(t, instanceName)
+			fakeMutexScripts(fake)
+
+			// the last instance is down
+			if i == 2 {
+				fake.close()
+			}
+
+			str := NewString(instanceName, "lock:%s")
+			instances = append(instances, &str)
+		}
+
+		ctx := context.Background()
+
+		Convey("majority", func() {
+			m1 := NewRedlock(instances, "job")
+			m2 := NewRedlock(instances, "job")
+
+			ok, err := m1.TryLock(ctx)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			ok, err = m2.TryLock(ctx)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+
+			So(m1.Extend(ctx), ShouldBeNil)
+			So(m1.Unlock(ctx), ShouldBeNil)
+
+			ok, err = m2.TryLock(ctx)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(m2.Unlock(ctx), ShouldBeNil)
+		})
+
+		Convey("minority", func() {
+			m := NewRedlock(instances, "minority")
+
+			// held by others on one instance, only one of three left
+			_, err := instances[0].DoContext(ctx, MASTER, SET, m.Key(), "others")
+			So(err, ShouldBeNil)
+
+			ok, err := m.TryLock(ctx)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+
+			// the partial lock is released
+			_, err = instances[1].Get("minority")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,268 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/mna/redisc"
+)
+
+const _defaultPipelineParallel = 8
+
+var (
+	// ErrPipelineNotFlushed reply received before the pipeline flushed
+	ErrPipelineNotFlushed = errors.New("redis: pipeline not flushed")
+	// ErrPipelineFlushed send after the pipeline flushed
+	ErrPipelineFlushed = errors.New("redis: pipeline already flushed")
+)
+
+// Pipeline commands sent in one round trip by Flush
+// in cluster mode the first arg of every command is its key, commands are grouped by slot
+// and the groups are flushed concurrently
+type Pipeline struct {
+	s        *Structure
+	isMaster bool
+	replies  []*PipelineReply
+	flushed  bool
+}
+
+// PipelineReply reply of one pipelined command, available after Flush
+type PipelineReply struct {
+	cmd      string
+	args     []interface{}
+	reply    interface{}
+	err      error
+	received bool
+}
+
+// Pipeline new pipeline of structure, with the context of structure
+func (s *Structure) Pipeline(isMaster bool) *Pipeline {
+	return &Pipeline{
+		s:        s,
+		isMaster: isMaster,
+	}
+}
+
+// Send buffer cmd, the reply is received by Flush
+func (p *Pipeline) Send(cmd string, args ...interface{}) *PipelineReply {
+	reply := &PipelineReply{cmd: cmd, args: args}
+	if p.flushed {
+		reply.err = ErrPipelineFlushed
+		reply.received = true
+		return reply
+	}
+
+	p.replies = append(p.replies, reply)
+	return reply
+}
+
+// Len number of buffered commands
+func (p *Pipeline) Len() int {
+	return len(p.replies)
+}
+
+// Flush send buffered commands and receive all replies
+// error of conn or ctx is returned and set to every reply, command errors are only in replies
+func (p *Pipeline) Flush() error {
+	if p.flushed {
+		return ErrPipelineFlushed
+	}
+	p.flushed = true
+
+	if len(p.replies) == 0 {
+		return nil
+	}
+
+	ctx := p.s.Context()
+	span := startSpan(ctx, p.s.InstanceName, fmt.Sprintf("pipeline(%d)", len(p.replies)))
+
+	var err error
+	if p.s.isCluster() {
+		err = p.flushCluster(ctx)
+	} else {
+		err = p.flushConn(ctx)
+	}
+
+	finishSpan(span, err)
+	return err
+}
+
+func (p *Pipeline) flushConn(ctx context.Context) error {
+	conn, err := p.s.getConn(ctx, p.isMaster)
+	if err != nil {
+		setReplies(p.replies, err)
+		return err
+	}
+	defer conn.Close()
+
+	return flushReplies(ctx, conn, p.replies)
+}
+
+// flushCluster group by slot of key, each group on a conn bound to the node of slot
+func (p *Pipeline) flushCluster(ctx context.Context) error {
+	cluster := p.s.getCluster()
+	if cluster == nil {
+		err := configNotExistsOrLoad(p.s.InstanceName, p.isMaster)
+		setReplies(p.replies, err)
+		return err
+	}
+
+	groups := make(map[int][]*PipelineReply)
+	for _, reply := range p.replies {
+		if len(reply.args) == 0 {
+			reply.err = fmt.Errorf("redis: cluster pipeline %s without key", reply.cmd)
+			reply.received = true
+			continue
+		}
+
+		slot := redisc.Slot(fmt.Sprint(reply.args[0]))
+		groups[slot] = append(groups[slot], reply)
+	}
+
+	slots := make([]int, 0, len(groups))
+	for slot := range groups {
+		slots = append(slots, slot)
+	}
+	sort.Ints(slots)
+
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		firstErr error
+		parallel = make(chan struct{}, _defaultPipelineParallel)
+	)
+
+	for _, slot := range slots {
+		wg.Add(1)
+		parallel <- struct{}{}
+		go func(replies []*PipelineReply) {
+			defer func() {
+				<-parallel
+				wg.Done()
+			}()
+
+			if err := flushSlot(ctx, cluster, replies); err != nil {
+				mutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mutex.Unlock()
+			}
+		}(groups[slot])
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+func flushSlot(ctx context.Context, cluster *redisc.Cluster, replies []*PipelineReply) error {
+	conn := cluster.Get()
+	defer conn.Close()
+
+	if err := redisc.BindConn(conn, fmt.Sprint(replies[0].args[0])); err != nil {
+		setReplies(replies, err)
+		return err
+	}
+
+	return flushReplies(ctx, conn, replies)
+}
+
+// flushReplies send, flush and receive replies on conn
+func flushReplies(ctx context.Context, conn redis.Conn, replies []*PipelineReply) error {
+	if err := ctx.Err(); err != nil {
+		setReplies(replies, err)
+		return err
+	}
+
+	for _, reply := range replies {
+		if err := conn.Send(reply.cmd, reply.args...); err != nil {
+			setReplies(replies, err)
+			return err
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		setReplies(replies, err)
+		return err
+	}
+
+	for i, reply := range replies {
+		reply.reply, reply.err = receiveContext(ctx, conn)
+		reply.received = true
+
+		// broken conn or ctx done, the rest can not be received
+		if _, ok := reply.err.(redis.Error); reply.err != nil && !ok {
+			setReplies(replies[i+1:], reply.err)
+			return reply.err
+		}
+	}
+
+	return nil
+}
+
+func setReplies(replies []*PipelineReply, err error) {
+	for _, reply := range replies {
+		if !reply.received {
+			reply.err = err
+			reply.received = true
+		}
+	}
+}
+
+// Receive raw reply and error of command
+func (r *PipelineReply) Receive() (interface{}, error) {
+	if !r.received {
+		return nil, ErrPipelineNotFlushed
+	}
+
+	return r.reply, r.err
+}
+
+// Bool bool reply
+func (r *PipelineReply) Bool() (bool, error) {
+	return redis.Bool(r.Receive())
+}
+
+// String string reply
+func (r *PipelineReply) String() (string, error) {
+	return redis.String(r.Receive())
+}
+
+// Strings strings reply
+func (r *PipelineReply) Strings() ([]string, error) {
+	return redis.Strings(r.Receive())
+}
+
+// StringMap stringmap reply
+func (r *PipelineReply) StringMap() (map[string]string, error) {
+	return redis.StringMap(r.Receive())
+}
+
+// Int int reply
+func (r *PipelineReply) Int() (int, error) {
+	return redis.Int(r.Receive())
+}
+
+// Int64 int64 reply
+func (r *PipelineReply) Int64() (int64, error) {
+	return redis.Int64(r.Receive())
+}
+
+// Int64s int64s reply
+func (r *PipelineReply) Int64s() ([]int64, error) {
+	return redis.Int64s(r.Receive())
+}
+
+// Float64 float64 reply
+func (r *PipelineReply) Float64() (float64, error) {
+	return redis.Float64(r.Receive())
+}
+
+// Values values reply
+func (r *PipelineReply) Values() ([]interface{}, error) {
+	return redis.Values(r.Receive())
+}
@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"testing"
+
+	redigo "github.com/gomodule/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPipeline(t *testing.T) {
+	Convey("pipeline test", t, func() {
+		fake := newFakeRedis(t)
+		defer fake.close()
+		fake.load(t, "PipelineTest")
+
+		Convey("send, flush and receive", func() {
+			s := NewStructure("PipelineTest", "%s")
+			pipe := s.Pipeline(MASTER)
+			set := pipe.Send(SET, "a", "1")
+			get := pipe.Send(GET, "a")
+			unknown := pipe.Send("NOPE", "a")
+			miss := pipe.Send(GET, "b")
+
+			_, err := get.Receive()
+			So(err, ShouldEqual, ErrPipelineNotFlushed)
+
+			So(pipe.Len(), ShouldEqual, 4)
+			So(pipe.Flush(), ShouldBeNil)
+
+			ok, err := set.String()
+			So(err, ShouldBeNil)
+			So(ok, ShouldEqual, OK)
+
+			value, err := get.Int64()
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 1)
+
+			_, err = unknown.String()
+			So(err, ShouldNotBeNil)
+
+			_, err = miss.String()
+			So(err, ShouldEqual, redigo.ErrNil)
+
+			So(pipe.Flush(), ShouldEqual, ErrPipelineFlushed)
+			_, err = pipe.Send(GET, "a").Receive()
+			So(err, ShouldEqual, ErrPipelineFlushed)
+		})
+
+		Convey("hash batch", func() {
+			h := NewHash("PipelineTest", "h:%s")
+			reply, err := h.MSetSafe("a", 2, "f1", "v1", "f2", "v2", "f3", "v3")
+			So(err, ShouldBeNil)
+			So(reply, ShouldEqual, OK)
+
+			_, err = h.MSetSafe("b", 0, "f1", "v1")
+			So(err, ShouldBeNil)
+
+			all, err := h.GetAlls([]string{"a", "b", "c"})
+			So(err, ShouldBeNil)
+			So(all, ShouldResemble, map[string]map[string]string{
+				"a": {"f1": "v1", "f2": "v2", "f3": "v3"},
+				"b": {"f1": "v1"},
+			})
+		})
+
+		Convey("not loaded", func() {
+			s := NewStructure("None", "%s")
+			pipe := s.Pipeline(SLAVE)
+			get := pipe.Send(GET, "a")
+			So(pipe.Flush(), ShouldNotBeNil)
+			_, err := get.Receive()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -93,6 +93,7 @@ func (p *Pool) register(db string) (rPool *redis.Pool) {
 				return err
 			},
 		}
+		p.pools[db] = rPool
 	}
 	p.rwMutex.Unlock()
 
@@ -134,3 +135,26 @@ func registerPool(addr string, maxIdle int, idleTimeout time.Duration) *Pool {
 
 	return pool
 }
+
+// releasePool close and remove the pool of addr and db, the Pool of addr is removed with its last db
+func releasePool(addr, db string) error {
+	var rPool *redis.Pool
+
+	rwMutex.Lock()
+	if pool, ok := pools[addr]; ok {
+		pool.rwMutex.Lock()
+		rPool = pool.pools[db]
+		delete(pool.pools, db)
+		if len(pool.pools) == 0 {
+			delete(pools, addr)
+		}
+		pool.rwMutex.Unlock()
+	}
+	rwMutex.Unlock()
+
+	if rPool == nil {
+		return nil
+	}
+
+	return rPool.Close()
+}
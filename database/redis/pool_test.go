@@ -1 +1,40 @@
 package redis
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPool(t *testing.T) {
+	Convey("pool test", t, func() {
+		shared, dropped := "127.0.0.1:16379", "127.0.0.1:16380"
+
+		rPool := GetPool(shared, "0", 1, time.Minute)
+		So(GetPool(shared, "0", 1, time.Minute), ShouldEqual, rPool)
+		So(GetPool(shared, "1", 1, time.Minute), ShouldNotEqual, rPool)
+		GetPool(dropped, "0", 1, time.Minute)
+
+		old := &Group{Name: "PoolTest", RedisConns: []Conn{
+			{ConnStr: shared, DB: "0", IsMaster: true},
+			{ConnStr: dropped, DB: "0"},
+		}}
+		settings.Store("PoolTest", old)
+		settings.Store("PoolTest", &Group{Name: "PoolTest", RedisConns: []Conn{
+			{ConnStr: shared, DB: "0", IsMaster: true},
+			{ConnStr: shared, DB: "0"},
+		}})
+		defer settings.Delete("PoolTest")
+
+		// the closer of the replaced group, run after the drain
+		So(releasePools(old), ShouldBeNil)
+		So(getPool(shared, "0"), ShouldEqual, rPool)
+		So(getPool(dropped, "0"), ShouldBeNil)
+
+		rwMutex.RLock()
+		_, ok := pools[dropped]
+		rwMutex.RUnlock()
+		So(ok, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,388 @@
+package redis
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	_defaultPubSubPing      = 30 * time.Second
+	_defaultPubSubReconnect = time.Second
+	_defaultPubSubBuffer    = 100
+
+	// PUBLISH publish
+	PUBLISH = "PUBLISH"
+)
+
+// ErrPubSubClosed subscribe on a closed PubSub
+var ErrPubSubClosed = errors.New("redis: pubsub closed")
+
+// Message message of subscribed channel, Pattern is set when matched by a pattern
+type Message struct {
+	Channel string
+	Pattern string
+	Data    []byte
+}
+
+// PubSub subscriber of channels and patterns on its own conn of the instance
+// the conn is pinged for liveness, dialed again after lost and every subscription is restored
+// messages published on master are replicated, subscribing on slave keeps master free
+type PubSub struct {
+	s         *Structure
+	isMaster  bool
+	ping      time.Duration
+	reconnect time.Duration
+	handler   func(msg Message)
+	connected func()
+	messages  chan Message
+
+	mutex    sync.Mutex
+	channels map[string]bool
+	patterns map[string]bool
+	conn     *redis.PubSubConn
+	changed  chan struct{}
+	closed   chan struct{}
+	done     chan struct{}
+}
+
+// PubSubOptionFunc pubsub option func
+type PubSubOptionFunc func(*PubSub)
+
+// SetPubSubHandler handle messages by fn in the receiving goroutine instead of Messages
+func SetPubSubHandler(fn func(msg Message)) PubSubOptionFunc {
+	return func(ps *PubSub) {
+		ps.handler = fn
+	}
+}
+
+// SetPubSubConnected call fn after every dial restored subscriptions
+// messages published while the conn was lost are missed, eg. purge a local cache
+func SetPubSubConnected(fn func()) PubSubOptionFunc {
+	return func(ps *PubSub) {
+		ps.connected = fn
+	}
+}
+
+// SetPubSubPing set ping interval, the conn is dialed again when no reply in interval plus ReadTimeout, default is 30s
+func SetPubSubPing(interval time.Duration) PubSubOptionFunc {
+	return func(ps *PubSub) {
+		if interval > 0 {
+			ps.ping = interval
+		}
+	}
+}
+
+// SetPubSubReconnect set delay between dials after the conn is lost, default is 1s
+func SetPubSubReconnect(delay time.Duration) PubSubOptionFunc {
+	return func(ps *PubSub) {
+		if delay > 0 {
+			ps.reconnect = delay
+		}
+	}
+}
+
+// SetPubSubBuffer set buffer of Messages, default is 100
+func SetPubSubBuffer(size int) PubSubOptionFunc {
+	return func(ps *PubSub) {
+		if size >= 0 {
+			ps.messages = make(chan Message, size)
+		}
+	}
+}
+
+// Publish publish message to channel on master, returns the number of receivers
+func (s *Structure) Publish(channel string, message interface{}) (int64, error) {
+	return s.Int64(MASTER, PUBLISH, channel, message)
+}
+
+// PubSub new subscriber on master or slave, Close it when done
+func (s *Structure) PubSub(isMaster bool, opts ...PubSubOptionFunc) *PubSub {
+	ps := &PubSub{
+		s:         s,
+		isMaster:  isMaster,
+		ping:      _defaultPubSubPing,
+		reconnect: _defaultPubSubReconnect,
+		messages:  make(chan Message, _defaultPubSubBuffer),
+		channels:  make(map[string]bool),
+		patterns:  make(map[string]bool),
+		changed:   make(chan struct{}, 1),
+		closed:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(ps)
+	}
+
+	go ps.run()
+
+	return ps
+}
+
+// Messages messages of subscriptions, closed after Close, unused with SetPubSubHandler
+// a slow reader blocks receiving of all subscriptions
+func (ps *PubSub) Messages() <-chan Message {
+	return ps.messages
+}
+
+// Subscribe subscribe channels
+func (ps *PubSub) Subscribe(channels ...string) error {
+	return ps.update(ps.channels, true, func(conn *redis.PubSubConn, args ...interface{}) error {
+		return conn.Subscribe(args...)
+	}, channels)
+}
+
+// PSubscribe subscribe patterns
+func (ps *PubSub) PSubscribe(patterns ...string) error {
+	return ps.update(ps.patterns, true, func(conn *redis.PubSubConn, args ...interface{}) error {
+		return conn.PSubscribe(args...)
+	}, patterns)
+}
+
+// Unsubscribe unsubscribe channels
+func (ps *PubSub) Unsubscribe(channels ...string) error {
+	return ps.update(ps.channels, false, func(conn *redis.PubSubConn, args ...interface{}) error {
+		return conn.Unsubscribe(args...)
+	}, channels)
+}
+
+// PUnsubscribe unsubscribe patterns
+func (ps *PubSub) PUnsubscribe(patterns ...string) error {
+	return ps.update(ps.patterns, false, func(conn *redis.PubSubConn, args ...interface{}) error {
+		return conn.PUnsubscribe(args...)
+	}, patterns)
+}
+
+// Close close the conn and stop resubscribing, Messages is closed
+func (ps *PubSub) Close() error {
+	ps.mutex.Lock()
+	select {
+	case <-ps.closed:
+		ps.mutex.Unlock()
+		return nil
+	default:
+	}
+
+	close(ps.closed)
+	if ps.conn != nil {
+		ps.conn.Close()
+	}
+	ps.mutex.Unlock()
+
+	<-ps.done
+	return nil
+}
+
+// update record subscriptions, send them when connected, a lost conn restores them after dialed again
+func (ps *PubSub) update(names map[string]bool, subscribe bool, send func(conn *redis.PubSubConn, args ...interface{}) error, keys []string) error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	select {
+	case <-ps.closed:
+		return ErrPubSubClosed
+	default:
+	}
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if subscribe {
+			names[key] = true
+		} else {
+			delete(names, key)
+		}
+		args[i] = key
+	}
+
+	select {
+	case ps.changed <- struct{}{}:
+	default:
+	}
+
+	if ps.conn == nil || len(args) == 0 {
+		return nil
+	}
+
+	if err := send(ps.conn, args...); err != nil {
+		// receiving fails on the broken conn too, it is dialed again
+		log.Printf("Failed on redis pubsub send, instance: %v, err: %v \r\n", ps.s.InstanceName, err)
+	}
+
+	return nil
+}
+
+// run keep a session while subscribed until closed
+func (ps *PubSub) run() {
+	defer close(ps.done)
+	defer close(ps.messages)
+
+	for {
+		if !ps.wait() {
+			return
+		}
+
+		if err := ps.session(); err != nil {
+			log.Printf("Failed on redis pubsub, instance: %v, err: %v \r\n", ps.s.InstanceName, err)
+
+			select {
+			case <-ps.closed:
+				return
+			case <-time.After(ps.reconnect):
+			}
+		}
+	}
+}
+
+// wait wait for any subscription, false when closed
+func (ps *PubSub) wait() bool {
+	for {
+		ps.mutex.Lock()
+		n := len(ps.channels) + len(ps.patterns)
+		ps.mutex.Unlock()
+
+		select {
+		case <-ps.closed:
+			return false
+		default:
+		}
+
+		if n > 0 {
+			return true
+		}
+
+		select {
+		case <-ps.closed:
+			return false
+		case <-ps.changed:
+		}
+	}
+}
+
+// session dial, restore subscriptions and receive until lost or no subscription
+func (ps *PubSub) session() error {
+	conn, err := ps.dial()
+	if err != nil {
+		return err
+	}
+
+	psc := &redis.PubSubConn{Conn: conn}
+	defer psc.Close()
+
+	ps.mutex.Lock()
+	select {
+	case <-ps.closed:
+		ps.mutex.Unlock()
+		return nil
+	default:
+	}
+
+	if err = restore(psc.Subscribe, ps.channels); err == nil {
+		err = restore(psc.PSubscribe, ps.patterns)
+	}
+	if err != nil {
+		ps.mutex.Unlock()
+		return err
+	}
+	ps.conn = psc
+	ps.mutex.Unlock()
+
+	defer func() {
+		ps.mutex.Lock()
+		ps.conn = nil
+		ps.mutex.Unlock()
+	}()
+
+	if ps.connected != nil {
+		ps.connected()
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go ps.pinger(psc, stop)
+
+	for {
+		switch v := psc.ReceiveWithTimeout(ps.ping + ReadTimeout).(type) {
+		case redis.Message:
+			ps.deliver(Message{Channel: v.Channel, Pattern: v.Pattern, Data: v.Data})
+		case redis.Subscription:
+			// out of subscribed mode, wait for the next subscription
+			if v.Count == 0 {
+				return nil
+			}
+		case error:
+			select {
+			case <-ps.closed:
+				return nil
+			default:
+			}
+
+			return v
+		}
+	}
+}
+
+// pinger ping every interval, the reply keeps ReceiveWithTimeout alive
+func (ps *PubSub) pinger(psc *redis.PubSubConn, stop chan struct{}) {
+	ticker := time.NewTicker(ps.ping)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ps.mutex.Lock()
+			err := psc.Ping("")
+			ps.mutex.Unlock()
+
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (ps *PubSub) deliver(msg Message) {
+	if ps.handler != nil {
+		ps.handler(msg)
+		return
+	}
+
+	select {
+	case ps.messages <- msg:
+	case <-ps.closed:
+	}
+}
+
+// dial dial a conn not taken from the pool, a subscribed conn is held for long
+func (ps *PubSub) dial() (redis.Conn, error) {
+	group, ok := settings.Get(ps.s.InstanceName)
+	if !ok {
+		return nil, configNotExistsOrLoad(ps.s.InstanceName, ps.isMaster)
+	}
+
+	pool := ps.s.getPool(group, ps.isMaster)
+	if pool == nil {
+		return nil, configNotExistsOrLoad(ps.s.InstanceName, ps.isMaster)
+	}
+
+	return pool.Dial()
+}
+
+// restore subscribe names of set on a new conn
+func restore(subscribe func(args ...interface{}) error, names map[string]bool) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(names))
+	for name := range names {
+		args = append(args, name)
+	}
+
+	return subscribe(args...)
+}
@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// publishUntil publish until received, subscriptions are sent asynchronously
+func publishUntil(s *Structure, channel, data string) int64 {
+	for i := 0; i < 200; i++ {
+		n, err := s.Publish(channel, data)
+		if err == nil && n > 0 {
+			return n
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return 0
+}
+
+func TestPubSub(t *testing.T) {
+	Convey("pubsub test", t, func() {
+		fake := newFakeRedis(t)
+		defer fake.close()
+		fake.load(t, "PubSubTest")
+
+		s := NewStructure("PubSubTest", "%s")
+
+		Convey("subscribe and publish", func() {
+			ps := s.PubSub(SLAVE)
+			defer ps.Close()
+
+			So(ps.Subscribe("news"), ShouldBeNil)
+			So(ps.PSubscribe("user.*"), ShouldBeNil)
+
+			So(publishUntil(&s, "news", "hello"), ShouldEqual, 1)
+			So(<-ps.Messages(), ShouldResemble, Message{Channel: "news", Data: []byte("hello")})
+
+			So(publishUntil(&s, "user.1", "login"), ShouldEqual, 1)
+			So(<-ps.Messages(), ShouldResemble, Message{Channel: "user.1", Pattern: "user.*", Data: []byte("login")})
+
+			So(ps.Unsubscribe("news"), ShouldBeNil)
+			So(ps.PUnsubscribe("user.*"), ShouldBeNil)
+			for i := 0; i < 200; i++ {
+				if n, _ := s.Publish("news", "bye"); n == 0 {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			n, err := s.Publish("news", "bye")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+		})
+
+		Convey("handler", func() {
+			received := make(chan Message, 1)
+			ps := s.PubSub(MASTER, SetPubSubHandler(func(msg Message) {
+				received <- msg
+			}))
+			defer ps.Close()
+
+			So(ps.Subscribe("invalidate"), ShouldBeNil)
+			So(publishUntil(&s, "invalidate", "user:1"), ShouldEqual, 1)
+			So(string((<-received).Data), ShouldEqual, "user:1")
+		})
+
+		Convey("resubscribe after conn lost", func() {
+			ps := s.PubSub(SLAVE, SetPubSubPing(20*time.Millisecond), SetPubSubReconnect(10*time.Millisecond))
+			defer ps.Close()
+
+			So(ps.Subscribe("news"), ShouldBeNil)
+			So(publishUntil(&s, "news", "1"), ShouldEqual, 1)
+			So(string((<-ps.Messages()).Data), ShouldEqual, "1")
+
+			// pinged conn stays alive
+			time.Sleep(100 * time.Millisecond)
+			So(publishUntil(&s, "news", "2"), ShouldEqual, 1)
+			So(string((<-ps.Messages()).Data), ShouldEqual, "2")
+
+			fake.kick()
+			So(publishUntil(&s, "news", "3"), ShouldEqual, 1)
+			So(string((<-ps.Messages()).Data), ShouldEqual, "3")
+		})
+
+		Convey("close", func() {
+			ps := s.PubSub(SLAVE)
+			So(ps.Subscribe("news"), ShouldBeNil)
+			So(publishUntil(&s, "news", "1"), ShouldEqual, 1)
+
+			So(ps.Close(), ShouldBeNil)
+			So(ps.Close(), ShouldBeNil)
+			So(ps.Subscribe("other"), ShouldEqual, ErrPubSubClosed)
+
+			for range ps.Messages() {
+			}
+		})
+	})
+}
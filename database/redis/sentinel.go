@@ -0,0 +1,295 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// SENTINEL sentinel
+	SENTINEL = "SENTINEL"
+
+	_sentinelPing  = 5 * time.Second
+	_sentinelRetry = time.Second
+)
+
+var (
+	// ErrSentinelNoAddrs sentinel config without addrs
+	ErrSentinelNoAddrs = errors.New("redis: sentinel without addrs")
+
+	// events of failover and replicas, the group is refreshed on events of its master
+	_sentinelEvents = []interface{}{"+switch-master", "+sdown", "-sdown", "+slave"}
+
+	// cancels of watching sentinels by instance name
+	sentinels     = make(map[string]context.CancelFunc)
+	sentinelMutex sync.Mutex
+)
+
+// SentinelConfig sentinel of configs, the master and replicas of MasterName are discovered instead of Master and Slave
+type SentinelConfig struct {
+	MasterName string
+	Addrs      []string
+	DB         string
+}
+
+// sentinel discovers the group of an instance and follows failovers
+type sentinel struct {
+	instanceName string
+	poolSize     int64
+	conf         SentinelConfig
+}
+
+// loadSentinel store the discovered group of instanceName and watch the sentinels until reloaded or StopWatching
+// the watch keeps retrying when the first discovery failed
+func loadSentinel(instanceName string, configs Configs) error {
+	s := &sentinel{
+		instanceName: instanceName,
+		poolSize:     configs.PoolSize,
+		conf:         *configs.Sentinel,
+	}
+	if len(s.conf.Addrs) == 0 {
+		return ErrSentinelNoAddrs
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	swapSentinel(instanceName, cancel)
+
+	err := s.refresh(ctx)
+	go s.watch(ctx)
+
+	return err
+}
+
+// swapSentinel stop the watching sentinel of instanceName, cancel is the new one if not nil
+func swapSentinel(instanceName string, cancel context.CancelFunc) {
+	sentinelMutex.Lock()
+	defer sentinelMutex.Unlock()
+
+	if old, ok := sentinels[instanceName]; ok {
+		old()
+		delete(sentinels, instanceName)
+	}
+
+	if cancel != nil {
+		sentinels[instanceName] = cancel
+	}
+}
+
+func stopSentinels() {
+	sentinelMutex.Lock()
+	defer sentinelMutex.Unlock()
+
+	for instanceName, cancel := range sentinels {
+		cancel()
+		delete(sentinels, instanceName)
+	}
+}
+
+// refresh store the discovered group if changed, structures rebuild their pools on the next call
+func (s *sentinel) refresh(ctx context.Context) error {
+	group, err := s.discover()
+	if err != nil {
+		return err
+	}
+
+	// a stopped sentinel never overwrites the group of a reload
+	sentinelMutex.Lock()
+	defer sentinelMutex.Unlock()
+
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	old, ok := settings.Get(s.instanceName)
+	if ok && old.PoolSize == group.PoolSize && reflect.DeepEqual(old.RedisConns, group.RedisConns) {
+		return nil
+	}
+
+	log.Printf("Redis sentinel, name: %v, master: %v \r\n", s.instanceName, group.RedisConns[0].ConnStr)
+	settings.Store(s.instanceName, group)
+	return nil
+}
+
+// discover group from the first sentinel knowing the master
+func (s *sentinel) discover() (*Group, error) {
+	var lastErr error
+	for _, addr := range s.conf.Addrs {
+		group, err := s.discoverBy(addr)
+		if err == nil {
+			return group, nil
+		}
+
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// discoverBy master and healthy replicas, reads go to the master when no replica is healthy
+func (s *sentinel) discoverBy(addr string) (*Group, error) {
+	conn, err := dialSentinel(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	master, err := redis.Strings(conn.Do(SENTINEL, "get-master-addr-by-name", s.conf.MasterName))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(master) != 2 {
+		return nil, errors.New("redis: sentinel unexpected master addr")
+	}
+
+	replicas, err := redis.Values(conn.Do(SENTINEL, "slaves", s.conf.MasterName))
+	if err != nil {
+		return nil, err
+	}
+
+	group := &Group{
+		Name:     s.instanceName,
+		PoolSize: s.poolSize,
+		RedisConns: []Conn{
+			{ConnStr: net.JoinHostPort(master[0], master[1]), DB: s.conf.DB, IsMaster: true},
+		},
+	}
+
+	for _, replica := range replicas {
+		info, err := redis.StringMap(replica, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if replicaUp(info) {
+			group.RedisConns = append(group.RedisConns, Conn{ConnStr: net.JoinHostPort(info["ip"], info["port"]), DB: s.conf.DB})
+		}
+	}
+
+	if len(group.RedisConns) == 1 {
+		group.RedisConns = append(group.RedisConns, Conn{ConnStr: group.RedisConns[0].ConnStr, DB: s.conf.DB})
+	}
+
+	return group, nil
+}
+
+// watch subscribe events of sentinels until ctx done, the next sentinel is tried when one is lost
+func (s *sentinel) watch(ctx context.Context) {
+	for {
+		if err := s.subscribe(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed on redis sentinel, name: %v, err: %v \r\n", s.instanceName, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(_sentinelRetry):
+		}
+	}
+}
+
+// subscribe refresh after subscribed for events missed, then on every event of the master
+func (s *sentinel) subscribe(ctx context.Context) error {
+	var (
+		conn redis.Conn
+		err  error
+	)
+	for _, addr := range s.conf.Addrs {
+		if conn, err = dialSentinel(addr); err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	psc := redis.PubSubConn{Conn: conn}
+	done := make(chan struct{})
+	defer close(done)
+
+	// close unblocks receive, ping keeps a silent sentinel checked
+	go func() {
+		ticker := time.NewTicker(_sentinelPing)
+		defer ticker.Stop()
+		defer psc.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := psc.Ping(""); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	if err = psc.Subscribe(_sentinelEvents...); err != nil {
+		return err
+	}
+
+	if err = s.refresh(ctx); err != nil {
+		log.Printf("Failed on redis sentinel refresh, name: %v, err: %v \r\n", s.instanceName, err)
+	}
+
+	for {
+		switch msg := psc.ReceiveWithTimeout(2 * _sentinelPing).(type) {
+		case error:
+			return msg
+		case redis.Message:
+			if !sentinelEventOf(string(msg.Data), s.conf.MasterName) {
+				continue
+			}
+
+			if err = s.refresh(ctx); err != nil {
+				log.Printf("Failed on redis sentinel refresh, name: %v, event: %v, err: %v \r\n", s.instanceName, msg.Channel, err)
+			}
+		}
+	}
+}
+
+func dialSentinel(addr string) (redis.Conn, error) {
+	return redis.Dial("tcp", addr,
+		redis.DialConnectTimeout(ConnectTimeout),
+		redis.DialReadTimeout(ReadTimeout),
+		redis.DialWriteTimeout(WriteTimeout),
+	)
+}
+
+// replicaUp replica not down and linked to the master
+func replicaUp(info map[string]string) bool {
+	for _, flag := range strings.Split(info["flags"], ",") {
+		switch flag {
+		case "s_down", "o_down", "disconnected":
+			return false
+		}
+	}
+
+	status, ok := info["master-link-status"]
+	return !ok || status == "ok"
+}
+
+// sentinelEventOf true if data of the event is about the master name or its replicas
+// eg. "mymaster 127.0.0.1 6379 127.0.0.1 6380", "slave 127.0.0.1:6381 127.0.0.1 6381 @ mymaster 127.0.0.1 6380"
+func sentinelEventOf(data, name string) bool {
+	fields := strings.Fields(data)
+	for i, field := range fields {
+		if field == name && (i == 0 || fields[i-1] == "@" || fields[i-1] == "master") {
+			return true
+		}
+	}
+
+	return false
+}
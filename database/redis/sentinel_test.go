@@ -0,0 +1,106 @@
+package redis
+
+import (
+	"fmt"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/JREAMLU/j-kit/consul"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSentinel(t *testing.T) {
+	Convey("sentinel test", t, func() {
+		r1 := newFakeRedis(t)
+		defer r1.close()
+		r2 := newFakeRedis(t)
+		defer r2.close()
+
+		s := newFakeRedis(t)
+		defer s.close()
+		s.monitor("mymaster", r1.addr(), r2.addr())
+
+		// the first sentinel is down
+		key := path.Join(consul.Redis, "SentinelTest")
+		source := consul.NewMemorySource(map[string]string{
+			key: fmt.Sprintf("InstanceName = \"SentinelTest\"\n[Sentinel]\nMasterName = \"mymaster\"\nAddrs = [\"127.0.0.1:1\", %q]\nDB = \"0\"", s.addr()),
+		})
+		So(LoadSource(source, false, "SentinelTest"), ShouldBeNil)
+		defer StopWatching()
+
+		conns := func() []Conn {
+			group, _ := settings.Get("SentinelTest")
+			return group.RedisConns
+		}
+		subscribed := func() bool {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+
+			for session := range s.sessions {
+				if session.channels["+switch-master"] {
+					return true
+				}
+			}
+			return false
+		}
+
+		str := NewString("SentinelTest", "%s")
+
+		Convey("discover", func() {
+			So(conns(), ShouldResemble, []Conn{
+				{ConnStr: r1.addr(), DB: "0", IsMaster: true},
+				{ConnStr: r2.addr(), DB: "0"},
+			})
+
+			_, err := str.Set("k", "v1", 0)
+			So(err, ShouldBeNil)
+			So(r1.has("k"), ShouldBeTrue)
+		})
+
+		Convey("failover", func() {
+			_, err := str.Set("k", "v1", 0)
+			So(err, ShouldBeNil)
+			So(eventually(subscribed), ShouldBeTrue)
+
+			s.failover("mymaster", r2.addr(), r1.addr())
+			So(eventually(func() bool { return conns()[0].ConnStr == r2.addr() }), ShouldBeTrue)
+
+			_, err = str.Set("k2", "v2", 0)
+			So(err, ShouldBeNil)
+			So(r2.has("k2"), ShouldBeTrue)
+			So(r1.has("k2"), ShouldBeFalse)
+		})
+
+		Convey("replica down", func() {
+			So(eventually(subscribed), ShouldBeTrue)
+
+			// reads go to the master
+			s.sdown("mymaster", r2.addr())
+			So(eventually(func() bool { return len(conns()) == 2 && conns()[1].ConnStr == r1.addr() }), ShouldBeTrue)
+		})
+
+		Convey("reload without sentinel", func() {
+			So(eventually(subscribed), ShouldBeTrue)
+
+			source.Set(key, "InstanceName = \"SentinelTest\"\n[[master]]\nDB = \"0\"\nIP = \"127.0.0.1\"\nPort = \"1\"")
+			So(LoadSource(source, false, "SentinelTest"), ShouldBeNil)
+
+			s.failover("mymaster", r2.addr(), r1.addr())
+			time.Sleep(100 * time.Millisecond)
+			So(conns(), ShouldResemble, []Conn{{ConnStr: "127.0.0.1:1", DB: "0", IsMaster: true}})
+		})
+
+		Convey("unknown master", func() {
+			source.Set(key, fmt.Sprintf("InstanceName = \"SentinelTest\"\n[Sentinel]\nMasterName = \"none\"\nAddrs = [%q]\nDB = \"0\"", s.addr()))
+			So(LoadSource(source, false, "SentinelTest"), ShouldNotBeNil)
+		})
+	})
+
+	Convey("sentinel event of master", t, func() {
+		So(sentinelEventOf("mymaster 127.0.0.1 6379 127.0.0.1 6380", "mymaster"), ShouldBeTrue)
+		So(sentinelEventOf("slave 127.0.0.1:6381 127.0.0.1 6381 @ mymaster 127.0.0.1 6380", "mymaster"), ShouldBeTrue)
+		So(sentinelEventOf("master mymaster 127.0.0.1 6380", "mymaster"), ShouldBeTrue)
+		So(sentinelEventOf("slave 127.0.0.1:6381 127.0.0.1 6381 @ other 127.0.0.1 6380", "mymaster"), ShouldBeFalse)
+	})
+}
@@ -1,5 +1,7 @@
 package redis
 
+import "context"
+
 // Set redis set
 type Set struct {
 	Structure
@@ -43,6 +45,11 @@ func NewSet(instanceName, keyPrefixFmt string) Set {
 	}
 }
 
+// WithContext copy of set, commands honor deadline and cancellation of ctx
+func (s *Set) WithContext(ctx context.Context) *Set {
+	return &Set{Structure: s.Structure.WithContext(ctx)}
+}
+
 // Add add
 func (s *Set) Add(keySuffix string, values ...string) (bool, error) {
 	key := s.InitKey(keySuffix)
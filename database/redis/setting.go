@@ -4,16 +4,16 @@ import (
 	"fmt"
 	"math/rand"
 	"time"
+
+	"github.com/JREAMLU/j-kit/database/registry"
 )
 
-// Group redis group
+// Group redis group, a reload stores a new group, structures rebuild their pools on the next call
 type Group struct {
-	Name           string
-	PoolSize       int64
-	RedisConns     []Conn
-	IsCluster      bool
-	RefreshSetting bool
-	RefreshPool    bool
+	Name       string
+	PoolSize   int64
+	RedisConns []Conn
+	IsCluster  bool
 }
 
 // Conn redis conn
@@ -28,7 +28,12 @@ const (
 	ConfigNotExistsOrLoad = `redis config not exists OR not load config instance, server=%s,master=%v`
 )
 
-var settings map[string]*Group
+// settings groups by instance name, pools of a replaced group are released after registry.DefaultDrain
+var settings *registry.Instances[*Group]
+
+func init() {
+	settings = registry.New(registry.SetCloser(releasePools))
+}
 
 var (
 	// ConnectTimeout default redis Connect Timeout
@@ -65,60 +70,71 @@ func SetWriteTimeout(t time.Duration) {
 	WriteTimeout = t
 }
 
-func isCluster(instanceName string) bool {
-	if group, ok := settings[instanceName]; ok {
-		return group.IsCluster
+// OnReload call fn after group of name loaded or reloaded
+func OnReload(fn func(name string, group *Group)) {
+	settings.Subscribe(fn)
+}
+
+// releasePools close pools of the replaced group that no stored group uses
+func releasePools(replaced *Group) error {
+	used := make(map[Conn]bool)
+	for _, group := range settings.All() {
+		for _, conn := range group.RedisConns {
+			used[Conn{ConnStr: conn.ConnStr, DB: conn.DB}] = true
+		}
 	}
 
-	return false
-}
+	var lastErr error
+	for _, conn := range replaced.RedisConns {
+		if used[Conn{ConnStr: conn.ConnStr, DB: conn.DB}] {
+			continue
+		}
 
-func isRefreshPool(instanceName string) bool {
-	if group, ok := settings[instanceName]; ok {
-		return group.RefreshPool
+		if err := releasePool(conn.ConnStr, conn.DB); err != nil {
+			lastErr = err
+		}
 	}
 
-	return false
+	return lastErr
 }
 
-func toggleRefreshPool(instanceName string, toggle bool) {
-	if group, ok := settings[instanceName]; ok {
-		group.RefreshPool = toggle
+func isCluster(instanceName string) bool {
+	if group, ok := settings.Get(instanceName); ok {
+		return group.IsCluster
 	}
+
+	return false
 }
 
 func getClusterNodes(instanceName string) []string {
-	if _, ok := settings[instanceName]; !ok {
+	group, ok := settings.Get(instanceName)
+	if !ok {
 		return nil
 	}
 
-	nodes := make([]string, len(settings[instanceName].RedisConns))
-	for k, v := range settings[instanceName].RedisConns {
+	nodes := make([]string, len(group.RedisConns))
+	for k, v := range group.RedisConns {
 		nodes[k] = v.ConnStr
 	}
 
 	return nodes
 }
 
-func getConn(instanceName string, isMaster bool) *Conn {
+func (group *Group) getConn(isMaster bool) *Conn {
 	var pool []int
-	if group, ok := settings[instanceName]; ok {
-		for key := range group.RedisConns {
-			if group.RedisConns[key].IsMaster == isMaster {
-				pool = append(pool, key)
-			}
-		}
-
-		if len(pool) == 0 {
-			return nil
+	for key := range group.RedisConns {
+		if group.RedisConns[key].IsMaster == isMaster {
+			pool = append(pool, key)
 		}
+	}
 
-		LB := loadBalance(len(pool))
-		hited := pool[LB]
-		return &group.RedisConns[hited]
+	if len(pool) == 0 {
+		return nil
 	}
 
-	return nil
+	LB := loadBalance(len(pool))
+	hited := pool[LB]
+	return &group.RedisConns[hited]
 }
 
 func loadBalance(num int) int {
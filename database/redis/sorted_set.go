@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"errors"
 
 	"github.com/JREAMLU/j-kit/constant"
@@ -61,6 +62,11 @@ func NewSortedSet(instanceName, keyPrefixFmt string) SortedSet {
 	}
 }
 
+// WithContext copy of sorted set, commands honor deadline and cancellation of ctx
+func (s *SortedSet) WithContext(ctx context.Context) *SortedSet {
+	return &SortedSet{Structure: s.Structure.WithContext(ctx)}
+}
+
 // Add add
 func (s *SortedSet) Add(keySuffix, member string, score interface{}) (int64, error) {
 	return s.Int64(MASTER, ZADD, s.InitKey(keySuffix), score, member)
@@ -0,0 +1,469 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Stream redis stream
+type Stream struct {
+	Structure
+}
+
+const (
+	_defaultStreamCount         = 10
+	_defaultStreamBlock         = 500 * time.Millisecond
+	_defaultStreamClaimIdle     = 30 * time.Second
+	_defaultStreamClaimInterval = 10 * time.Second
+	_streamDeadLetterSuffix     = ":dead-letter"
+
+	// XADD xadd
+	XADD = "XADD"
+	// XLEN xlen
+	XLEN = "XLEN"
+	// XDEL xdel
+	XDEL = "XDEL"
+	// XRANGE xrange
+	XRANGE = "XRANGE"
+	// XREVRANGE xrevrange
+	XREVRANGE = "XREVRANGE"
+	// XGROUP xgroup
+	XGROUP = "XGROUP"
+	// XREADGROUP xreadgroup
+	XREADGROUP = "XREADGROUP"
+	// XACK xack
+	XACK = "XACK"
+	// XPENDING xpending
+	XPENDING = "XPENDING"
+	// XCLAIM xclaim
+	XCLAIM = "XCLAIM"
+	// MAXLEN maxlen
+	MAXLEN = "MAXLEN"
+	// CREATE create
+	CREATE = "CREATE"
+	// MKSTREAM mkstream
+	MKSTREAM = "MKSTREAM"
+	// GROUP group
+	GROUP = "GROUP"
+	// BLOCK block
+	BLOCK = "BLOCK"
+	// STREAMS streams
+	STREAMS = "STREAMS"
+	// COUNT count
+	COUNT = "COUNT"
+)
+
+// StreamMessage message of stream
+type StreamMessage struct {
+	ID     string
+	Values map[string]string
+}
+
+// StreamPending pending message of consumer group
+type StreamPending struct {
+	ID         string
+	Consumer   string
+	Idle       time.Duration
+	Deliveries int64
+}
+
+// StreamHandler handle message of Consume, the message is acked when nil returned
+type StreamHandler func(msg StreamMessage) error
+
+// NewStream new stream
+func NewStream(instanceName, keyPrefixFmt string) Stream {
+	return Stream{
+		Structure: NewStructure(instanceName, keyPrefixFmt),
+	}
+}
+
+// WithContext copy of stream, commands honor deadline and cancellation of ctx
+func (s *Stream) WithContext(ctx context.Context) *Stream {
+	return &Stream{Structure: s.Structure.WithContext(ctx)}
+}
+
+// XAdd xadd with auto id, the stream is trimmed to about maxLen when maxLen > 0
+func (s *Stream) XAdd(keySuffix string, maxLen int64, fields ...interface{}) (string, error) {
+	args := []interface{}{s.InitKey(keySuffix)}
+	if maxLen > 0 {
+		args = append(args, MAXLEN, "~", maxLen)
+	}
+	args = append(args, "*")
+
+	return s.String(MASTER, XADD, append(args, fields...)...)
+}
+
+// XLen xlen
+func (s *Stream) XLen(keySuffix string) (int64, error) {
+	return s.Int64(SLAVE, XLEN, s.InitKey(keySuffix))
+}
+
+// XDel xdel
+func (s *Stream) XDel(keySuffix string, ids ...string) (int64, error) {
+	return s.Int64(MASTER, XDEL, redis.Args{}.Add(s.InitKey(keySuffix)).AddFlat(ids)...)
+}
+
+// XRange messages between start and end, "-" and "+" are the first and last, all when count <= 0
+func (s *Stream) XRange(keySuffix, start, end string, count int64) ([]StreamMessage, error) {
+	return streamMessages(s.Do(SLAVE, XRANGE, rangeArgs(s.InitKey(keySuffix), start, end, count)...))
+}
+
+// XRevRange messages between end and start in reverse order
+func (s *Stream) XRevRange(keySuffix, end, start string, count int64) ([]StreamMessage, error) {
+	return streamMessages(s.Do(SLAVE, XREVRANGE, rangeArgs(s.InitKey(keySuffix), end, start, count)...))
+}
+
+// XGroupCreate create consumer group from id, "$" for new messages only, "0" for all
+// the stream is created if not exists, an existing group is not an error
+func (s *Stream) XGroupCreate(keySuffix, group, id string) error {
+	_, err := s.Do(MASTER, XGROUP, CREATE, s.InitKey(keySuffix), group, id, MKSTREAM)
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+
+	return err
+}
+
+// XReadGroup read new messages as consumer of group, blocks up to block when none
+// block must be shorter than ReadTimeout, nil when timed out
+func (s *Stream) XReadGroup(keySuffix, group, consumer string, count int64, block time.Duration) ([]StreamMessage, error) {
+	args := []interface{}{GROUP, group, consumer}
+	if count > 0 {
+		args = append(args, COUNT, count)
+	}
+	if block > 0 {
+		args = append(args, BLOCK, int64(block/time.Millisecond))
+	}
+	args = append(args, STREAMS, s.InitKey(keySuffix), ">")
+
+	streams, err := redis.Values(s.Do(MASTER, XREADGROUP, args...))
+	if err == redis.ErrNil || len(streams) == 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// [[key, messages]]
+	stream, err := redis.Values(streams[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(stream) != 2 {
+		return nil, fmt.Errorf("redis: unexpected stream reply %v", streams[0])
+	}
+
+	return streamMessages(stream[1], nil)
+}
+
+// XAck xack
+func (s *Stream) XAck(keySuffix, group string, ids ...string) (int64, error) {
+	return s.Int64(MASTER, XACK, redis.Args{}.Add(s.InitKey(keySuffix), group).AddFlat(ids)...)
+}
+
+// XPending pending messages of group between start and end
+func (s *Stream) XPending(keySuffix, group, start, end string, count int64) ([]StreamPending, error) {
+	values, err := redis.Values(s.Do(MASTER, XPENDING, s.InitKey(keySuffix), group, start, end, count))
+	if err != nil {
+		return nil, err
+	}
+
+	pendings := make([]StreamPending, 0, len(values))
+	for _, value := range values {
+		// [id, consumer, idle ms, deliveries]
+		fields, err := redis.Values(value, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			pending StreamPending
+			idle    int64
+		)
+		if _, err = redis.Scan(fields, &pending.ID, &pending.Consumer, &idle, &pending.Deliveries); err != nil {
+			return nil, err
+		}
+		pending.Idle = time.Duration(idle) * time.Millisecond
+
+		pendings = append(pendings, pending)
+	}
+
+	return pendings, nil
+}
+
+// XClaim claim messages idle at least minIdle to consumer, deleted messages are skipped
+func (s *Stream) XClaim(keySuffix, group, consumer string, minIdle time.Duration, ids ...string) ([]StreamMessage, error) {
+	args := redis.Args{}.Add(s.InitKey(keySuffix), group, consumer, int64(minIdle/time.Millisecond)).AddFlat(ids)
+	return streamMessages(s.Do(MASTER, XCLAIM, args...))
+}
+
+// streamConsumer options of Consume
+type streamConsumer struct {
+	count         int64
+	block         time.Duration
+	claimIdle     time.Duration
+	claimInterval time.Duration
+	maxDeliveries int64
+	deadLetter    string
+}
+
+// StreamConsumerOptionFunc consume option func
+type StreamConsumerOptionFunc func(*streamConsumer)
+
+// SetStreamCount set messages of each read, default is 10
+func SetStreamCount(count int64) StreamConsumerOptionFunc {
+	return func(c *streamConsumer) {
+		if count > 0 {
+			c.count = count
+		}
+	}
+}
+
+// SetStreamBlock set block of each read, must be shorter than ReadTimeout, default is 500ms
+func SetStreamBlock(block time.Duration) StreamConsumerOptionFunc {
+	return func(c *streamConsumer) {
+		if block > 0 {
+			c.block = block
+		}
+	}
+}
+
+// SetStreamClaim claim messages pending longer than idle every interval, default is 30s and 10s
+func SetStreamClaim(idle, interval time.Duration) StreamConsumerOptionFunc {
+	return func(c *streamConsumer) {
+		if idle > 0 {
+			c.claimIdle = idle
+		}
+
+		if interval > 0 {
+			c.claimInterval = interval
+		}
+	}
+}
+
+// SetStreamDeadLetter move messages delivered maxDeliveries times to stream keySuffix
+// default keySuffix is the consumed keySuffix with suffix ":dead-letter"
+func SetStreamDeadLetter(maxDeliveries int64, keySuffix string) StreamConsumerOptionFunc {
+	return func(c *streamConsumer) {
+		c.maxDeliveries = maxDeliveries
+		c.deadLetter = keySuffix
+	}
+}
+
+// Consume consume keySuffix as consumer of group until ctx done, the group is created for new messages
+// a message failed by handler stays pending, it is claimed again when idle
+// and moved to the dead letter stream after max deliveries if set
+func (s *Stream) Consume(ctx context.Context, keySuffix, group, consumer string, handler StreamHandler, opts ...StreamConsumerOptionFunc) error {
+	c := &streamConsumer{
+		count:         _defaultStreamCount,
+		block:         _defaultStreamBlock,
+		claimIdle:     _defaultStreamClaimIdle,
+		claimInterval: _defaultStreamClaimInterval,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.deadLetter == "" {
+		c.deadLetter = keySuffix + _streamDeadLetterSuffix
+	}
+
+	st := s.WithContext(ctx)
+	if err := st.XGroupCreate(keySuffix, group, "$"); err != nil {
+		return err
+	}
+
+	var lastClaim time.Time
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if time.Since(lastClaim) >= c.claimInterval {
+			lastClaim = time.Now()
+			if err := st.reclaim(c, keySuffix, group, consumer, handler); err != nil && ctx.Err() == nil {
+				log.Printf("Failed on redis stream reclaim, key: %v, group: %v, err: %v \r\n", keySuffix, group, err)
+			}
+		}
+
+		msgs, err := st.XReadGroup(keySuffix, group, consumer, c.count, c.block)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			log.Printf("Failed on redis stream XReadGroup, key: %v, group: %v, err: %v \r\n", keySuffix, group, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.block):
+			}
+			continue
+		}
+
+		st.handle(keySuffix, group, msgs, handler)
+	}
+}
+
+// handle handle messages, ack the handled
+func (s *Stream) handle(keySuffix, group string, msgs []StreamMessage, handler StreamHandler) {
+	for _, msg := range msgs {
+		if err := handler(msg); err != nil {
+			log.Printf("Failed on redis stream handle, key: %v, id: %v, err: %v \r\n", keySuffix, msg.ID, err)
+			continue
+		}
+
+		if _, err := s.XAck(keySuffix, group, msg.ID); err != nil {
+			log.Printf("Failed on redis stream XAck, key: %v, id: %v, err: %v \r\n", keySuffix, msg.ID, err)
+		}
+	}
+}
+
+// reclaim claim and handle idle pending messages, move them to dead letter after max deliveries
+func (s *Stream) reclaim(c *streamConsumer, keySuffix, group, consumer string, handler StreamHandler) error {
+	pendings, err := s.XPending(keySuffix, group, "-", "+", c.count)
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	for _, pending := range pendings {
+		if pending.Idle < c.claimIdle {
+			continue
+		}
+
+		if c.maxDeliveries > 0 && pending.Deliveries >= c.maxDeliveries {
+			if err = s.deadLetter(c, keySuffix, group, pending.ID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		ids = append(ids, pending.ID)
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	msgs, err := s.XClaim(keySuffix, group, consumer, c.claimIdle, ids...)
+	if err != nil {
+		return err
+	}
+
+	if err = s.ackDeleted(keySuffix, group, ids, msgs); err != nil {
+		return err
+	}
+
+	s.handle(keySuffix, group, msgs, handler)
+	return nil
+}
+
+// ackDeleted ack claimed ids whose messages were deleted, redis before 7 keeps them pending
+func (s *Stream) ackDeleted(keySuffix, group string, ids []string, msgs []StreamMessage) error {
+	claimed := make(map[string]bool, len(msgs))
+	for _, msg := range msgs {
+		claimed[msg.ID] = true
+	}
+
+	for _, id := range ids {
+		if claimed[id] {
+			continue
+		}
+
+		exists, err := s.XRange(keySuffix, id, id, 1)
+		if err != nil {
+			return err
+		}
+		if len(exists) > 0 {
+			continue
+		}
+
+		if _, err = s.XAck(keySuffix, group, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deadLetter copy message id to dead letter stream and ack it
+func (s *Stream) deadLetter(c *streamConsumer, keySuffix, group, id string) error {
+	msgs, err := s.XRange(keySuffix, id, id, 1)
+	if err != nil {
+		return err
+	}
+
+	// deleted messages are only acked
+	if len(msgs) == 1 {
+		fields := make([]interface{}, 0, len(msgs[0].Values)*2)
+		for field, value := range msgs[0].Values {
+			fields = append(fields, field, value)
+		}
+
+		if _, err = s.XAdd(c.deadLetter, 0, fields...); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.XAck(keySuffix, group, id)
+	return err
+}
+
+func rangeArgs(key, start, end string, count int64) []interface{} {
+	args := []interface{}{key, start, end}
+	if count > 0 {
+		args = append(args, COUNT, count)
+	}
+
+	return args
+}
+
+// streamMessages messages of reply [[id, [field, value, ...]], ...]
+func streamMessages(reply interface{}, err error) ([]StreamMessage, error) {
+	values, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([]StreamMessage, 0, len(values))
+	for _, value := range values {
+		// redis before 7 replies nil for claimed but deleted messages
+		if value == nil {
+			continue
+		}
+
+		entry, err := redis.Values(value, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(entry) != 2 {
+			return nil, fmt.Errorf("redis: unexpected stream entry %v", value)
+		}
+
+		// fields of claimed but deleted messages are nil
+		if entry[1] == nil {
+			continue
+		}
+
+		id, err := redis.String(entry[0], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		fields, err := redis.StringMap(entry[1], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		msgs = append(msgs, StreamMessage{ID: id, Values: fields})
+	}
+
+	return msgs, nil
+}
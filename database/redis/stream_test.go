@@ -0,0 +1,199 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStream(t *testing.T) {
+	Convey("stream test", t, func() {
+		fake := newFakeRedis(t)
+		defer fake.close()
+		fake.load(t, "StreamTest")
+
+		s := NewStream("StreamTest", "stream:%s")
+
+		Convey("add and range", func() {
+			var ids []string
+			for i := 0; i < 5; i++ {
+				id, err := s.XAdd("range", 3, "n", i)
+				So(err, ShouldBeNil)
+				ids = append(ids, id)
+			}
+
+			n, err := s.XLen("range")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 3)
+
+			msgs, err := s.XRange("range", "-", "+", 0)
+			So(err, ShouldBeNil)
+			So(msgs, ShouldResemble, []StreamMessage{
+				{ID: ids[2], Values: map[string]string{"n": "2"}},
+				{ID: ids[3], Values: map[string]string{"n": "3"}},
+				{ID: ids[4], Values: map[string]string{"n": "4"}},
+			})
+
+			msgs, err = s.XRevRange("range", "+", "-", 2)
+			So(err, ShouldBeNil)
+			So(len(msgs), ShouldEqual, 2)
+			So(msgs[0].ID, ShouldEqual, ids[4])
+
+			n, err = s.XDel("range", ids[4])
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+		})
+
+		Convey("group read and ack", func() {
+			_, err := s.XAdd("group", 0, "a", "1")
+			So(err, ShouldBeNil)
+			_, err = s.XAdd("group", 0, "b", "2")
+			So(err, ShouldBeNil)
+
+			So(s.XGroupCreate("group", "g", "0"), ShouldBeNil)
+			So(s.XGroupCreate("group", "g", "0"), ShouldBeNil)
+
+			msgs, err := s.XReadGroup("group", "g", "c1", 1, 0)
+			So(err, ShouldBeNil)
+			So(len(msgs), ShouldEqual, 1)
+			So(msgs[0].Values, ShouldResemble, map[string]string{"a": "1"})
+
+			pendings, err := s.XPending("group", "g", "-", "+", 10)
+			So(err, ShouldBeNil)
+			So(len(pendings), ShouldEqual, 1)
+			So(pendings[0].ID, ShouldEqual, msgs[0].ID)
+			So(pendings[0].Consumer, ShouldEqual, "c1")
+			So(pendings[0].Deliveries, ShouldEqual, 1)
+
+			claimed, err := s.XClaim("group", "g", "c2", 0, msgs[0].ID)
+			So(err, ShouldBeNil)
+			So(claimed, ShouldResemble, msgs)
+
+			n, err := s.XAck("group", "g", msgs[0].ID)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+
+			msgs, err = s.XReadGroup("group", "g", "c1", 10, 20*time.Millisecond)
+			So(err, ShouldBeNil)
+			So(len(msgs), ShouldEqual, 1)
+
+			msgs, err = s.XReadGroup("group", "g", "c1", 10, 20*time.Millisecond)
+			So(err, ShouldBeNil)
+			So(msgs, ShouldBeEmpty)
+		})
+
+		Convey("consume, reclaim and dead letter", func() {
+			So(s.XGroupCreate("jobs", "workers", "$"), ShouldBeNil)
+			for _, job := range []string{"ok", "flaky", "poison"} {
+				_, err := s.XAdd("jobs", 0, "job", job)
+				So(err, ShouldBeNil)
+			}
+
+			var (
+				mutex   sync.Mutex
+				handled = make(map[string]int)
+			)
+			handler := func(msg StreamMessage) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+
+				job := msg.Values["job"]
+				handled[job]++
+				if job == "poison" || (job == "flaky" && handled[job] == 1) {
+					return errors.New("failed")
+				}
+
+				return nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			result := make(chan error)
+			go func() {
+				result <- s.Consume(ctx, "jobs", "workers", "w1", handler,
+					SetStreamBlock(20*time.Millisecond),
+					SetStreamClaim(50*time.Millisecond, 20*time.Millisecond),
+					SetStreamDeadLetter(2, ""))
+			}()
+
+			var dead []StreamMessage
+			for i := 0; i < 200 && len(dead) == 0; i++ {
+				time.Sleep(10 * time.Millisecond)
+
+				var err error
+				dead, err = s.XRange("jobs"+_streamDeadLetterSuffix, "-", "+", 0)
+				So(err, ShouldBeNil)
+			}
+			cancel()
+			So(<-result, ShouldEqual, context.Canceled)
+
+			So(len(dead), ShouldEqual, 1)
+			So(dead[0].Values, ShouldResemble, map[string]string{"job": "poison"})
+
+			mutex.Lock()
+			So(handled["ok"], ShouldEqual, 1)
+			So(handled["flaky"], ShouldEqual, 2)
+			So(handled["poison"], ShouldEqual, 2)
+			mutex.Unlock()
+
+			pendings, err := s.XPending("jobs", "workers", "-", "+", 10)
+			So(err, ShouldBeNil)
+			So(pendings, ShouldBeEmpty)
+		})
+
+		Convey("reclaim deleted messages before redis 7", func() {
+			fake.mutex.Lock()
+			fake.legacyClaim = true
+			fake.mutex.Unlock()
+
+			So(s.XGroupCreate("legacy", "workers", "$"), ShouldBeNil)
+			id, err := s.XAdd("legacy", 0, "job", "deleted")
+			So(err, ShouldBeNil)
+
+			msgs, err := s.XReadGroup("legacy", "workers", "w1", 1, 0)
+			So(err, ShouldBeNil)
+			So(len(msgs), ShouldEqual, 1)
+
+			_, err = s.XDel("legacy", id)
+			So(err, ShouldBeNil)
+
+			claimed, err := s.XClaim("legacy", "workers", "w2", 0, id)
+			So(err, ShouldBeNil)
+			So(claimed, ShouldBeEmpty)
+
+			var handled int64
+			handler := func(msg StreamMessage) error {
+				atomic.AddInt64(&handled, 1)
+				return nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			result := make(chan error)
+			go func() {
+				result <- s.Consume(ctx, "legacy", "workers", "w1", handler,
+					SetStreamBlock(20*time.Millisecond),
+					SetStreamClaim(20*time.Millisecond, 20*time.Millisecond))
+			}()
+
+			var pendings []StreamPending
+			for i := 0; i < 200; i++ {
+				time.Sleep(10 * time.Millisecond)
+
+				pendings, err = s.XPending("legacy", "workers", "-", "+", 10)
+				So(err, ShouldBeNil)
+				if len(pendings) == 0 {
+					break
+				}
+			}
+			cancel()
+			So(<-result, ShouldEqual, context.Canceled)
+
+			So(pendings, ShouldBeEmpty)
+			So(atomic.LoadInt64(&handled), ShouldEqual, 0)
+		})
+	})
+}
@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -66,6 +67,11 @@ func NewString(instanceName, keyPrefixFmt string) String {
 	}
 }
 
+// WithContext copy of string, commands honor deadline and cancellation of ctx
+func (s *String) WithContext(ctx context.Context) *String {
+	return &String{Structure: s.Structure.WithContext(ctx)}
+}
+
 // Exists exists
 func (s *String) Exists(keySuffix string) (bool, error) {
 	ok, err := s.Int(SLAVE, EXISTS, s.InitKey(keySuffix))
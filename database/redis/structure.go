@@ -1,12 +1,14 @@
 package redis
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/JREAMLU/j-kit/constant"
+	"github.com/JREAMLU/j-kit/database/registry"
 	"github.com/JREAMLU/j-kit/ext"
 	"github.com/gomodule/redigo/redis"
 	"github.com/mna/redisc"
@@ -42,22 +44,36 @@ const (
 	LIMIT = "LIMIT"
 	// WEIGHTS weights
 	WEIGHTS = "WEIGHTS"
+	// DEL del
+	DEL = "DEL"
 )
 
 // Structure redis structure
+// commands use the context of WithContext, see DoContext
 type Structure struct {
 	KeyPrefixFmt string
 	InstanceName string
-	readPool     *redis.Pool
-	writePool    *redis.Pool
-	clusterPool  *redisc.Cluster
-	writeConn    string
-	readConn     string
-	mutex        sync.Mutex
 	MaxIdle      int
 	IdleTimeout  time.Duration
+	TxRetries    int
+	ctx          context.Context
+	pools        *structurePools
 }
 
+// structurePools pools of structure, shared by the copies of WithContext
+type structurePools struct {
+	mutex       sync.Mutex
+	group       *Group
+	readPool    *redis.Pool
+	writePool   *redis.Pool
+	clusterPool *redisc.Cluster
+	writeConn   string
+	readConn    string
+}
+
+// _poolsMutex guards the lazy pools of structures not made by NewStructure
+var _poolsMutex sync.Mutex
+
 // NewStructure new structure
 func NewStructure(instanceName, keyPrefixFmt string) Structure {
 	return Structure{
@@ -65,6 +81,8 @@ func NewStructure(instanceName, keyPrefixFmt string) Structure {
 		InstanceName: instanceName,
 		MaxIdle:      _defaultMaxidle,
 		IdleTimeout:  _defaultIdletimeout,
+		TxRetries:    _defaultTxRetries,
+		pools:        &structurePools{},
 	}
 }
 
@@ -78,6 +96,23 @@ func (s *Structure) SetIdleTimeout(idleTimeout time.Duration) {
 	s.IdleTimeout = idleTimeout
 }
 
+// WithContext copy of structure, commands honor deadline and cancellation of ctx, pools are shared
+func (s *Structure) WithContext(ctx context.Context) Structure {
+	c := *s
+	c.ctx = ctx
+	c.pools = s.getPools()
+	return c
+}
+
+// Context context of commands, default is context.Background()
+func (s *Structure) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+
+	return s.ctx
+}
+
 // InitKey init redis key
 func (s *Structure) InitKey(keySuffix string) string {
 	if ext.StringEq(keySuffix) {
@@ -87,131 +122,88 @@ func (s *Structure) InitKey(keySuffix string) string {
 	return fmt.Sprintf(s.KeyPrefixFmt, keySuffix)
 }
 
-// Bool bool base operation
-func (s *Structure) Bool(isMaster bool, cmd string, params ...interface{}) (reply bool, err error) {
-	conn := s.getConn(isMaster)
-	if conn == nil {
-		return false, configNotExistsOrLoad(s.InstanceName, isMaster)
+// initKeys keys of suffixes
+func (s *Structure) initKeys(keySuffix []string) []interface{} {
+	keys := make([]interface{}, len(keySuffix))
+	for i := range keySuffix {
+		keys[i] = s.InitKey(keySuffix[i])
 	}
 
-	reply, err = redis.Bool(conn.Do(cmd, params...))
-	conn.Close()
+	return keys
+}
 
-	return reply, err
+// Do do cmd with the context of structure
+func (s *Structure) Do(isMaster bool, cmd string, params ...interface{}) (interface{}, error) {
+	return s.DoContext(s.Context(), isMaster, cmd, params...)
 }
 
-// String string base operation
-func (s *Structure) String(isMaster bool, cmd string, params ...interface{}) (reply string, err error) {
-	conn := s.getConn(isMaster)
-	if conn == nil {
-		return "", configNotExistsOrLoad(s.InstanceName, isMaster)
+// DoContext do cmd, waiting for conn and reply honor deadline and cancellation of ctx
+// a child span is started when ctx has an opentracing span
+func (s *Structure) DoContext(ctx context.Context, isMaster bool, cmd string, params ...interface{}) (reply interface{}, err error) {
+	span := startSpan(ctx, s.InstanceName, cmd)
+	defer func() {
+		finishSpan(span, err)
+	}()
+
+	conn, err := s.getConn(ctx, isMaster)
+	if err != nil {
+		return nil, err
 	}
 
-	reply, err = redis.String(conn.Do(cmd, params...))
+	reply, err = doContext(ctx, conn, cmd, params...)
 	conn.Close()
 
 	return reply, err
 }
 
-// Strings strings base operation
-func (s *Structure) Strings(isMaster bool, cmd string, params ...interface{}) (reply []string, err error) {
-	conn := s.getConn(isMaster)
-	if conn == nil {
-		return nil, configNotExistsOrLoad(s.InstanceName, isMaster)
-	}
+// Bool bool base operation
+func (s *Structure) Bool(isMaster bool, cmd string, params ...interface{}) (reply bool, err error) {
+	return redis.Bool(s.Do(isMaster, cmd, params...))
+}
 
-	reply, err = redis.Strings(conn.Do(cmd, params...))
-	conn.Close()
+// String string base operation
+func (s *Structure) String(isMaster bool, cmd string, params ...interface{}) (reply string, err error) {
+	return redis.String(s.Do(isMaster, cmd, params...))
+}
 
-	return reply, err
+// Strings strings base operation
+func (s *Structure) Strings(isMaster bool, cmd string, params ...interface{}) (reply []string, err error) {
+	return redis.Strings(s.Do(isMaster, cmd, params...))
 }
 
 // StringMap stringmap base operation
 func (s *Structure) StringMap(isMaster bool, cmd string, params ...interface{}) (reply map[string]string, err error) {
-	conn := s.getConn(isMaster)
-	if conn == nil {
-		return nil, configNotExistsOrLoad(s.InstanceName, isMaster)
-	}
-
-	reply, err = redis.StringMap(conn.Do(cmd, params...))
-	conn.Close()
-
-	return reply, err
+	return redis.StringMap(s.Do(isMaster, cmd, params...))
 }
 
 // Int int base operation
 func (s *Structure) Int(isMaster bool, cmd string, params ...interface{}) (reply int, err error) {
-	conn := s.getConn(isMaster)
-	if conn == nil {
-		return constant.ZeroInt, configNotExistsOrLoad(s.InstanceName, isMaster)
-	}
-
-	reply, err = redis.Int(conn.Do(cmd, params...))
-	conn.Close()
-
-	return reply, err
+	return redis.Int(s.Do(isMaster, cmd, params...))
 }
 
 // Ints ints base operation
 func (s *Structure) Ints(isMaster bool, cmd string, params ...interface{}) (reply []int, err error) {
-	conn := s.getConn(isMaster)
-	if conn == nil {
-		return nil, configNotExistsOrLoad(s.InstanceName, isMaster)
-	}
-
-	reply, err = redis.Ints(conn.Do(cmd, params...))
-	conn.Close()
-
-	return reply, err
+	return redis.Ints(s.Do(isMaster, cmd, params...))
 }
 
 // Int64 int64 base operation
 func (s *Structure) Int64(isMaster bool, cmd string, params ...interface{}) (reply int64, err error) {
-	conn := s.getConn(isMaster)
-	if conn == nil {
-		return constant.ZeroInt64, configNotExistsOrLoad(s.InstanceName, isMaster)
-	}
-
-	reply, err = redis.Int64(conn.Do(cmd, params...))
-	conn.Close()
-
-	return reply, err
+	return redis.Int64(s.Do(isMaster, cmd, params...))
 }
 
 // Int64s int64s base operation
 func (s *Structure) Int64s(isMaster bool, cmd string, params ...interface{}) (reply []int64, err error) {
-	conn := s.getConn(isMaster)
-	if conn == nil {
-		return nil, configNotExistsOrLoad(s.InstanceName, isMaster)
-	}
-
-	reply, err = redis.Int64s(conn.Do(cmd, params...))
-	conn.Close()
-
-	return reply, err
+	return redis.Int64s(s.Do(isMaster, cmd, params...))
 }
 
 // Float64 float64 base operation
 func (s *Structure) Float64(isMaster bool, cmd string, params ...interface{}) (reply float64, err error) {
-	conn := s.getConn(isMaster)
-	if conn == nil {
-		return constant.ZeroFLOAT64, configNotExistsOrLoad(s.InstanceName, isMaster)
-	}
-
-	reply, err = redis.Float64(conn.Do(cmd, params...))
-	conn.Close()
-
-	return reply, err
+	return redis.Float64(s.Do(isMaster, cmd, params...))
 }
 
 // Float64Slice float64slice
 func (s *Structure) Float64Slice(isMaster bool, cmd string, params ...interface{}) (reply [][]float64, err error) {
-	conn := s.getConn(isMaster)
-	if conn == nil {
-		return nil, configNotExistsOrLoad(s.InstanceName, isMaster)
-	}
-
-	items, err := redis.Values(conn.Do(cmd, params...))
+	items, err := redis.Values(s.Do(isMaster, cmd, params...))
 	if err != nil {
 		return nil, err
 	}
@@ -224,30 +216,22 @@ func (s *Structure) Float64Slice(isMaster bool, cmd string, params ...interface{
 			cErr = err
 		}
 	}
-	conn.Close()
 
 	return reply, cErr
 }
 
 // MultiBulk Multi Bulk
 func (s *Structure) MultiBulk(isMaster bool, cmd string, params ...interface{}) (reply []interface{}, err error) {
-	conn := s.getConn(isMaster)
-	if conn == nil {
-		return nil, configNotExistsOrLoad(s.InstanceName, isMaster)
-	}
-
-	reply, err = redis.MultiBulk(conn.Do(cmd, params...))
-	conn.Close()
-
-	return reply, err
+	return redis.MultiBulk(s.Do(isMaster, cmd, params...))
 }
 
 // ScanAllMap scan all return map
 func (s *Structure) ScanAllMap(key, luaBody string) (map[string]string, error) {
 	cursor := 0
-	conn := s.getConn(SLAVE)
-	if conn == nil {
-		return nil, configNotExistsOrLoad(s.InstanceName, SLAVE)
+	ctx := s.Context()
+	conn, err := s.getConn(ctx, SLAVE)
+	if err != nil {
+		return nil, err
 	}
 
 	defer conn.Close()
@@ -264,7 +248,7 @@ func (s *Structure) ScanAllMap(key, luaBody string) (map[string]string, error) {
 	result := make(map[string]string)
 
 	for {
-		results, err := redis.Strings(script.Do(conn, 0, key, cursor, _defaultPagesize))
+		results, err := redis.Strings(scriptDo(ctx, script, conn, 0, key, cursor, _defaultPagesize))
 		if err != nil {
 			return nil, err
 		}
@@ -289,9 +273,10 @@ func (s *Structure) ScanAllMap(key, luaBody string) (map[string]string, error) {
 // ScanAll scan by lua
 func (s *Structure) ScanAll(key, luaBody string) ([]string, error) {
 	cursor := 0
-	conn := s.getConn(SLAVE)
-	if conn == nil {
-		return nil, configNotExistsOrLoad(s.InstanceName, SLAVE)
+	ctx := s.Context()
+	conn, err := s.getConn(ctx, SLAVE)
+	if err != nil {
+		return nil, err
 	}
 
 	defer conn.Close()
@@ -307,7 +292,7 @@ func (s *Structure) ScanAll(key, luaBody string) ([]string, error) {
 	}
 
 	for {
-		results, err := redis.Strings(script.Do(conn, 0, key, cursor, _defaultPagesize))
+		results, err := redis.Strings(scriptDo(ctx, script, conn, 0, key, cursor, _defaultPagesize))
 		if err != nil {
 			return nil, err
 		}
@@ -329,9 +314,10 @@ func (s *Structure) ScanAll(key, luaBody string) ([]string, error) {
 // Scan scan
 // first return params: int is remain numbers
 func (s *Structure) Scan(key, luaBody string, cursor, pageSize int) (int, []string, error) {
-	conn := s.getConn(SLAVE)
-	if conn == nil {
-		return constant.ZeroInt, nil, configNotExistsOrLoad(s.InstanceName, SLAVE)
+	ctx := s.Context()
+	conn, err := s.getConn(ctx, SLAVE)
+	if err != nil {
+		return constant.ZeroInt, nil, err
 	}
 
 	defer conn.Close()
@@ -346,7 +332,7 @@ func (s *Structure) Scan(key, luaBody string, cursor, pageSize int) (int, []stri
 	}
 
 	//第一个0参数是KEYS参数个数
-	reply, err := redis.Strings(script.Do(conn, 0, key, cursor, pageSize))
+	reply, err := redis.Strings(scriptDo(ctx, script, conn, 0, key, cursor, pageSize))
 	if err != nil {
 		return constant.ZeroInt, nil, err
 	}
@@ -361,79 +347,71 @@ func (s *Structure) Scan(key, luaBody string, cursor, pageSize int) (int, []stri
 
 // Values values
 func (s *Structure) Values(isMaster bool, cmd string, params ...interface{}) (reply []interface{}, err error) {
-	conn := s.getConn(isMaster)
-	if conn == nil {
-		return nil, configNotExistsOrLoad(s.InstanceName, isMaster)
+	return redis.Values(s.Do(isMaster, cmd, params...))
+}
+
+func (s *Structure) getConn(ctx context.Context, isMaster bool) (redis.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	reply, err = redis.Values(conn.Do(cmd, params...))
-	conn.Close()
+	var conn redis.Conn
+	var err error
+	if s.isCluster() {
+		conn = s.getClusterConn()
+	} else {
+		conn, err = s.getClientConn(ctx, isMaster)
+	}
 
-	return reply, err
-}
+	if err != nil {
+		return nil, err
+	}
 
-func (s *Structure) getConn(isMaster bool) redis.Conn {
-	if s.isCluster() {
-		return s.getClusterConn()
+	if conn == nil {
+		return nil, configNotExistsOrLoad(s.InstanceName, isMaster)
 	}
 
-	return s.getClientConn(isMaster)
+	return conn, nil
 }
 
 func (s *Structure) isCluster() bool {
 	return isCluster(s.InstanceName)
 }
 
-func (s *Structure) getClientConn(isMaster bool) redis.Conn {
-	// refresh true, set pool = nil, then get new pool
-	if isRefreshPool(s.InstanceName) {
-		s.mutex.Lock()
-		s.writePool = nil
-		s.readPool = nil
-		toggleRefreshPool(s.InstanceName, OFF)
-		s.mutex.Unlock()
-	}
-
-	if s.writePool == nil {
-		s.writePool = s.getPool(s.InstanceName, MASTER)
-		s.readPool = s.getPool(s.InstanceName, SLAVE)
+// getClientConn get conn of pool, waiting for an idle conn honors ctx
+func (s *Structure) getClientConn(ctx context.Context, isMaster bool) (redis.Conn, error) {
+	p := s.getPools()
+	p.mutex.Lock()
+	group := s.syncGroup()
+	if p.writePool == nil && group != nil {
+		p.writePool = s.getPool(group, MASTER)
+		p.readPool = s.getPool(group, SLAVE)
 	}
+	writePool, readPool := p.writePool, p.readPool
+	p.mutex.Unlock()
 
 	if isMaster {
-		if s.writePool == nil {
-			return nil
+		if writePool == nil {
+			return nil, nil
 		}
 
-		return s.writePool.Get()
+		return writePool.GetContext(ctx)
 	}
 
-	if s.readPool == nil {
-		return nil
+	if readPool == nil {
+		return nil, nil
 	}
 
-	return s.readPool.Get()
+	return readPool.GetContext(ctx)
 }
 
 func (s *Structure) getClusterConn() redis.Conn {
-	// refresh true, set pool = nil, then get new pool
-	if isRefreshPool(s.InstanceName) {
-		s.mutex.Lock()
-		if s.clusterPool != nil {
-			s.clusterPool.Close()
-			delete(poolcs, s.InstanceName)
-			s.clusterPool = nil
-		}
-		toggleRefreshPool(s.InstanceName, OFF)
-		s.mutex.Unlock()
-	}
-
-	if s.clusterPool == nil {
-		if s.clusterPool = s.getClusterPool(s.InstanceName); s.clusterPool == nil {
-			return nil
-		}
+	clusterPool := s.getCluster()
+	if clusterPool == nil {
+		return nil
 	}
 
-	retryConn, err := redisc.RetryConn(s.clusterPool.Get(), _defaultClusterRetryTime, _defaultClusterRetryDelay)
+	retryConn, err := redisc.RetryConn(clusterPool.Get(), _defaultClusterRetryTime, _defaultClusterRetryDelay)
 	if err != nil {
 		return nil
 	}
@@ -441,8 +419,58 @@ func (s *Structure) getClusterConn() redis.Conn {
 	return retryConn
 }
 
-func (s *Structure) getPool(instanceName string, isMaster bool) *redis.Pool {
-	conn := getConn(instanceName, isMaster)
+// getCluster cluster of the current group
+func (s *Structure) getCluster() *redisc.Cluster {
+	p := s.getPools()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if s.syncGroup(); p.clusterPool == nil {
+		p.clusterPool = s.getClusterPool(s.InstanceName)
+	}
+
+	return p.clusterPool
+}
+
+// getPools pools of structure, created for zero value and literal structures
+func (s *Structure) getPools() *structurePools {
+	_poolsMutex.Lock()
+	defer _poolsMutex.Unlock()
+
+	if s.pools == nil {
+		s.pools = &structurePools{}
+	}
+
+	return s.pools
+}
+
+// syncGroup drop pools built from the replaced group after reload, must hold the pools mutex
+// the replaced cluster is closed after registry.DefaultDrain, in-flight conns can finish
+func (s *Structure) syncGroup() *Group {
+	p := s.getPools()
+	group, _ := settings.Get(s.InstanceName)
+	if group == p.group {
+		return group
+	}
+
+	if clusterPool := p.clusterPool; clusterPool != nil {
+		time.AfterFunc(registry.DefaultDrain, func() {
+			clusterPool.Close()
+		})
+	}
+
+	p.group = group
+	p.writePool = nil
+	p.readPool = nil
+	p.clusterPool = nil
+	p.writeConn = ""
+	p.readConn = ""
+
+	return group
+}
+
+func (s *Structure) getPool(group *Group, isMaster bool) *redis.Pool {
+	conn := group.getConn(isMaster)
 	if conn == nil {
 		return nil
 	}
@@ -455,27 +483,32 @@ func (s *Structure) getClusterPool(instanceName string) *redisc.Cluster {
 }
 
 func (s *Structure) getConnstr(isMaster bool) string {
-	if isMaster && s.writeConn != "" {
-		return s.writeConn
+	p := s.getPools()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	group := s.syncGroup()
+	if isMaster && p.writeConn != "" {
+		return p.writeConn
 	}
 
-	if !isMaster && s.readConn != "" {
-		return s.readConn
+	if !isMaster && p.readConn != "" {
+		return p.readConn
 	}
 
-	conn := getConn(s.InstanceName, isMaster)
-	if conn == nil {
-		conn = getConn(s.InstanceName, isMaster)
+	if group == nil {
+		return constant.EmptyStr
 	}
 
+	conn := group.getConn(isMaster)
 	if conn == nil {
 		return constant.EmptyStr
 	}
 
 	if isMaster {
-		s.writeConn = conn.ConnStr
+		p.writeConn = conn.ConnStr
 	} else {
-		s.readConn = conn.ConnStr
+		p.readConn = conn.ConnStr
 	}
 
 	return conn.ConnStr
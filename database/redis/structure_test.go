@@ -0,0 +1,39 @@
+package redis
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStructure(t *testing.T) {
+	Convey("structure test", t, func() {
+		fake := newFakeRedis(t)
+		defer fake.close()
+		fake.load(t, "StructureTest")
+
+		Convey("literal", func() {
+			s := Structure{InstanceName: "StructureTest", KeyPrefixFmt: "literal:%s"}
+			_, err := s.Do(MASTER, SET, s.InitKey("a"), "1")
+			So(err, ShouldBeNil)
+
+			str := String{Structure: s}
+			value, err := str.Get("a")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "1")
+			So(s.getConnstr(MASTER), ShouldNotBeEmpty)
+		})
+
+		Convey("zero value", func() {
+			var s Structure
+			s.InstanceName = "StructureTest"
+			_, err := s.Do(MASTER, SET, "b", "2")
+			So(err, ShouldBeNil)
+
+			c := s.WithContext(s.Context())
+			value, err := c.String(SLAVE, GET, "b")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "2")
+		})
+	})
+}
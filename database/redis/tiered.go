@@ -0,0 +1,217 @@
+package redis
+
+import (
+	"log"
+
+	"github.com/JREAMLU/j-kit/constant"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	_tieredChannelPrefix = "j-kit:invalidate:"
+)
+
+// Tiered local cache in front of String and Hash of an instance
+// writes through Tiered publish the key, every replica evicts its local copy, the writer too
+// the invalidation reaches the slave after the write, so a stale copy read through the lagging slave is evicted
+// while the subscription is lost entries live up to the ttl of the local cache, the local cache is purged after resubscribed
+type Tiered struct {
+	str     String
+	hash    Hash
+	local   *LocalCache
+	channel string
+	ps      *PubSub
+}
+
+// TieredOptionFunc tiered option func
+type TieredOptionFunc func(*Tiered)
+
+// SetTieredLocalCache set local cache, default is NewLocalCache()
+func SetTieredLocalCache(local *LocalCache) TieredOptionFunc {
+	return func(t *Tiered) {
+		t.local = local
+	}
+}
+
+// SetTieredChannel set invalidation channel, default is "j-kit:invalidate:" + instanceName
+func SetTieredChannel(channel string) TieredOptionFunc {
+	return func(t *Tiered) {
+		t.channel = channel
+	}
+}
+
+// NewTiered new tiered cache, subscribes invalidations on slave, Close it when done
+func NewTiered(instanceName, keyPrefixFmt string, opts ...TieredOptionFunc) (*Tiered, error) {
+	t := &Tiered{
+		str:     NewString(instanceName, keyPrefixFmt),
+		hash:    NewHash(instanceName, keyPrefixFmt),
+		channel: _tieredChannelPrefix + instanceName,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	if t.local == nil {
+		t.local = NewLocalCache()
+	}
+
+	t.ps = t.str.PubSub(SLAVE, SetPubSubHandler(t.evict), SetPubSubConnected(t.local.Purge))
+
+	if err := t.ps.Subscribe(t.channel); err != nil {
+		t.ps.Close()
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Get string get
+func (t *Tiered) Get(keySuffix string) (string, error) {
+	key := t.str.InitKey(keySuffix)
+	if value, ok := t.local.Get(key); ok {
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	}
+
+	gen := t.local.Gen()
+	value, err := t.str.Get(keySuffix)
+	if err != nil {
+		return constant.EmptyStr, err
+	}
+
+	t.local.SetIfGen(key, value, int64(len(key)+len(value)), gen)
+	return value, nil
+}
+
+// Set string set
+func (t *Tiered) Set(keySuffix string, value interface{}, when int) (bool, error) {
+	ok, err := t.str.Set(keySuffix, value, when)
+	t.invalidate(t.str.InitKey(keySuffix))
+
+	return ok, err
+}
+
+// SetEX string setex
+func (t *Tiered) SetEX(keySuffix, value string, timespan int) (bool, error) {
+	ok, err := t.str.SetEX(keySuffix, value, timespan)
+	t.invalidate(t.str.InitKey(keySuffix))
+
+	return ok, err
+}
+
+// Delete delete key of string or hash
+func (t *Tiered) Delete(keySuffix string) (int64, error) {
+	key := t.str.InitKey(keySuffix)
+	n, err := t.str.Int64(MASTER, DEL, key)
+	t.invalidate(key)
+
+	return n, err
+}
+
+// HGetAll hash getall, the whole hash is cached, for small hot hashes
+func (t *Tiered) HGetAll(keySuffix string) (map[string]string, error) {
+	key := t.hash.InitKey(keySuffix)
+	if value, ok := t.local.Get(key); ok {
+		if m, ok := value.(map[string]string); ok {
+			return copyStringMap(m), nil
+		}
+	}
+
+	gen := t.local.Gen()
+	value, err := t.hash.StringMap(SLAVE, HGETALL, key)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(len(key))
+	for field, v := range value {
+		size += int64(len(field) + len(v))
+	}
+
+	t.local.SetIfGen(key, copyStringMap(value), size, gen)
+	return value, nil
+}
+
+// HGet field of the cached hash, redis.ErrNil if not exists
+func (t *Tiered) HGet(keySuffix, field string) (string, error) {
+	value, err := t.HGetAll(keySuffix)
+	if err != nil {
+		return constant.EmptyStr, err
+	}
+
+	v, ok := value[field]
+	if !ok {
+		return constant.EmptyStr, redis.ErrNil
+	}
+
+	return v, nil
+}
+
+// HSet hash hset
+func (t *Tiered) HSet(keySuffix, field string, value interface{}, when int) (int, error) {
+	n, err := t.hash.Set(keySuffix, field, value, when)
+	t.invalidate(t.hash.InitKey(keySuffix))
+
+	return n, err
+}
+
+// HMSet hash hmset
+func (t *Tiered) HMSet(keySuffix string, fields ...interface{}) (string, error) {
+	reply, err := t.hash.MSet(keySuffix, fields...)
+	t.invalidate(t.hash.InitKey(keySuffix))
+
+	return reply, err
+}
+
+// HDel hash hdel
+func (t *Tiered) HDel(keySuffix string, fields ...interface{}) (bool, error) {
+	ok, err := t.hash.Delete(keySuffix, fields...)
+	t.invalidate(t.hash.InitKey(keySuffix))
+
+	return ok, err
+}
+
+// Invalidate evict keySuffix locally and on every replica
+func (t *Tiered) Invalidate(keySuffix string) error {
+	key := t.str.InitKey(keySuffix)
+	t.local.Delete(key)
+
+	_, err := t.str.Publish(t.channel, key)
+	return err
+}
+
+// Stats stats of the local cache
+func (t *Tiered) Stats() LocalCacheStats {
+	return t.local.Stats()
+}
+
+// Close stop receiving invalidations
+func (t *Tiered) Close() error {
+	return t.ps.Close()
+}
+
+// invalidate evict key after a write, also after a failed one which may be applied
+// publish failures are logged, replicas keep the stale entry up to the local ttl
+func (t *Tiered) invalidate(key string) {
+	t.local.Delete(key)
+
+	if _, err := t.str.Publish(t.channel, key); err != nil {
+		log.Printf("Failed on redis tiered invalidate, key: %v, err: %v \r\n", key, err)
+	}
+}
+
+// evict evict key of invalidation, also of the ones published by itself
+func (t *Tiered) evict(msg Message) {
+	t.local.Delete(string(msg.Data))
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+
+	return c
+}
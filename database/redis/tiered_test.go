@@ -0,0 +1,150 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// eventually poll fn up to 2s
+func eventually(fn func() bool) bool {
+	for i := 0; i < 200; i++ {
+		if fn() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return false
+}
+
+func TestTiered(t *testing.T) {
+	Convey("tiered test", t, func() {
+		fake := newFakeRedis(t)
+		defer fake.close()
+		fake.load(t, "TieredTest")
+
+		// two replicas of a service
+		a, err := NewTiered("TieredTest", "t:%s")
+		So(err, ShouldBeNil)
+		defer a.Close()
+
+		b, err := NewTiered("TieredTest", "t:%s")
+		So(err, ShouldBeNil)
+		defer b.Close()
+
+		s := NewStructure("TieredTest", "%s")
+		So(eventually(func() bool {
+			n, _ := s.Publish(_tieredChannelPrefix+"TieredTest", "none")
+			return n == 2
+		}), ShouldBeTrue)
+
+		cached := func(tiered *Tiered, key string) bool {
+			tiered.local.mutex.Lock()
+			defer tiered.local.mutex.Unlock()
+
+			_, ok := tiered.local.items[key]
+			return ok
+		}
+
+		// delivered wait for the invalidations published before, own writes are evicted too
+		delivered := func(tiered *Tiered) bool {
+			tiered.local.Set("t:delivered", "", 11)
+			_, err := s.Publish(_tieredChannelPrefix+"TieredTest", "t:delivered")
+			return err == nil && eventually(func() bool { return !cached(tiered, "t:delivered") })
+		}
+
+		Convey("string", func() {
+			_, err := a.Set("k", "v1", 0)
+			So(err, ShouldBeNil)
+			So(delivered(a), ShouldBeTrue)
+
+			value, err := a.Get("k")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "v1")
+
+			value, err = a.Get("k")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "v1")
+			So(a.Stats().Hits, ShouldEqual, 1)
+			So(a.Stats().Misses, ShouldEqual, 1)
+
+			// written by the other replica
+			_, err = b.SetEX("k", "v2", 60)
+			So(err, ShouldBeNil)
+			So(eventually(func() bool { return !cached(a, "t:k") }), ShouldBeTrue)
+
+			value, err = a.Get("k")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "v2")
+
+			_, err = b.Delete("k")
+			So(err, ShouldBeNil)
+			So(eventually(func() bool { return !cached(a, "t:k") }), ShouldBeTrue)
+			_, err = a.Get("k")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("hash", func() {
+			_, err := a.HMSet("h", "f1", "1", "f2", "2")
+			So(err, ShouldBeNil)
+			So(delivered(a), ShouldBeTrue)
+
+			value, err := a.HGet("h", "f1")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "1")
+
+			all, err := a.HGetAll("h")
+			So(err, ShouldBeNil)
+			So(all, ShouldResemble, map[string]string{"f1": "1", "f2": "2"})
+			So(a.Stats().Hits, ShouldEqual, 1)
+
+			_, err = b.HSet("h", "f1", "3", 0)
+			So(err, ShouldBeNil)
+			So(eventually(func() bool { return !cached(a, "t:h") }), ShouldBeTrue)
+
+			value, err = a.HGet("h", "f1")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "3")
+
+			_, err = b.HDel("h", "f2")
+			So(err, ShouldBeNil)
+			So(eventually(func() bool { return !cached(a, "t:h") }), ShouldBeTrue)
+
+			_, err = a.HGet("h", "f2")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("evict own write", func() {
+			_, err := a.Set("k", "v1", 0)
+			So(err, ShouldBeNil)
+			So(delivered(a), ShouldBeTrue)
+
+			// read through a slave lagging behind the write
+			a.local.Set("t:k", "stale", 8)
+			So(cached(a, "t:k"), ShouldBeTrue)
+
+			// the invalidation of the write reaches the slave after it
+			_, err = a.str.Publish(_tieredChannelPrefix+"TieredTest", "t:k")
+			So(err, ShouldBeNil)
+			So(eventually(func() bool { return !cached(a, "t:k") }), ShouldBeTrue)
+
+			value, err := a.Get("k")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "v1")
+		})
+
+		Convey("purge after resubscribed", func() {
+			_, err := a.Set("k", "v1", 0)
+			So(err, ShouldBeNil)
+			So(delivered(a), ShouldBeTrue)
+			_, err = a.Get("k")
+			So(err, ShouldBeNil)
+			So(cached(a, "t:k"), ShouldBeTrue)
+
+			fake.kick()
+			So(eventually(func() bool { return !cached(a, "t:k") }), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,250 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/mna/redisc"
+)
+
+const (
+	_defaultTxRetries = 3
+
+	// MULTI multi
+	MULTI = "MULTI"
+	// EXEC exec
+	EXEC = "EXEC"
+	// WATCH watch
+	WATCH = "WATCH"
+)
+
+var (
+	// ErrTxAborted watched keys changed, EXEC replied nil
+	ErrTxAborted = errors.New("redis: transaction aborted, watched keys changed")
+	// ErrTxCrossSlot keys of cluster transaction not in one slot
+	ErrTxCrossSlot = errors.New("redis: transaction keys must hash to one slot in cluster mode")
+	// ErrTxQueued Watch or Do after Send, commands are being queued by MULTI
+	ErrTxQueued = errors.New("redis: transaction already in MULTI, use Send")
+)
+
+// Tx transaction on one master conn
+// Watch and Do run at once before MULTI, Send queues commands in MULTI, EXEC runs after fn returns
+// in cluster mode the first arg of every command is its key, all keys must hash to one slot
+type Tx struct {
+	s       *Structure
+	ctx     context.Context
+	cluster bool
+	conn    redis.Conn
+	slot    int
+	multi   bool
+	replies []*PipelineReply
+	err     error
+}
+
+// SetTxRetries set retries of Tx when watched keys changed, default is 3
+func (s *Structure) SetTxRetries(retries int) {
+	s.TxRetries = retries
+}
+
+// Tx run fn in MULTI/EXEC, fn is called again when watched keys changed
+// returns the error of fn, or ErrTxAborted when retries are used up
+func (s *Structure) Tx(fn func(tx *Tx) error) error {
+	for i := 0; ; i++ {
+		err := s.tx(fn)
+		if err != ErrTxAborted || i >= s.TxRetries {
+			return err
+		}
+	}
+}
+
+func (s *Structure) tx(fn func(tx *Tx) error) (err error) {
+	ctx := s.Context()
+	span := startSpan(ctx, s.InstanceName, "tx")
+	defer func() {
+		finishSpan(span, err)
+	}()
+
+	tx := &Tx{
+		s:       s,
+		ctx:     ctx,
+		cluster: s.isCluster(),
+	}
+	// the pooled conn unwatches or discards when closed
+	defer tx.close()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	return tx.exec()
+}
+
+// Watch watch keys, the transaction is aborted if any of them changed before EXEC
+func (tx *Tx) Watch(keys ...string) error {
+	if tx.multi {
+		return ErrTxQueued
+	}
+
+	if err := tx.bind(keys...); err != nil {
+		return err
+	}
+
+	args := make([]interface{}, len(keys))
+	for i := range keys {
+		args[i] = keys[i]
+	}
+
+	_, err := doContext(tx.ctx, tx.conn, WATCH, args...)
+	return err
+}
+
+// Do do cmd at once, eg. read watched keys
+func (tx *Tx) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if tx.multi {
+		return nil, ErrTxQueued
+	}
+
+	if err := tx.bind(argKeys(args)...); err != nil {
+		return nil, err
+	}
+
+	return doContext(tx.ctx, tx.conn, cmd, args...)
+}
+
+// Send queue cmd in MULTI, the reply is available after EXEC
+func (tx *Tx) Send(cmd string, args ...interface{}) *PipelineReply {
+	reply := &PipelineReply{cmd: cmd, args: args}
+	tx.replies = append(tx.replies, reply)
+
+	if tx.err == nil {
+		tx.err = tx.send(cmd, args...)
+	}
+
+	return reply
+}
+
+func (tx *Tx) send(cmd string, args ...interface{}) error {
+	if err := tx.bind(argKeys(args)...); err != nil {
+		return err
+	}
+
+	if !tx.multi {
+		if err := tx.conn.Send(MULTI); err != nil {
+			return err
+		}
+		tx.multi = true
+	}
+
+	return tx.conn.Send(cmd, args...)
+}
+
+// exec EXEC queued commands, replies are set
+func (tx *Tx) exec() error {
+	if tx.err != nil {
+		setReplies(tx.replies, tx.err)
+		return tx.err
+	}
+
+	if !tx.multi {
+		return nil
+	}
+
+	reply, err := doContext(tx.ctx, tx.conn, EXEC)
+	if err == nil && reply == nil {
+		err = ErrTxAborted
+	}
+
+	if err != nil {
+		setReplies(tx.replies, err)
+		return err
+	}
+
+	values, err := redis.Values(reply, nil)
+	if err != nil || len(values) != len(tx.replies) {
+		err = fmt.Errorf("redis: unexpected EXEC reply %v, err: %v", reply, err)
+		setReplies(tx.replies, err)
+		return err
+	}
+
+	for i, value := range values {
+		tx.replies[i].reply = value
+		if e, ok := value.(redis.Error); ok {
+			tx.replies[i].err = e
+		}
+		tx.replies[i].received = true
+	}
+
+	return nil
+}
+
+// bind get the conn on first use, bound to the slot of keys in cluster mode
+func (tx *Tx) bind(keys ...string) error {
+	if tx.cluster {
+		for _, key := range keys {
+			slot := redisc.Slot(key)
+			if tx.conn != nil && slot != tx.slot {
+				return ErrTxCrossSlot
+			}
+		}
+	}
+
+	if tx.conn != nil {
+		return nil
+	}
+
+	if err := tx.ctx.Err(); err != nil {
+		return err
+	}
+
+	if !tx.cluster {
+		conn, err := tx.s.getConn(tx.ctx, MASTER)
+		if err != nil {
+			return err
+		}
+
+		tx.conn = conn
+		return nil
+	}
+
+	if len(keys) == 0 {
+		return ErrTxCrossSlot
+	}
+
+	for _, key := range keys[1:] {
+		if redisc.Slot(key) != redisc.Slot(keys[0]) {
+			return ErrTxCrossSlot
+		}
+	}
+
+	cluster := tx.s.getCluster()
+	if cluster == nil {
+		return configNotExistsOrLoad(tx.s.InstanceName, MASTER)
+	}
+
+	conn := cluster.Get()
+	if err := redisc.BindConn(conn, keys...); err != nil {
+		conn.Close()
+		return err
+	}
+
+	tx.conn = conn
+	tx.slot = redisc.Slot(keys[0])
+	return nil
+}
+
+func (tx *Tx) close() {
+	if tx.conn != nil {
+		tx.conn.Close()
+	}
+}
+
+// argKeys key of command, the first arg
+func argKeys(args []interface{}) []string {
+	if len(args) == 0 {
+		return nil
+	}
+
+	return []string{fmt.Sprint(args[0])}
+}
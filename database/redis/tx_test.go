@@ -0,0 +1,112 @@
+package redis
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	redigo "github.com/gomodule/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTx(t *testing.T) {
+	Convey("tx test", t, func() {
+		fake := newFakeRedis(t)
+		defer fake.close()
+		fake.load(t, "TxTest")
+
+		s := NewStructure("TxTest", "%s")
+
+		Convey("multi exec", func() {
+			var set, incr *PipelineReply
+			err := s.Tx(func(tx *Tx) error {
+				set = tx.Send(SET, "a", "1")
+				incr = tx.Send(INCR, "a")
+				return nil
+			})
+			So(err, ShouldBeNil)
+
+			ok, err := set.String()
+			So(err, ShouldBeNil)
+			So(ok, ShouldEqual, OK)
+
+			n, err := incr.Int()
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+		})
+
+		Convey("watch and retry", func() {
+			s.SetTxRetries(100)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					err := s.Tx(func(tx *Tx) error {
+						if err := tx.Watch("counter"); err != nil {
+							return err
+						}
+
+						n, err := redigo.Int(tx.Do(GET, "counter"))
+						if err != nil && err != redigo.ErrNil {
+							return err
+						}
+
+						tx.Send(SET, "counter", n+1)
+						return nil
+					})
+					if err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			n, err := s.Int(SLAVE, GET, "counter")
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 10)
+		})
+
+		Convey("aborted", func() {
+			s.SetTxRetries(0)
+			other := NewStructure("TxTest", "%s")
+
+			calls := 0
+			err := s.Tx(func(tx *Tx) error {
+				calls++
+				if err := tx.Watch("b"); err != nil {
+					return err
+				}
+
+				// changed by others between WATCH and EXEC
+				if _, err := other.String(MASTER, SET, "b", "x"); err != nil {
+					return err
+				}
+
+				tx.Send(SET, "b", "y")
+				_, err := tx.Do(GET, "b")
+				So(err, ShouldEqual, ErrTxQueued)
+				return nil
+			})
+			So(err, ShouldEqual, ErrTxAborted)
+			So(calls, ShouldEqual, 1)
+
+			value, err := s.String(SLAVE, GET, "b")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "x")
+		})
+
+		Convey("fn error", func() {
+			errFn := errors.New("fn")
+			err := s.Tx(func(tx *Tx) error {
+				tx.Send(SET, "c", "1")
+				return errFn
+			})
+			So(err, ShouldEqual, errFn)
+
+			_, err = s.String(SLAVE, GET, "c")
+			So(err, ShouldEqual, redigo.ErrNil)
+		})
+	})
+}
@@ -2,6 +2,7 @@ package redis
 
 import (
 	"log"
+	"sync"
 
 	"github.com/JREAMLU/core/crypto"
 	redigo "github.com/gomodule/redigo/redis"
@@ -156,12 +157,18 @@ func sliceChunkStr(slice []string, size int) (chunkslice [][]string) {
 // Second key is string = sha1(luaBody)
 var LuaBodySha1 = make(map[string]map[string]*redigo.Script)
 
+// luaMutex guards LuaBodySha1
+var luaMutex sync.Mutex
+
 // GetScript get lu script
 func GetScript(key, luaBody string) *redigo.Script {
 	var m map[string]*redigo.Script
 	var s *redigo.Script
 	var ok bool
 
+	luaMutex.Lock()
+	defer luaMutex.Unlock()
+
 	if m, ok = LuaBodySha1[key]; !ok {
 		m = make(map[string]*redigo.Script)
 		LuaBodySha1[key] = m
@@ -0,0 +1,196 @@
+package registry
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultDrain default drain period before closing replaced instances
+const DefaultDrain = 30 * time.Second
+
+// Instances named instances, eg. db clients, safe for concurrent use
+// reload swaps instances atomically, replaced instances are closed after the drain period
+type Instances[T comparable] struct {
+	mutex       sync.RWMutex
+	items       map[string]T
+	closer      func(T) error
+	drain       time.Duration
+	subscribers []func(name string, value T)
+}
+
+// OptionFunc instances option func
+type OptionFunc[T comparable] func(*Instances[T])
+
+// SetCloser set closer of replaced and deleted instances
+func SetCloser[T comparable](closer func(T) error) OptionFunc[T] {
+	return func(instances *Instances[T]) {
+		instances.closer = closer
+	}
+}
+
+// SetDrain set drain period before closing replaced instances, 0 closes at once
+func SetDrain[T comparable](drain time.Duration) OptionFunc[T] {
+	return func(instances *Instances[T]) {
+		if drain >= 0 {
+			instances.drain = drain
+		}
+	}
+}
+
+// New new instances
+func New[T comparable](opts ...OptionFunc[T]) *Instances[T] {
+	instances := &Instances[T]{
+		items: make(map[string]T),
+		drain: DefaultDrain,
+	}
+
+	for _, opt := range opts {
+		opt(instances)
+	}
+
+	return instances
+}
+
+// Get get instance by name
+func (instances *Instances[T]) Get(name string) (T, bool) {
+	instances.mutex.RLock()
+	value, ok := instances.items[name]
+	instances.mutex.RUnlock()
+
+	return value, ok
+}
+
+// All copy of all instances
+func (instances *Instances[T]) All() map[string]T {
+	instances.mutex.RLock()
+	items := make(map[string]T, len(instances.items))
+	for name, value := range instances.items {
+		items[name] = value
+	}
+	instances.mutex.RUnlock()
+
+	return items
+}
+
+// Names sorted names of all instances
+func (instances *Instances[T]) Names() []string {
+	instances.mutex.RLock()
+	names := make([]string, 0, len(instances.items))
+	for name := range instances.items {
+		names = append(names, name)
+	}
+	instances.mutex.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// Len number of instances
+func (instances *Instances[T]) Len() int {
+	instances.mutex.RLock()
+	defer instances.mutex.RUnlock()
+
+	return len(instances.items)
+}
+
+// Store store instance, the replaced one is closed after drain
+func (instances *Instances[T]) Store(name string, value T) {
+	instances.Swap(map[string]T{name: value})
+}
+
+// Swap store instances at once, others are kept, the replaced ones are closed after drain
+func (instances *Instances[T]) Swap(values map[string]T) {
+	instances.mutex.Lock()
+	replaced := make(map[string]T)
+	for name, value := range values {
+		if old, ok := instances.items[name]; ok && old != value {
+			replaced[name] = old
+		}
+		instances.items[name] = value
+	}
+	subscribers := instances.subscribers
+	instances.mutex.Unlock()
+
+	instances.closeAll(replaced)
+	notify(subscribers, values)
+}
+
+// Delete delete instance, it is closed after drain
+func (instances *Instances[T]) Delete(name string) {
+	instances.mutex.Lock()
+	old, ok := instances.items[name]
+	delete(instances.items, name)
+	instances.mutex.Unlock()
+
+	if ok {
+		instances.closeAll(map[string]T{name: old})
+	}
+}
+
+// Subscribe call fn after every stored instance, in name order
+func (instances *Instances[T]) Subscribe(fn func(name string, value T)) {
+	instances.mutex.Lock()
+	instances.subscribers = append(instances.subscribers, fn)
+	instances.mutex.Unlock()
+}
+
+// Close delete and close all instances at once
+func (instances *Instances[T]) Close() {
+	instances.mutex.Lock()
+	items := instances.items
+	instances.items = make(map[string]T)
+	instances.mutex.Unlock()
+
+	for name, value := range items {
+		instances.close(name, value)
+	}
+}
+
+func (instances *Instances[T]) closeAll(items map[string]T) {
+	if instances.closer == nil || len(items) == 0 {
+		return
+	}
+
+	if instances.drain == 0 {
+		for name, value := range items {
+			instances.close(name, value)
+		}
+		return
+	}
+
+	time.AfterFunc(instances.drain, func() {
+		for name, value := range items {
+			instances.close(name, value)
+		}
+	})
+}
+
+func (instances *Instances[T]) close(name string, value T) {
+	if instances.closer == nil {
+		return
+	}
+
+	if err := instances.closer(value); err != nil {
+		log.Printf("Failed on registry close, name: %v, err: %v \r\n", name, err)
+	}
+}
+
+func notify[T comparable](subscribers []func(name string, value T), values map[string]T) {
+	if len(subscribers) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, fn := range subscribers {
+		for _, name := range names {
+			fn(name, values[name])
+		}
+	}
+}
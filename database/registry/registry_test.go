@@ -0,0 +1,116 @@
+package registry
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testClient struct {
+	name   string
+	mutex  sync.Mutex
+	closed bool
+}
+
+func (client *testClient) Close() error {
+	client.mutex.Lock()
+	client.closed = true
+	client.mutex.Unlock()
+	return nil
+}
+
+func (client *testClient) isClosed() bool {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.closed
+}
+
+func TestInstances(t *testing.T) {
+	Convey("instances test", t, func() {
+		closer := SetCloser(func(client *testClient) error { return client.Close() })
+
+		Convey("swap and close at once", func() {
+			instances := New(closer, SetDrain[*testClient](0))
+			a1, b1 := &testClient{name: "a1"}, &testClient{name: "b1"}
+			instances.Swap(map[string]*testClient{"a": a1, "b": b1})
+			So(instances.Names(), ShouldResemble, []string{"a", "b"})
+
+			a2 := &testClient{name: "a2"}
+			instances.Store("a", a2)
+			value, ok := instances.Get("a")
+			So(ok, ShouldBeTrue)
+			So(value, ShouldEqual, a2)
+			So(a1.isClosed(), ShouldBeTrue)
+			So(b1.isClosed(), ShouldBeFalse)
+
+			// the same instance stored again is not closed
+			instances.Store("a", a2)
+			So(a2.isClosed(), ShouldBeFalse)
+
+			instances.Delete("b")
+			So(b1.isClosed(), ShouldBeTrue)
+			_, ok = instances.Get("b")
+			So(ok, ShouldBeFalse)
+
+			instances.Close()
+			So(a2.isClosed(), ShouldBeTrue)
+			So(instances.Len(), ShouldEqual, 0)
+		})
+
+		Convey("close after drain", func() {
+			instances := New(closer, SetDrain[*testClient](50*time.Millisecond))
+			a1 := &testClient{name: "a1"}
+			instances.Store("a", a1)
+			instances.Store("a", &testClient{name: "a2"})
+			So(a1.isClosed(), ShouldBeFalse)
+
+			time.Sleep(200 * time.Millisecond)
+			So(a1.isClosed(), ShouldBeTrue)
+		})
+
+		Convey("subscribe", func() {
+			instances := New[*testClient]()
+			var names []string
+			instances.Subscribe(func(name string, client *testClient) {
+				names = append(names, name+":"+client.name)
+			})
+
+			instances.Swap(map[string]*testClient{"b": {name: "b1"}, "a": {name: "a1"}})
+			So(names, ShouldResemble, []string{"a:a1", "b:b1"})
+		})
+
+		Convey("all is a copy", func() {
+			instances := New[*testClient]()
+			instances.Store("a", &testClient{name: "a1"})
+			all := instances.All()
+			delete(all, "a")
+			So(instances.Len(), ShouldEqual, 1)
+		})
+
+		Convey("concurrent get and swap", func() {
+			instances := New(closer, SetDrain[*testClient](0))
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(2)
+				go func(i int) {
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						instances.Store(fmt.Sprint(j%4), &testClient{name: fmt.Sprint(i, j)})
+					}
+				}(i)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						instances.Get(fmt.Sprint(j % 4))
+						instances.All()
+					}
+				}()
+			}
+			wg.Wait()
+			So(instances.Len(), ShouldEqual, 4)
+		})
+	})
+}
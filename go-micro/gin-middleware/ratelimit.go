@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/JREAMLU/j-kit/database/redis"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit limit requests by the limiter shared by every replica, key is the client ip if nil
+// empty key is not limited, redis errors are logged and allowed
+func RateLimit(l *redis.Limiter, key func(c *gin.Context) string) gin.HandlerFunc {
+	if key == nil {
+		key = func(c *gin.Context) string {
+			return c.ClientIP()
+		}
+	}
+
+	return func(c *gin.Context) {
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+
+		result, err := l.Allow(c.Request.Context(), k)
+		if err != nil {
+			log.Printf("Failed on redis ratelimit, key: %v, err: %v \r\n", k, err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+		if !result.Allowed {
+			if result.RetryAfter > 0 {
+				c.Header("Retry-After", strconv.FormatInt(int64((result.RetryAfter+time.Second-1)/time.Second), 10))
+			}
+			c.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+
+		c.Next()
+	}
+}
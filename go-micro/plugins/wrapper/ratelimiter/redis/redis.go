@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"log"
+
+	jredis "github.com/JREAMLU/j-kit/database/redis"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/server"
+
+	"context"
+)
+
+// ClientKeyFunc key of the limit of a call, empty key is not limited
+type ClientKeyFunc func(ctx context.Context, req client.Request) string
+
+// HandlerKeyFunc key of the limit of a handle, empty key is not limited
+type HandlerKeyFunc func(ctx context.Context, req server.Request) string
+
+type clientWrapper struct {
+	l    *jredis.Limiter
+	wait bool
+	key  ClientKeyFunc
+	client.Client
+}
+
+// limit limit key by the limiter shared by every replica
+// redis errors are logged and allowed, the limit must not take down the service
+func limit(ctx context.Context, l *jredis.Limiter, wait bool, key, errID string) error {
+	if key == "" {
+		return nil
+	}
+
+	if wait {
+		err := l.Wait(ctx, key)
+		if err == nil {
+			return nil
+		}
+
+		if err == jredis.ErrLimiterCostExceeded || err == ctx.Err() {
+			return errors.New(errID, "too many request", 429)
+		}
+
+		log.Printf("Failed on redis ratelimit, key: %v, err: %v \r\n", key, err)
+		return nil
+	}
+
+	result, err := l.Allow(ctx, key)
+	if err != nil {
+		log.Printf("Failed on redis ratelimit, key: %v, err: %v \r\n", key, err)
+		return nil
+	}
+
+	if !result.Allowed {
+		return errors.New(errID, "too many request", 429)
+	}
+
+	return nil
+}
+
+func (c *clientWrapper) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	if err := limit(ctx, c.l, c.wait, c.key(ctx, req), "go.micro.client"); err != nil {
+		return err
+	}
+	return c.Client.Call(ctx, req, rsp, opts...)
+}
+
+// NewClientWrapper takes a redis limiter, wait flag and key func and returns a client Wrapper.
+// key is the request service if nil
+func NewClientWrapper(l *jredis.Limiter, wait bool, key ClientKeyFunc) client.Wrapper {
+	if key == nil {
+		key = func(ctx context.Context, req client.Request) string {
+			return req.Service()
+		}
+	}
+
+	return func(c client.Client) client.Client {
+		return &clientWrapper{l, wait, key, c}
+	}
+}
+
+// NewHandlerWrapper takes a redis limiter, wait flag and key func and returns a handler Wrapper.
+// key is the request service if nil, e.g. a user id of metadata for per user quotas
+func NewHandlerWrapper(l *jredis.Limiter, wait bool, key HandlerKeyFunc) server.HandlerWrapper {
+	if key == nil {
+		key = func(ctx context.Context, req server.Request) string {
+			return req.Service()
+		}
+	}
+
+	return func(h server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			if err := limit(ctx, l, wait, key(ctx, req), "go.micro.server"); err != nil {
+				return err
+			}
+			return h(ctx, req, rsp)
+		}
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"log"
 	"reflect"
 
-	"github.com/BurntSushi/toml"
 	"github.com/JREAMLU/j-kit/consul"
 	"github.com/JREAMLU/j-kit/ext"
 )
@@ -83,29 +82,45 @@ const (
 
 // LoadConfig load service config
 func LoadConfig(consulAddr string, name, version string) (*Config, error) {
-	sc := &Config{}
-	return sc, loadConfig(consulAddr, getServiceKey(name, version), sc)
+	client, err := consul.NewClient(consul.SetAddress(consulAddr))
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadConfigSource(client, name, version)
 }
 
 // LoadCustomConfig load service config by custom
 func LoadCustomConfig(consulAddr string, name, version string, sc interface{}) error {
-	return loadConfig(consulAddr, getServiceKey(name, version), sc)
-}
-
-func loadConfig(consulAddr string, key string, sc interface{}) error {
 	client, err := consul.NewClient(consul.SetAddress(consulAddr))
 	if err != nil {
 		return err
 	}
 
-	buf, err := client.Get(key)
+	return LoadCustomConfigSource(client, name, version, sc)
+}
+
+// LoadConfigSource load service config from source
+func LoadConfigSource(source consul.ConfigSource, name, version string) (*Config, error) {
+	sc := &Config{}
+	return sc, loadConfig(source, getServiceKey(name, version), sc)
+}
+
+// LoadCustomConfigSource load service config by custom from source
+func LoadCustomConfigSource(source consul.ConfigSource, name, version string, sc interface{}) error {
+	return loadConfig(source, getServiceKey(name, version), sc)
+}
+
+func loadConfig(source consul.ConfigSource, key string, sc interface{}) error {
+	buf, err := source.Get(key)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Load Config: %v \n%v\n%v\n%v\n\n", key, consul.SeparatorStart, buf, consul.SeparatorEnd)
+	// the value may be a decrypted secret, only the key is logged
+	log.Printf("Load Config: %v \n", key)
 
-	_, err = toml.Decode(buf, sc)
+	err = consul.DecodeSourceValue(source, key, []byte(buf), sc)
 	if err != nil {
 		return err
 	}
@@ -124,17 +139,17 @@ func loadConfig(consulAddr string, key string, sc interface{}) error {
 
 	// kafka zookeeper
 	if config != nil {
-		config.Kafka.ZipkinBroker, config.Kafka.ZipkinTopic, err = client.GetKafkas(_zipkin)
+		config.Kafka.ZipkinBroker, config.Kafka.ZipkinTopic, err = consul.LoadKafkas(source, _zipkin)
 		if err != nil {
 			return err
 		}
 
-		config.Kafka.Broker, _, err = client.GetKafkas(_broker)
+		config.Kafka.Broker, _, err = consul.LoadKafkas(source, _broker)
 		if err != nil {
 			return err
 		}
 
-		config.Zookeeper.BigdataAddrs, config.Zookeeper.BigdataZkroot, err = client.GetZookeepers(_bigdata)
+		config.Zookeeper.BigdataAddrs, config.Zookeeper.BigdataZkroot, err = consul.LoadZookeepers(source, _bigdata)
 		if err != nil {
 			return err
 		}
@@ -143,7 +158,7 @@ func loadConfig(consulAddr string, key string, sc interface{}) error {
 			config.Kafka.ZipkinTopic = zipkinTopic
 		}
 
-		config.Consul.RegistryAddrs, err = client.GetConsulAddrs(_consul)
+		config.Consul.RegistryAddrs, err = consul.LoadConsulAddrs(source, _consul)
 		if err != nil {
 			return err
 		}
@@ -1,8 +1,10 @@
 package util
 
 import (
+	"path"
 	"testing"
 
+	"github.com/JREAMLU/j-kit/consul"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -42,3 +44,38 @@ func TestLoadCustomConfig(t *testing.T) {
 		t.Log(config.Pusher)
 	})
 }
+
+func TestLoadConfigSource(t *testing.T) {
+	Convey("Load Config from source test", t, func() {
+		source := consul.NewMemorySource(map[string]string{
+			getServiceKey(serviceName, serviceVersion): `[service]
+Name = "go.micro.srv.pusher"
+Version = "v1"
+
+[web]
+Host = "0.0.0.0"
+Port = 8012
+
+[pusher]
+Auth = true
+Secret = "abc"`,
+			path.Join(consul.Kafka, _zipkin):      `Brokers = ["127.0.0.1:9092"]`,
+			path.Join(consul.Kafka, _broker):      `Brokers = ["127.0.0.2:9092"]`,
+			path.Join(consul.Zookeeper, _bigdata): `Addrs = ["127.0.0.1:2181"]`,
+			path.Join(consul.Consul, _consul):     `Addrs = ["127.0.0.1:8500"]`,
+		})
+
+		var config PusherConfig
+		err := LoadCustomConfigSource(source, serviceName, serviceVersion, &config)
+		So(err, ShouldBeNil)
+		So(config.Service.Name, ShouldEqual, "go.micro.srv.pusher")
+		So(config.Service.RegisterTTL, ShouldEqual, defaultRegisterTTL)
+		So(config.Web.URL, ShouldEqual, "0.0.0.0:8012")
+		So(config.Pusher.Secret, ShouldEqual, "abc")
+		So(config.Kafka.ZipkinBroker, ShouldResemble, []string{"127.0.0.1:9092"})
+		So(config.Kafka.ZipkinTopic, ShouldEqual, zipkinTopic)
+		So(config.Kafka.Broker, ShouldResemble, []string{"127.0.0.2:9092"})
+		So(config.Zookeeper.BigdataAddrs, ShouldResemble, []string{"127.0.0.1:2181"})
+		So(config.Consul.RegistryAddrs, ShouldResemble, []string{"127.0.0.1:8500"})
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/JREAMLU/j-kit/consul"
 	"github.com/JREAMLU/j-kit/ext"
 	"github.com/JREAMLU/j-kit/go-micro/util"
 	"github.com/JREAMLU/j-kit/uuid"
@@ -24,6 +25,7 @@ type Requests struct {
 	HTTPClient   *http.Client
 	TraceRequest RequestFunc
 	Cb           *gobreaker.CircuitBreaker
+	Discovery    *consul.Discovery
 }
 
 // Responses struct
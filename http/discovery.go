@@ -0,0 +1,41 @@
+package http
+
+import (
+	"context"
+	"errors"
+
+	"github.com/JREAMLU/j-kit/consul"
+	"github.com/JREAMLU/j-kit/ext"
+)
+
+// ErrDiscoveryNotSet discovery not set
+var ErrDiscoveryNotSet = errors.New("discovery not set, use SetDiscovery")
+
+// SetDiscovery set consul discovery, used by RequestService
+func (r *Requests) SetDiscovery(discovery *consul.Discovery) {
+	r.Discovery = discovery
+}
+
+// ServiceURL pick one healthy instance of service, return http://host:port + uri
+func (r *Requests) ServiceURL(service, uri string) (string, error) {
+	if r.Discovery == nil {
+		return "", ErrDiscoveryNotSet
+	}
+
+	addr, err := r.Discovery.Addr(service)
+	if err != nil {
+		return "", err
+	}
+
+	return ext.StringSplice("http://", addr, uri), nil
+}
+
+// RequestService call non-micro http service by consul service name
+func (r *Requests) RequestService(ctx context.Context, Method string, service string, URI string, Header map[string]string, Raw string, data interface{}) (rp Responses, err error) {
+	URLStr, err := r.ServiceURL(service, URI)
+	if err != nil {
+		return rp, err
+	}
+
+	return r.RequestCURL(ctx, Method, URLStr, Header, Raw, data)
+}